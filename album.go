@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"time"
+)
+
+// albumGainSet and albumGainDb are -album-gain's result.  They're set once, before any encoding starts, and
+// parseAudioSettings applies the gain to every file in place of loudnorm.
+var albumGainSet bool
+var albumGainDb float64
+
+// albumTrack is one file's measured loudness (LUFS), true peak (dBTP) and how long it is
+type albumTrack struct {
+	File     string
+	Loudness float64
+	Peak     float64
+	Duration time.Duration
+}
+
+// albumLoudness is the loudness of all the tracks played one after another.  Loudness is a log of energy, so the
+// tracks' energies are averaged weighted by how long each one is, rather than averaging the LUFS values.  A
+// track without a duration counts as one second.
+func albumLoudness(tracks []albumTrack) float64 {
+	var energy, seconds float64
+	for _, t := range tracks {
+		d := t.Duration.Seconds()
+		if d <= 0 {
+			d = 1
+		}
+		energy += d * math.Pow(10, t.Loudness/10)
+		seconds += d
+	}
+	if seconds == 0 {
+		return math.Inf(-1)
+	}
+	return 10 * math.Log10(energy/seconds)
+}
+
+// albumGain is the one gain that brings the album as a whole to target, the way ReplayGain's album gain works.
+// Every track gets the same gain, so a quiet episode stays quieter than a loud one.  If that would push the
+// loudest peak over peakTarget the gain is cut back to fit, and peakLimited says so.
+func albumGain(tracks []albumTrack, target, peakTarget float64) (gain, loudness float64, peakLimited bool, err error) {
+	loudness = albumLoudness(tracks)
+	if math.IsInf(loudness, 0) || math.IsNaN(loudness) {
+		return 0, loudness, false, fmt.Errorf("the album is silent, there's no loudness to adjust")
+	}
+	gain = target - loudness
+
+	peak := math.Inf(-1)
+	for _, t := range tracks {
+		peak = math.Max(peak, t.Peak)
+	}
+	if !math.IsInf(peak, 0) && peak+gain > peakTarget {
+		gain = peakTarget - peak
+		peakLimited = true
+	}
+	return
+}
+
+// measureAlbum is -album-gain: it measures every job's loudness, including ones whose output is already
+// there since they're still part of the album, and sets the gain all of them get.  A file that can't be
+// measured is left out of the album with a warning but still gets the gain.
+func measureAlbum(jobs []batchJob, settings Settings) error {
+	var pending []measureJob
+	for _, job := range jobs {
+		jobSettings, _, err := sidecarSettings(settings, job.In)
+		if err != nil {
+			jobSettings = settings
+		}
+		pending = append(pending, measureJob{File: job.In, Stream: audioStream(jobSettings.Audio, job.In)})
+	}
+	measureAll(pending)
+
+	var tracks []albumTrack
+	for _, m := range pending {
+		lnJson, ok := cachedLoudnorm(m.File, m.Stream)
+		if !ok {
+			log.Printf("warning: %s couldn't be measured, leaving it out of the album loudness\n", m.File)
+			continue
+		}
+		t := albumTrack{File: m.File}
+		t.Loudness, _ = strconv.ParseFloat(lnJson.InputI, 64)
+		t.Peak, _ = strconv.ParseFloat(lnJson.InputTp, 64)
+		if probe, err := probeFile(m.File); err == nil {
+			t.Duration = probe.duration()
+		}
+		tracks = append(tracks, t)
+	}
+	if len(tracks) == 0 {
+		return fmt.Errorf("-album-gain couldn't measure any of the files")
+	}
+
+	gain, loudness, peakLimited, err := albumGain(tracks, loudnormTargetI, loudnormTargetTp)
+	if err != nil {
+		return err
+	}
+	log.Printf("album loudness is %.2f LUFS over %d files, applying %+.2f dB to every file\n", loudness, len(tracks), gain)
+	if peakLimited {
+		log.Printf("warning: the gain is held back so the loudest peak stays under %.1f dBTP, the album will be quieter than %.0f LUFS\n", loudnormTargetTp, loudnormTargetI)
+	}
+	albumGainSet, albumGainDb = true, gain
+	return nil
+}
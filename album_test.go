@@ -0,0 +1,54 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestAlbumLoudness(t *testing.T) {
+	tests := []struct {
+		name   string
+		tracks []albumTrack
+		want   float64
+	}{
+		{"one track", []albumTrack{{Loudness: -20, Duration: time.Minute}}, -20},
+		{"same loudness", []albumTrack{{Loudness: -18, Duration: time.Minute}, {Loudness: -18, Duration: 3 * time.Minute}}, -18},
+		{"energy not lufs", []albumTrack{{Loudness: -20, Duration: 10 * time.Second}, {Loudness: -30, Duration: 10 * time.Second}}, -22.596},
+		{"longer track counts more", []albumTrack{{Loudness: -20, Duration: 30 * time.Second}, {Loudness: -30, Duration: 10 * time.Second}}, -21.107},
+		{"no duration is a second", []albumTrack{{Loudness: -20}, {Loudness: -30, Duration: time.Second}}, -22.596},
+	}
+	for _, tt := range tests {
+		if got := albumLoudness(tt.tracks); math.Abs(got-tt.want) > 0.001 {
+			t.Errorf("%s: albumLoudness = %.3f, want %.3f", tt.name, got, tt.want)
+		}
+	}
+	if got := albumLoudness(nil); !math.IsInf(got, -1) {
+		t.Errorf("albumLoudness(nil) = %v, want -Inf", got)
+	}
+}
+
+func TestAlbumGain(t *testing.T) {
+	tests := []struct {
+		name        string
+		tracks      []albumTrack
+		wantGain    float64
+		peakLimited bool
+		wantErr     bool
+	}{
+		{"quiet album", []albumTrack{{Loudness: -20, Peak: -8}, {Loudness: -20, Peak: -10}}, 4, false, false},
+		{"loud album", []albumTrack{{Loudness: -10, Peak: -0.5}}, -6, false, false},
+		{"peak limited", []albumTrack{{Loudness: -20, Peak: -5}, {Loudness: -20, Peak: -12}}, 3.5, true, false},
+		{"silent", nil, 0, false, true},
+	}
+	for _, tt := range tests {
+		gain, _, peakLimited, err := albumGain(tt.tracks, -16, -1.5)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: albumGain error = %v, want error %t", tt.name, err, tt.wantErr)
+			continue
+		}
+		if math.Abs(gain-tt.wantGain) > 0.001 || peakLimited != tt.peakLimited {
+			t.Errorf("%s: albumGain = %.3f, peak limited %t, want %.3f, %t", tt.name, gain, peakLimited, tt.wantGain, tt.peakLimited)
+		}
+	}
+}
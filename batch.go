@@ -0,0 +1,214 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type batchJob struct {
+	In  string
+	Out string
+}
+
+// runBatch processes every matching file under -indir with the same settings, writing to the mirrored path under -outdir.
+// A file with a settings sidecar next to it (see sidecarSettings) gets that merged over them.
+func runBatch(settings Settings) {
+	jobs, notMedia := mediaJobs(findBatchJobs(*inDir, *outDir), settings)
+	if *outName != "" {
+		nameBatchJobs(jobs, settings, *outName)
+	}
+	if len(jobs) == 0 {
+		fatalf("no files to process in %s\n", *inDir)
+	}
+
+	if *measureFirst && !*argsOnly {
+		measureBatch(jobs, settings)
+	}
+	if *albumGainFlag && *argsOnly {
+		log.Printf("warning: -args-only doesn't measure the files for -album-gain, the commands leave the album gain out\n")
+	} else if *albumGainFlag {
+		if err := measureAlbum(jobs, settings); err != nil {
+			fatalf("%v\n", err)
+		}
+	}
+
+	var processed, failed, timedOut int
+	skipped := notMedia
+	for _, job := range jobs {
+		if _, err := os.Stat(job.Out); err == nil && !*overwrite {
+			log.Printf("skipping %s, %s already exists\n", job.In, job.Out)
+			appendCsvReport(job.In, job.Out, "skipped", settings, 0)
+			skipped++
+			continue
+		}
+
+		if !*argsOnly {
+			if err := os.MkdirAll(filepath.Dir(job.Out), 0755); err != nil {
+				log.Printf("unable to create output directory for %s: %v\n", job.Out, err)
+				failed++
+				continue
+			}
+		}
+
+		jobSettings, sidecar, err := sidecarSettings(settings, job.In)
+		if err != nil {
+			log.Printf("skipping %s: %s\n", job.In, describeError(err))
+			failed++
+			continue
+		}
+		if sidecar != "" {
+			log.Printf("using the settings in %s for %s\n", sidecar, job.In)
+		}
+
+		log.Printf("processing %s -> %s\n", job.In, job.Out)
+		startTime := time.Now()
+		err = processFile(jobSettings, job.In, job.Out)
+		if errors.Is(err, errNotOverwriting) || errors.Is(err, errAlreadyMatches) {
+			log.Printf("skipping %s, %v\n", job.In, err)
+			appendCsvReport(job.In, job.Out, "skipped", jobSettings, 0)
+			skipped++
+			continue
+		}
+		if errors.Is(err, errTimedOut) {
+			log.Printf("gave up on %s, moving on: %s\n", job.In, describeError(err))
+			appendCsvReport(job.In, job.Out, "timed out", jobSettings, time.Since(startTime))
+			timedOut++
+			continue
+		}
+		if err != nil {
+			log.Printf("%s\n", describeError(err))
+			appendCsvReport(job.In, job.Out, "failed", jobSettings, time.Since(startTime))
+			failed++
+			continue
+		}
+		appendCsvReport(job.In, job.Out, "ok", jobSettings, time.Since(startTime))
+		processed++
+	}
+
+	log.Printf("batch finished: %d processed, %d skipped, %d failed, %d timed out\n", processed, skipped, failed, timedOut)
+	result := runResult{Input: *inDir, Output: *outDir, Processed: processed, Skipped: skipped, Failed: failed, TimedOut: timedOut}
+	if failed > 0 || timedOut > 0 {
+		result.ExitCode = 1
+		exitRun(result)
+	}
+	notifyRun(result)
+}
+
+// findBatchJobs expands the indir pattern and pairs each matching file with its output path.
+// When the pattern is a glob, each matched directory's name is kept under outdir so that
+// e.g. 'Season 1/ep1.mkv' and 'Season 2/ep1.mkv' don't land on the same output.
+func findBatchJobs(pattern, outRoot string) (jobs []batchJob) {
+	roots, err := filepath.Glob(filepath.Clean(pattern))
+	if err != nil {
+		fatalf("bad -indir pattern %s: %v\n", pattern, err)
+	}
+	keepRootName := hasGlobMeta(pattern)
+
+	for _, root := range roots {
+		fh, err := os.Stat(root)
+		if err != nil || !fh.IsDir() {
+			continue
+		}
+
+		err = filepath.WalkDir(root, func(file string, d fs.DirEntry, err error) error {
+			if err != nil {
+				log.Printf("unable to read %s: %v\n", file, err)
+				return nil
+			}
+			if d.IsDir() {
+				if file != root && !*recursive {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !batchExtAllowed(file) {
+				return nil
+			}
+
+			rel, err := filepath.Rel(root, file)
+			if err != nil {
+				return err
+			}
+			if keepRootName {
+				rel = filepath.Join(filepath.Base(root), rel)
+			}
+			jobs = append(jobs, batchJob{In: file, Out: batchOutPath(filepath.Join(outRoot, rel))})
+			return nil
+		})
+		if err != nil {
+			fatalf("unable to walk %s: %v\n", root, err)
+		}
+	}
+	return
+}
+
+func batchOutPath(file string) string {
+	if *outExt == "" {
+		return file
+	}
+	return strings.TrimSuffix(file, filepath.Ext(file)) + "." + strings.TrimPrefix(*outExt, ".")
+}
+
+func batchExtAllowed(file string) bool {
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(file), "."))
+	return (*includeExt == "*" || extInList(ext, *includeExt)) && !extInList(ext, *excludeExt)
+}
+
+// nonMediaFormats are what ffprobe calls files it reads as video without them being video: pictures, and
+// text like .nfo files that it renders as a terminal screen
+var nonMediaFormats = []string{"image2", "tty"}
+
+// isMedia is whether ffprobe finds audio or video worth encoding in file, and why not if it doesn't.  A file
+// ffprobe can't read isn't media, but if ffprobe itself can't be run the file gets the benefit of the doubt.
+func isMedia(file string) (media bool, why string) {
+	probe, err := probeFile(file)
+	var probeErr *ProbeError
+	if errors.As(err, &probeErr) {
+		return false, "ffprobe can't read it"
+	} else if err != nil {
+		return true, ""
+	}
+	format := probe.Format.FormatName
+	if stringInList(format, nonMediaFormats) || strings.HasSuffix(format, "_pipe") {
+		return false, fmt.Sprintf("it's %s, not audio or video", format)
+	}
+	if len(probe.streamsOfType("video")) == 0 && len(probe.streamsOfType("audio")) == 0 {
+		return false, "it has no audio or video streams"
+	}
+	return true, ""
+}
+
+// mediaJobs drops the jobs whose input isn't audio or video, like the cover art and .nfo files that end
+// up next to videos.  They're counted as skipped rather than failed.  The extension filter has already
+// cut out what it can without running ffprobe, -include-ext '*' leaves it all to this.
+func mediaJobs(jobs []batchJob, settings Settings) (media []batchJob, skipped int) {
+	for _, job := range jobs {
+		if ok, why := isMedia(job.In); !ok {
+			log.Printf("skipping %s, %s\n", job.In, why)
+			appendCsvReport(job.In, job.Out, "skipped", settings, 0)
+			skipped++
+			continue
+		}
+		media = append(media, job)
+	}
+	return
+}
+
+func extInList(ext, list string) bool {
+	for _, e := range strings.Split(list, ",") {
+		if strings.ToLower(strings.TrimPrefix(strings.TrimSpace(e), ".")) == ext {
+			return true
+		}
+	}
+	return false
+}
+
+func hasGlobMeta(pattern string) bool {
+	return strings.ContainsAny(pattern, `*?[`)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fakeFfprobe points ffprobeBin at a shell script for the test, the file being probed is $7.  probeFile's
+// cache is cleared so nothing probed with another script is reused.
+func fakeFfprobe(t *testing.T, script string) {
+	t.Helper()
+	bin := filepath.Join(t.TempDir(), "ffprobe")
+	if err := os.WriteFile(bin, []byte("#!/bin/sh\n"+script+"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	old := ffprobeBin
+	ffprobeBin = bin
+	clearProbeCache()
+	t.Cleanup(func() {
+		ffprobeBin = old
+		clearProbeCache()
+	})
+}
+
+func clearProbeCache() {
+	probeCacheMu.Lock()
+	defer probeCacheMu.Unlock()
+	probeCache = map[string]probeData{}
+}
+
+func TestIsMedia(t *testing.T) {
+	fakeFfprobe(t, `case "$7" in
+movie.mkv) echo '{"streams":[{"codec_type":"video"},{"codec_type":"audio"}],"format":{"format_name":"matroska,webm"}}' ;;
+song.flac) echo '{"streams":[{"codec_type":"audio"}],"format":{"format_name":"flac"}}' ;;
+cover.jpg) echo '{"streams":[{"codec_type":"video"}],"format":{"format_name":"image2"}}' ;;
+poster.png) echo '{"streams":[{"codec_type":"video"}],"format":{"format_name":"png_pipe"}}' ;;
+movie.nfo) echo '{"streams":[{"codec_type":"video"}],"format":{"format_name":"tty"}}' ;;
+subs.mkv) echo '{"streams":[{"codec_type":"subtitle"}],"format":{"format_name":"matroska,webm"}}' ;;
+*) echo 'Invalid data found when processing input' >&2; exit 1 ;;
+esac`)
+	tests := []struct {
+		file  string
+		media bool
+	}{
+		{"movie.mkv", true},
+		{"song.flac", true},
+		{"cover.jpg", false},
+		{"poster.png", false},
+		{"movie.nfo", false},
+		{"subs.mkv", false},
+		{"notes.txt", false},
+	}
+	for _, tt := range tests {
+		media, why := isMedia(tt.file)
+		if media != tt.media {
+			t.Errorf("isMedia(%q) = %t (%s), want %t", tt.file, media, why, tt.media)
+		}
+		if !media && why == "" {
+			t.Errorf("isMedia(%q) didn't say why it isn't media", tt.file)
+		}
+	}
+}
+
+func TestBatchExtAllowed(t *testing.T) {
+	defer func(in, ex string) { *includeExt, *excludeExt = in, ex }(*includeExt, *excludeExt)
+	tests := []struct {
+		include string
+		exclude string
+		file    string
+		want    bool
+	}{
+		{"mkv,mp4", "", "a.mkv", true},
+		{"mkv,mp4", "", "a.MP4", true},
+		{"mkv, .mp4", "", "a.mp4", true},
+		{"mkv,mp4", "", "a.jpg", false},
+		{"*", "", "a.jpg", true},
+		{"*", "jpg,nfo", "a.jpg", false},
+		{"mkv", "mkv", "a.mkv", false},
+	}
+	for _, tt := range tests {
+		*includeExt, *excludeExt = tt.include, tt.exclude
+		if got := batchExtAllowed(tt.file); got != tt.want {
+			t.Errorf("batchExtAllowed(%q) with -include-ext %q -exclude-ext %q = %t, want %t", tt.file, tt.include, tt.exclude, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// ffmpegBin is the resolved ffmpeg executable, set by resolveFfmpeg before anything runs
+var ffmpegBin = "/usr/bin/ffmpeg"
+
+// ffprobeBin is resolved the first time something probes a file
+var ffprobeBin string
+
+func resolveFfmpeg() {
+	bin, err := resolveBinary("ffmpeg", *ffmpegPath, "/usr/bin/ffmpeg")
+	if err != nil {
+		fatalf("%v; install it or set -ffmpeg-path\n", err)
+	}
+	ffmpegBin = bin
+}
+
+// resolveFfprobe finds ffprobe: -ffprobe-path, then $FFPROBE_PATH, then next to the resolved ffmpeg (named
+// the same way, so ffmpeg-6 finds ffprobe-6), then PATH and /usr/bin/ffprobe
+func resolveFfprobe() (bin string, err error) {
+	if ffprobeBin != "" {
+		return ffprobeBin, nil
+	}
+	override := *ffprobePath
+	if override == "" {
+		override = os.Getenv("FFPROBE_PATH")
+	}
+	if override == "" {
+		if sibling := siblingFfprobe(ffmpegBin); sibling != "" {
+			override = sibling
+		}
+	}
+	bin, err = resolveBinary("ffprobe", override, "/usr/bin/ffprobe")
+	if err != nil {
+		err = fmt.Errorf("%v; install it (it comes with ffmpeg) or set -ffprobe-path", err)
+		return
+	}
+	ffprobeBin = bin
+	return
+}
+
+// siblingFfprobe is the ffprobe in the same directory as ffmpeg, or "" if there isn't one
+func siblingFfprobe(ffmpeg string) string {
+	name := filepath.Base(ffmpeg)
+	if strings.Contains(name, "ffmpeg") {
+		name = strings.Replace(name, "ffmpeg", "ffprobe", 1)
+	} else {
+		name = "ffprobe" + filepath.Ext(name)
+	}
+	sibling := filepath.Join(filepath.Dir(ffmpeg), name)
+	if fh, err := os.Stat(sibling); err != nil || fh.IsDir() {
+		return ""
+	}
+	return sibling
+}
+
+// resolveBinary finds an executable: the override if one was given, otherwise PATH, otherwise the fallback location
+func resolveBinary(name, override, fallback string) (bin string, err error) {
+	if override != "" {
+		fh, statErr := os.Stat(override)
+		if statErr != nil || fh.IsDir() {
+			err = fmt.Errorf("%s not found at %s", name, override)
+			return
+		}
+		bin = override
+		return
+	}
+
+	bin, err = exec.LookPath(name)
+	if err == nil {
+		return
+	}
+	if fh, statErr := os.Stat(fallback); statErr == nil && !fh.IsDir() {
+		return fallback, nil
+	}
+	err = fmt.Errorf("%s not found in PATH or at %s", name, fallback)
+	return
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeExecutable makes an empty executable file at dir/name and returns its path
+func writeExecutable(t *testing.T, dir, name string) string {
+	t.Helper()
+	file := filepath.Join(dir, name)
+	if err := os.WriteFile(file, []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestResolveFfprobe(t *testing.T) {
+	defer func(f, p, flag string) { ffmpegBin, ffprobeBin, *ffprobePath = f, p, flag }(ffmpegBin, ffprobeBin, *ffprobePath)
+
+	flagDir, envDir, ffmpegDir, pathDir, bareDir := t.TempDir(), t.TempDir(), t.TempDir(), t.TempDir(), t.TempDir()
+	flagProbe := writeExecutable(t, flagDir, "ffprobe")
+	envProbe := writeExecutable(t, envDir, "ffprobe")
+	ffmpeg := writeExecutable(t, ffmpegDir, "ffmpeg-6")
+	siblingProbe := writeExecutable(t, ffmpegDir, "ffprobe-6")
+	pathProbe := writeExecutable(t, pathDir, "ffprobe")
+	bareFfmpeg := writeExecutable(t, bareDir, "ffmpeg")
+	t.Setenv("PATH", pathDir)
+
+	tests := []struct {
+		name    string
+		flag    string
+		env     string
+		ffmpeg  string
+		want    string
+		wantErr bool
+	}{
+		{"flag first", flagProbe, envProbe, ffmpeg, flagProbe, false},
+		{"then the environment", "", envProbe, ffmpeg, envProbe, false},
+		{"then next to ffmpeg", "", "", ffmpeg, siblingProbe, false},
+		{"then PATH", "", "", bareFfmpeg, pathProbe, false},
+		{"missing flag path", filepath.Join(flagDir, "missing"), envProbe, ffmpeg, "", true},
+		{"directory in the environment", "", envDir, ffmpeg, "", true},
+	}
+	for _, tt := range tests {
+		ffprobeBin, *ffprobePath, ffmpegBin = "", tt.flag, tt.ffmpeg
+		t.Setenv("FFPROBE_PATH", tt.env)
+		got, err := resolveFfprobe()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: resolveFfprobe() error = %v, want error %t", tt.name, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: resolveFfprobe() = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+
+	//once found it isn't looked up again
+	ffprobeBin, *ffprobePath = "", flagProbe
+	resolveFfprobe()
+	*ffprobePath = envProbe
+	if got, _ := resolveFfprobe(); got != flagProbe {
+		t.Errorf("second resolveFfprobe() = %s, want the first result %s", got, flagProbe)
+	}
+}
+
+func TestSiblingFfprobe(t *testing.T) {
+	dir := t.TempDir()
+	writeExecutable(t, dir, "ffprobe")
+	writeExecutable(t, dir, "ffprobe-6")
+	if err := os.Mkdir(filepath.Join(dir, "ffprobe.exe"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		ffmpeg string
+		want   string
+	}{
+		{filepath.Join(dir, "ffmpeg"), filepath.Join(dir, "ffprobe")},
+		{filepath.Join(dir, "ffmpeg-6"), filepath.Join(dir, "ffprobe-6")},
+		{filepath.Join(dir, "ffmpeg-7"), ""},
+		{filepath.Join(dir, "avconv"), filepath.Join(dir, "ffprobe")},
+		{filepath.Join(dir, "ffmpeg.exe"), ""},
+	}
+	for _, tt := range tests {
+		if got := siblingFfprobe(tt.ffmpeg); got != tt.want {
+			t.Errorf("siblingFfprobe(%s) = %q, want %q", tt.ffmpeg, got, tt.want)
+		}
+	}
+}
+
+func TestResolveBinary(t *testing.T) {
+	pathDir, fallbackDir := t.TempDir(), t.TempDir()
+	onPath := writeExecutable(t, pathDir, "ffmpeg")
+	fallback := writeExecutable(t, fallbackDir, "ffmpeg")
+
+	t.Setenv("PATH", pathDir)
+	if got, err := resolveBinary("ffmpeg", fallback, "/nonexistent"); err != nil || got != fallback {
+		t.Errorf("resolveBinary with an override = %s, %v, want %s", got, err, fallback)
+	}
+	if got, err := resolveBinary("ffmpeg", "", fallback); err != nil || got != onPath {
+		t.Errorf("resolveBinary from PATH = %s, %v, want %s", got, err, onPath)
+	}
+	t.Setenv("PATH", t.TempDir())
+	if got, err := resolveBinary("ffmpeg", "", fallback); err != nil || got != fallback {
+		t.Errorf("resolveBinary not on PATH = %s, %v, want the fallback %s", got, err, fallback)
+	}
+	if _, err := resolveBinary("ffmpeg", "", filepath.Join(fallbackDir, "missing")); err == nil {
+		t.Errorf("resolveBinary with nothing to find = nil error, want one")
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// bitstreamFilters are the bitstream filters ffmpeg has.  They rewrite the encoded packets without decoding
+// them, so they work with justCopy, which is mostly what they're for.
+var bitstreamFilters = []string{
+	"aac_adtstoasc", "av1_frame_merge", "av1_frame_split", "av1_metadata", "chomp", "dca_core", "dts2pts",
+	"dump_extra", "dv_error_marker", "eac3_core", "evc_frame_merge", "extract_extradata", "filter_units",
+	"h264_metadata", "h264_mp4toannexb", "h264_redundant_pps", "hapqa_extract", "hevc_metadata",
+	"hevc_mp4toannexb", "imxdump", "media100_to_mjpegb", "mjpeg2jpeg", "mjpegadump", "mov2textsub",
+	"mp3decomp", "mpeg2_metadata", "mpeg4_unpack_bframes", "noise", "null", "opus_metadata", "pcm_rechunk",
+	"pgs_frame_merge", "prores_metadata", "remove_extra", "setts", "showinfo", "text2movsub", "trace_headers",
+	"truehd_core", "vp9_metadata", "vp9_raw_reorder", "vp9_superframe", "vp9_superframe_split",
+	"vvc_metadata", "vvc_mp4toannexb",
+}
+
+// bsfArgs emits -bsf for streamType (v or a), ex: h264_mp4toannexb for copying h264 out of mp4 into mpegts.
+// Filters run in the order given and can have options after an =, like h264_metadata=level=4.1.
+func bsfArgs(streamType, setting string, filters []string) (args []string, err error) {
+	if len(filters) == 0 {
+		return
+	}
+	for _, f := range filters {
+		name, _, _ := strings.Cut(f, "=")
+		if !stringInList(name, bitstreamFilters) {
+			return nil, fmt.Errorf("%s %q is not a bitstream filter ffmpeg has, ex: h264_mp4toannexb, aac_adtstoasc", setting, name)
+		}
+	}
+	args = append(args, []string{"-bsf:" + streamType, strings.Join(filters, ",")}...)
+	return
+}
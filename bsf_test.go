@@ -0,0 +1,31 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBsfArgs(t *testing.T) {
+	tests := []struct {
+		streamType string
+		filters    []string
+		want       []string
+		wantErr    bool
+	}{
+		{"v", nil, nil, false},
+		{"v", []string{"h264_mp4toannexb"}, []string{"-bsf:v", "h264_mp4toannexb"}, false},
+		{"v", []string{"h264_metadata=level=4.1", "dump_extra"}, []string{"-bsf:v", "h264_metadata=level=4.1,dump_extra"}, false},
+		{"a", []string{"aac_adtstoasc"}, []string{"-bsf:a", "aac_adtstoasc"}, false},
+		{"v", []string{"h264_mp4toannexb", "scale=1280:720"}, nil, true},
+	}
+	for _, tt := range tests {
+		got, err := bsfArgs(tt.streamType, "video.bitstreamFilters", tt.filters)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("bsfArgs(%q, %q) error = %v, want error %t", tt.streamType, tt.filters, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("bsfArgs(%q, %q) = %q, want %q", tt.streamType, tt.filters, got, tt.want)
+		}
+	}
+}
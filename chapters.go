@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+type chapter struct {
+	Start float64
+	End   float64
+	Title string
+}
+
+// chapterContainers are the containers that can hold chapters
+var chapterContainers = []string{"mkv", "mp4", "mov"}
+
+// prepareChapters checks output.chaptersFile can be used for out.  A simple list of "<time> <title>" lines is
+// turned into an ffmetadata file under -tmpdir, and o comes back pointing at it; cleanup removes it again.
+func prepareChapters(o Output, in, out string) (newO Output, cleanup func(), err error) {
+	newO, cleanup = o, func() {}
+	if container := outputContainer(o, out); !stringInList(container, chapterContainers) {
+		log.Printf("warning: .%s files can't hold chapters, ignoring output.chaptersFile\n", container)
+		newO.ChaptersFile = ""
+		return
+	}
+
+	data, err := os.ReadFile(o.ChaptersFile)
+	if err != nil {
+		err = fmt.Errorf("unable to read output.chaptersFile: %v", err)
+		return
+	}
+	if strings.HasPrefix(string(data), ";FFMETADATA1") {
+		return
+	}
+
+	chapters, err := parseChapterList(string(data))
+	if err != nil {
+		err = fmt.Errorf("unable to read chapters from %s: %v", o.ChaptersFile, err)
+		return
+	}
+	probe, probeErr := probeFile(in)
+	if probeErr != nil || probe.duration() <= 0 {
+		err = fmt.Errorf("unable to tell where the last chapter in %s ends, the input's duration is unknown: %v", o.ChaptersFile, probeErr)
+		return
+	}
+	chapters[len(chapters)-1].End = probe.duration().Seconds()
+
+	if *argsOnly {
+		//where it would be written, without writing it
+		newO.ChaptersFile = filepath.Join(*tmpDir, "ffmpegfront-chapters.txt")
+		return
+	}
+	fh, err := os.CreateTemp(runTempDir, "ffmpegfront-chapters-*.txt")
+	if err != nil {
+		err = fmt.Errorf("unable to write chapters metadata: %v", err)
+		return
+	}
+	_, err = fh.WriteString(ffmetadata(chapters))
+	fh.Close()
+	if err != nil {
+		os.Remove(fh.Name())
+		err = fmt.Errorf("unable to write chapters metadata: %v", err)
+		return
+	}
+	newO.ChaptersFile = fh.Name()
+	cleanup = func() { os.Remove(fh.Name()) }
+	return
+}
+
+// parseChapterList reads one chapter per line as "<start> <title>", the start in seconds or [hh:]mm:ss[.ms].
+// Each chapter ends where the next one starts, the last one's end is left for the caller.
+func parseChapterList(data string) (chapters []chapter, err error) {
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.SplitN(line, " ", 2)
+		start, timeErr := segmentSeconds(fields[0])
+		if timeErr != nil {
+			err = fmt.Errorf("line %d: %v", n, timeErr)
+			return
+		}
+		title := fmt.Sprintf("Chapter %d", len(chapters)+1)
+		if len(fields) == 2 && strings.TrimSpace(fields[1]) != "" {
+			title = strings.TrimSpace(fields[1])
+		}
+		chapters = append(chapters, chapter{Start: start, Title: title})
+	}
+	if len(chapters) == 0 {
+		err = fmt.Errorf("no chapters found")
+		return
+	}
+
+	sort.SliceStable(chapters, func(i, j int) bool { return chapters[i].Start < chapters[j].Start })
+	for i := 0; i < len(chapters)-1; i++ {
+		chapters[i].End = chapters[i+1].Start
+	}
+	return
+}
+
+// ffmetadata writes chapters in ffmpeg's metadata file format, with times in milliseconds
+func ffmetadata(chapters []chapter) string {
+	escaper := strings.NewReplacer(`\`, `\\`, "=", `\=`, ";", `\;`, "#", `\#`, "\n", "\\\n")
+	var b strings.Builder
+	b.WriteString(";FFMETADATA1\n")
+	for _, c := range chapters {
+		fmt.Fprintf(&b, "\n[CHAPTER]\nTIMEBASE=1/1000\nSTART=%d\nEND=%d\ntitle=%s\n", int64(c.Start*1000), int64(c.End*1000), escaper.Replace(c.Title))
+	}
+	return b.String()
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseChapterList(t *testing.T) {
+	tests := []struct {
+		data    string
+		want    []chapter
+		wantErr bool
+	}{
+		{"0 Intro\n90 The Middle\n1:02:03.5 Credits\n", []chapter{{0, 90, "Intro"}, {90, 3723.5, "The Middle"}, {3723.5, 0, "Credits"}}, false},
+		{"# comments and blank lines are skipped\n\n5:00\n0 Start\n", []chapter{{0, 300, "Start"}, {300, 0, "Chapter 1"}}, false},
+		{"\n# nothing\n", nil, true},
+		{"0 Intro\nsoon Credits\n", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := parseChapterList(tt.data)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseChapterList(%q) error = %v, want error %t", tt.data, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseChapterList(%q) = %+v, want %+v", tt.data, got, tt.want)
+		}
+	}
+}
+
+func TestFfmetadata(t *testing.T) {
+	got := ffmetadata([]chapter{{0, 90.25, "Intro"}, {90.25, 600.5, "Act 1; a=b #2"}})
+	want := ";FFMETADATA1\n" +
+		"\n[CHAPTER]\nTIMEBASE=1/1000\nSTART=0\nEND=90250\ntitle=Intro\n" +
+		"\n[CHAPTER]\nTIMEBASE=1/1000\nSTART=90250\nEND=600500\ntitle=Act 1\\; a\\=b \\#2\n"
+	if got != want {
+		t.Errorf("ffmetadata = %q, want %q", got, want)
+	}
+}
+
+func TestPrepareChapters(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+	defer func(a bool) { *argsOnly = a }(*argsOnly)
+	*argsOnly = false
+	defer func(d string) { runTempDir = d }(runTempDir)
+	runTempDir = t.TempDir()
+	fakeFfprobe(t, `echo '{"streams":[{"codec_type":"video","codec_name":"h264"}],"format":{"duration":"600.5"}}'`)
+
+	dir := t.TempDir()
+	list := filepath.Join(dir, "chapters.txt")
+	if err := os.WriteFile(list, []byte("0 Intro\n90 Act 1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	meta := filepath.Join(dir, "chapters.ffmeta")
+	if err := os.WriteFile(meta, []byte(";FFMETADATA1\n[CHAPTER]\nTIMEBASE=1/1000\nSTART=0\nEND=1000\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	o, cleanup, err := prepareChapters(Output{ChaptersFile: list}, "in.mkv", "out.mkv")
+	if err != nil {
+		t.Fatalf("prepareChapters(%s) = %v", list, err)
+	}
+	data, err := os.ReadFile(o.ChaptersFile)
+	if err != nil {
+		t.Fatalf("prepareChapters(%s) wrote %s: %v", list, o.ChaptersFile, err)
+	}
+	if want := ffmetadata([]chapter{{0, 90, "Intro"}, {90, 600.5, "Act 1"}}); string(data) != want {
+		t.Errorf("prepareChapters(%s) wrote %q, want %q", list, data, want)
+	}
+	cleanup()
+	if _, err := os.Stat(o.ChaptersFile); !os.IsNotExist(err) {
+		t.Errorf("%s still there after cleanup: %v", o.ChaptersFile, err)
+	}
+
+	if o, _, err = prepareChapters(Output{ChaptersFile: meta}, "in.mkv", "out.mp4"); err != nil || o.ChaptersFile != meta {
+		t.Errorf("prepareChapters(%s) = %s, %v, want the ffmetadata file used as it is", meta, o.ChaptersFile, err)
+	}
+	if o, _, err = prepareChapters(Output{ChaptersFile: list}, "in.mkv", "out.webm"); err != nil || o.ChaptersFile != "" {
+		t.Errorf("prepareChapters(%s) into webm = %q, %v, want it dropped", list, o.ChaptersFile, err)
+	}
+	if _, _, err = prepareChapters(Output{ChaptersFile: filepath.Join(dir, "missing.txt")}, "in.mkv", "out.mkv"); err == nil {
+		t.Errorf("prepareChapters with a missing file = nil error, want one")
+	}
+}
+
+func TestChaptersArgs(t *testing.T) {
+	args := testArgs(t, Settings{Output: Output{ChaptersFile: "chapters.ffmeta"}})
+	meta := argIndex(args, "chapters.ffmeta")
+	if meta < 2 || args[meta-1] != "-i" || args[meta-2] != "ffmetadata" || args[meta-3] != "-f" {
+		t.Errorf("buildArgs = %q, want -f ffmetadata -i chapters.ffmeta", args)
+	}
+	if got := argValue(args, "-map_chapters"); got != "1" {
+		t.Errorf("buildArgs -map_chapters = %q, want 1", got)
+	}
+
+	args = testArgs(t, Settings{})
+	if argIndex(args, "-map_chapters") >= 0 || argIndex(args, "ffmetadata") >= 0 {
+		t.Errorf("buildArgs without output.chaptersFile = %q, want no chapters input", args)
+	}
+}
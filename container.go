@@ -0,0 +1,277 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// containerCodecs lists the codecs each container can hold per stream type.  A container missing from
+// this map, or a stream type missing from a container's entry, is treated as accepting anything.
+var containerCodecs = map[string]map[string][]string{
+	"mp4": {
+		"video":    {"h264", "hevc", "mpeg4", "av1", "vp9"},
+		"audio":    {"aac", "mp3", "ac3", "eac3", "alac"},
+		"subtitle": {"mov_text"},
+	},
+	"mov": {
+		"video":    {"h264", "hevc", "mpeg4", "prores"},
+		"audio":    {"aac", "mp3", "ac3", "eac3", "alac", "pcm_s16le", "pcm_s24le"},
+		"subtitle": {"mov_text"},
+	},
+	"webm": {
+		"video":    {"vp8", "vp9", "av1"},
+		"audio":    {"opus", "vorbis"},
+		"subtitle": {"webvtt"},
+	},
+}
+
+// containerTranscodeSuggestion is what to recommend when a stream can't be copied into a container
+var containerTranscodeSuggestion = map[string]map[string]string{
+	"mp4":  {"video": "h264", "audio": "aac", "subtitle": "mov_text"},
+	"mov":  {"video": "h264", "audio": "aac", "subtitle": "mov_text"},
+	"webm": {"video": "vp9", "audio": "opus", "subtitle": "webvtt"},
+}
+
+func containerOf(file string) string {
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(file), "."))
+	switch ext {
+	case "m4v":
+		ext = "mp4"
+	case "m2ts", "mts":
+		ext = "ts"
+	}
+	return ext
+}
+
+// outputContainer is the container the output will be, from output.format if it's set, otherwise from the file extension
+func outputContainer(o Output, out string) string {
+	formats := map[string]string{
+		"matroska": "mkv",
+		"mpegts":   "ts",
+		"ipod":     "mp4",
+	}
+	if o.Format == "" {
+		return containerOf(out)
+	}
+	if c, ok := formats[o.Format]; ok {
+		return c
+	}
+	return o.Format
+}
+
+func containerSupports(container, codecType, codec string) bool {
+	codecs, ok := containerCodecs[container][codecType]
+	if !ok {
+		return true
+	}
+	return stringInList(codec, codecs)
+}
+
+// suggestionEncoders are the encoders to use for a containerTranscodeSuggestion that isn't an encoder's name
+var suggestionEncoders = map[string]string{
+	"opus": "libopus",
+}
+
+// inputAudioCodec is the codec of the audio track the output takes from in, empty if it can't be probed
+func inputAudioCodec(a Audio, in string) string {
+	probe, err := probeFile(in)
+	if err != nil {
+		log.Printf("warning: unable to check the input's audio codec: %v\n", err)
+		return ""
+	}
+	var n int
+	fmt.Sscanf(audioStream(a, in), "0:a:%d", &n)
+	streams := probe.streamsOfType("audio")
+	if n >= len(streams) {
+		return ""
+	}
+	return streams[n].CodecName
+}
+
+// containerAudio checks the audio going into out is a codec its container can hold, since ffmpeg only finds
+// out once it starts writing.  By default audio that doesn't fit is encoded to the container's usual codec
+// instead, with a warning.  With audio.incompatibleAudio set to error, or with -remux unless it's set to
+// transcode, it fails before anything runs.
+func containerAudio(a Audio, o Output, in, out string) (Audio, error) {
+	switch a.IncompatibleAudio {
+	case "", "transcode", "error":
+	default:
+		return a, fmt.Errorf("audio.incompatibleAudio %q should be transcode or error", a.IncompatibleAudio)
+	}
+	container := outputContainer(o, out)
+	if _, ok := containerCodecs[container]["audio"]; !ok {
+		return a, nil
+	}
+
+	var codec string
+	if a.JustCopy {
+		codec = inputAudioCodec(a, in)
+	} else {
+		codec = a.AudioCodec
+		if codec == "" {
+			codec = "aac"
+		}
+		if c, ok := encoderCodecs[codec]; ok {
+			codec = c
+		}
+	}
+	if codec == "" || containerSupports(container, "audio", codec) {
+		return a, nil
+	}
+
+	suggestion := containerTranscodeSuggestion[container]["audio"]
+	//-remux promises not to re-encode anything
+	if a.IncompatibleAudio == "error" || (*remux && a.IncompatibleAudio == "") {
+		return a, fmt.Errorf("%s audio can't go in a .%s file, set audio.audioCodec to one it can hold, like %s, or use another container", codec, container, suggestion)
+	}
+	log.Printf("warning: %s audio can't go in a .%s file, encoding it to %s instead\n", codec, container, suggestion)
+	a.JustCopy = false
+	a.AudioCodec = suggestion
+	if encoder, ok := suggestionEncoders[suggestion]; ok {
+		a.AudioCodec = encoder
+	}
+	//these were for the codec that was asked for
+	a.AudioQuality = ""
+	a.AudioProfile = ""
+	return a, nil
+}
+
+// inputVideoCodec is the codec of in's first video track, empty if it can't be probed
+func inputVideoCodec(in string) string {
+	probe, err := probeFile(in)
+	if err != nil {
+		log.Printf("warning: unable to check the input's video codec: %v\n", err)
+		return ""
+	}
+	if streams := probe.streamsOfType("video"); len(streams) > 0 {
+		return streams[0].CodecName
+	}
+	return ""
+}
+
+// containerVideo checks video.justCopy can copy the input's video into out's container.  Copying the video
+// and encoding only the audio is the quick way to fix the audio (loudness, codec, channels), since the video
+// is most of the work of an encode, so it fails before anything runs rather than quietly encoding the video.
+func containerVideo(v Video, a Audio, o Output, in, out string) error {
+	if !v.JustCopy || v.DisableVideo {
+		return nil
+	}
+	container := outputContainer(o, out)
+	if codec := inputVideoCodec(in); codec != "" && !containerSupports(container, "video", codec) {
+		return fmt.Errorf("video.justCopy can't copy %s video into a .%s file, use a container that holds it, like mkv, or encode it to %s by turning justCopy off", codec, container, containerTranscodeSuggestion[container]["video"])
+	}
+	if !a.JustCopy && !a.DisableAudio {
+		log.Printf("copying the video as it is and only encoding the audio\n")
+	}
+	return nil
+}
+
+// checkContainerVideo runs containerVideo for the main output and each of settings.Outputs, which are outs
+// in the same order
+func checkContainerVideo(settings Settings, in string, outs []string) error {
+	if err := containerVideo(settings.Video, settings.Audio, settings.Output, in, outs[0]); err != nil {
+		return err
+	}
+	for i, extra := range settings.Outputs {
+		if err := containerVideo(extra.Video, extra.Audio, Output{}, in, outs[i+1]); err != nil {
+			return fmt.Errorf("outputs[%d]: %w", i, err)
+		}
+	}
+	return nil
+}
+
+var codecTagRegex = regexp.MustCompile(`^[A-Za-z0-9 ]{4}$`)
+
+// codecTagArgs sets the video's codec tag, the fourcc players go by to pick a decoder.  video.codecTag wins,
+// otherwise hevc in mp4 or mov is tagged hvc1.  ffmpeg's default there is hev1, which apple devices refuse to
+// play even though it's the same video.
+func codecTagArgs(v Video, o Output, in, out string) (args []string, err error) {
+	if v.CodecTag != "" {
+		if !codecTagRegex.MatchString(v.CodecTag) {
+			return nil, fmt.Errorf("video.codecTag %q should be a 4 character code like hvc1 or avc1", v.CodecTag)
+		}
+		return []string{"-tag:v", v.CodecTag}, nil
+	}
+	if container := outputContainer(o, out); container != "mp4" && container != "mov" {
+		return
+	}
+
+	codec := encoderCodecs[videoEncoder(v)]
+	if v.JustCopy {
+		codec = inputVideoCodec(in)
+	}
+	if codec == "hevc" {
+		args = append(args, []string{"-tag:v", "hvc1"}...)
+	}
+	return
+}
+
+// checkRemuxCompatibility probes the input and warns about every stream that can't be copied as-is into
+// the output's container.  It returns false if anything would need a transcode.
+func checkRemuxCompatibility(o Output, in, out string) (ok bool) {
+	ok = true
+	container := outputContainer(o, out)
+	probe, err := probeFile(in)
+	if err != nil {
+		log.Printf("warning: skipping remux compatibility check: %v\n", err)
+		return
+	}
+
+	for _, s := range probe.Streams {
+		if s.CodecType != "video" && s.CodecType != "audio" && s.CodecType != "subtitle" {
+			continue
+		}
+		if containerSupports(container, s.CodecType, s.CodecName) {
+			continue
+		}
+		ok = false
+		log.Printf("warning: stream #%d (%s, %s) can't be copied into a .%s file; it needs to be transcoded, e.g. to %s\n", s.Index, s.CodecType, s.CodecName, container, containerTranscodeSuggestion[container][s.CodecType])
+	}
+	return
+}
+
+var textSubtitleCodecs = []string{"subrip", "ass", "ssa", "mov_text", "webvtt", "text"}
+
+// subtitleCopyArgs maps every subtitle track from the input into the output.  Mapping anything turns off
+// ffmpeg's automatic stream selection, so the first video track and the chosen audio track are mapped too.  If the
+// container can't hold the input's subtitle codecs as they are, text subs get converted to the container's
+// own format, and if any are bitmap subs (which can't be converted) they're all left out.
+func subtitleCopyArgs(o Output, in, out, audio string) (args []string) {
+	codec := "copy"
+	container := outputContainer(o, out)
+
+	probe, err := probeFile(in)
+	if err != nil {
+		log.Printf("warning: unable to check subtitle codecs, copying them as-is: %v\n", err)
+	}
+	for _, s := range probe.streamsOfType("subtitle") {
+		if containerSupports(container, "subtitle", s.CodecName) {
+			continue
+		}
+		if !stringInList(s.CodecName, textSubtitleCodecs) {
+			log.Printf("warning: subtitle stream #%d is %s, which can't go in a .%s file or be converted to text. Not copying subtitles\n", s.Index, s.CodecName, container)
+			return
+		}
+		codec = containerTranscodeSuggestion[container]["subtitle"]
+		log.Printf("subtitle stream #%d is %s, which can't be copied into a .%s file, converting to %s\n", s.Index, s.CodecName, container, codec)
+	}
+
+	args = append(args, []string{"-map", "0:v:0?"}...)
+	if audio != "" {
+		args = append(args, []string{"-map", audio + "?"}...)
+	}
+	args = append(args, []string{"-map", "0:s?", "-c:s", codec}...)
+	return
+}
+
+func stringInList(s string, list []string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"io"
+	"log"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestOutputContainer(t *testing.T) {
+	tests := []struct {
+		format string
+		out    string
+		want   string
+	}{
+		{"", "out.mp4", "mp4"},
+		{"", "OUT.M4V", "mp4"},
+		{"", "out.m2ts", "ts"},
+		{"", "out.mkv", "mkv"},
+		{"matroska", "out.mp4", "mkv"},
+		{"mpegts", "out", "ts"},
+		{"ipod", "out.m4a", "mp4"},
+		{"webm", "out.mkv", "webm"},
+	}
+	for _, tt := range tests {
+		if got := outputContainer(Output{Format: tt.format}, tt.out); got != tt.want {
+			t.Errorf("outputContainer(%q, %q) = %q, want %q", tt.format, tt.out, got, tt.want)
+		}
+	}
+}
+
+func TestContainerAudio(t *testing.T) {
+	tests := []struct {
+		audio     Audio
+		out       string
+		remuxing  bool
+		wantCodec string
+		wantErr   bool
+	}{
+		{Audio{}, "out.mp4", false, "", false},
+		{Audio{AudioCodec: "libopus"}, "out.webm", false, "libopus", false},
+		{Audio{AudioCodec: "flac"}, "out.mkv", false, "flac", false},
+		{Audio{AudioCodec: "libopus", AudioQuality: "5"}, "out.mp4", false, "aac", false},
+		{Audio{AudioCodec: "aac"}, "out.webm", false, "libopus", false},
+		{Audio{AudioCodec: "aac", IncompatibleAudio: "error"}, "out.webm", false, "", true},
+		{Audio{AudioCodec: "aac"}, "out.webm", true, "", true},
+		{Audio{AudioCodec: "aac", IncompatibleAudio: "transcode"}, "out.webm", true, "libopus", false},
+		{Audio{IncompatibleAudio: "drop"}, "out.mp4", false, "", true},
+	}
+	defer func(r bool) { *remux = r }(*remux)
+	for _, tt := range tests {
+		*remux = tt.remuxing
+		got, err := containerAudio(tt.audio, Output{}, "in.mkv", tt.out)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("containerAudio(%+v, %q) error = %v, want error %t", tt.audio, tt.out, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if got.AudioCodec != tt.wantCodec {
+			t.Errorf("containerAudio(%+v, %q) codec = %q, want %q", tt.audio, tt.out, got.AudioCodec, tt.wantCodec)
+		}
+		if got.AudioCodec != tt.audio.AudioCodec && got.AudioQuality != "" {
+			t.Errorf("containerAudio(%+v, %q) kept audioQuality %q for the new codec", tt.audio, tt.out, got.AudioQuality)
+		}
+	}
+}
+
+func TestCodecTagArgs(t *testing.T) {
+	fakeFfprobe(t, `echo '{"streams":[{"codec_type":"video","codec_name":"hevc"}]}'`)
+	tests := []struct {
+		video   Video
+		out     string
+		want    []string
+		wantErr bool
+	}{
+		{Video{Encoder: "libx265"}, "out.mp4", []string{"-tag:v", "hvc1"}, false},
+		{Video{Encoder: "hevc_nvenc"}, "out.mov", []string{"-tag:v", "hvc1"}, false},
+		{Video{Encoder: "libx265"}, "out.mkv", nil, false},
+		{Video{Encoder: "libx264"}, "out.mp4", nil, false},
+		{Video{JustCopy: true}, "out.mp4", []string{"-tag:v", "hvc1"}, false},
+		{Video{Encoder: "libx265", CodecTag: "hev1"}, "out.mp4", []string{"-tag:v", "hev1"}, false},
+		{Video{CodecTag: "avc1"}, "out.mkv", []string{"-tag:v", "avc1"}, false},
+		{Video{CodecTag: "hvc"}, "out.mp4", nil, true},
+		{Video{CodecTag: "hvc1!"}, "out.mp4", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := codecTagArgs(tt.video, Output{}, "in.mkv", tt.out)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("codecTagArgs(%+v, %q) error = %v, want error %t", tt.video, tt.out, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("codecTagArgs(%+v, %q) = %q, want %q", tt.video, tt.out, got, tt.want)
+		}
+	}
+}
+
+func TestCheckContainerVideo(t *testing.T) {
+	fakeFfprobe(t, `echo '{"streams":[{"codec_type":"video","codec_name":"hevc"}]}'`)
+	copyVideo := Video{JustCopy: true}
+	tests := []struct {
+		name     string
+		settings Settings
+		outs     []string
+		wantErr  bool
+	}{
+		{"encoding", Settings{Video: Video{Encoder: "libx264"}}, []string{"out.webm"}, false},
+		{"copy into mkv", Settings{Video: copyVideo}, []string{"out.mkv"}, false},
+		{"copy into mp4", Settings{Video: copyVideo}, []string{"out.mp4"}, false},
+		{"copy into webm", Settings{Video: copyVideo}, []string{"out.webm"}, true},
+		{"copy into matroska format", Settings{Video: copyVideo, Output: Output{Format: "matroska"}}, []string{"out.webm"}, false},
+		{"video disabled", Settings{Video: Video{JustCopy: true, DisableVideo: true}}, []string{"out.webm"}, false},
+		{"extra output", Settings{Outputs: []ExtraOutput{{Video: copyVideo}}}, []string{"out.mp4", "out_copy.webm"}, true},
+	}
+	for _, tt := range tests {
+		if err := checkContainerVideo(tt.settings, "in.mkv", tt.outs); (err != nil) != tt.wantErr {
+			t.Errorf("%s: checkContainerVideo = %v, want error %t", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestCheckRemuxCompatibility(t *testing.T) {
+	fakeFfprobe(t, `echo '{"streams":[{"codec_type":"video","codec_name":"h264"},{"codec_type":"audio","codec_name":"flac"}]}'`)
+	tests := []struct {
+		output Output
+		out    string
+		want   bool
+	}{
+		{Output{}, "out.mkv", true},
+		{Output{}, "out.mp4", false},
+		{Output{Format: "matroska"}, "out.mp4", true},
+		{Output{Format: "mp4"}, "-", false},
+		{Output{Format: "mpegts"}, "-", true},
+	}
+	for _, tt := range tests {
+		if got := checkRemuxCompatibility(tt.output, "in.mkv", tt.out); got != tt.want {
+			t.Errorf("checkRemuxCompatibility(%+v, %q) = %t, want %t", tt.output, tt.out, got, tt.want)
+		}
+	}
+}
+
+func TestSubtitleCopyArgs(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	text := `{"streams":[{"codec_type":"video","codec_name":"h264"},{"index":2,"codec_type":"subtitle","codec_name":"subrip"}]}`
+	bitmap := `{"streams":[{"codec_type":"video","codec_name":"h264"},{"index":2,"codec_type":"subtitle","codec_name":"subrip"},{"index":3,"codec_type":"subtitle","codec_name":"hdmv_pgs_subtitle"}]}`
+	tests := []struct {
+		name   string
+		probe  string
+		output Output
+		out    string
+		audio  string
+		want   []string
+	}{
+		{"text into mkv", text, Output{}, "out.mkv", "0:a:0", []string{"-map", "0:v:0?", "-map", "0:a:0?", "-map", "0:s?", "-c:s", "copy"}},
+		{"text into mp4", text, Output{}, "out.mp4", "0:a:1", []string{"-map", "0:v:0?", "-map", "0:a:1?", "-map", "0:s?", "-c:s", "mov_text"}},
+		{"text into mp4 format", text, Output{Format: "mp4"}, "out.mkv", "", []string{"-map", "0:v:0?", "-map", "0:s?", "-c:s", "mov_text"}},
+		{"bitmap into mkv", bitmap, Output{}, "out.mkv", "0:a:0", []string{"-map", "0:v:0?", "-map", "0:a:0?", "-map", "0:s?", "-c:s", "copy"}},
+		{"bitmap into mp4", bitmap, Output{}, "out.mp4", "0:a:0", nil},
+	}
+	for _, tt := range tests {
+		fakeFfprobe(t, "echo '"+tt.probe+"'")
+		if got := subtitleCopyArgs(tt.output, "in.mkv", tt.out, tt.audio); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: subtitleCopyArgs = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// coverArtContainers are the containers that can hold an attached picture
+var coverArtContainers = []string{"mp4", "mov", "m4a", "mp3", "flac", "mkv", "mka"}
+
+// coverArtInput is the input index output.coverArt is read from, it comes after the chapters if there are any
+func coverArtInput(o Output) int {
+	if o.ChaptersFile != "" {
+		return 2
+	}
+	return 1
+}
+
+// inputHasVideo reports whether in has a video track that's really video, not cover art of its own
+func inputHasVideo(in string) bool {
+	probe, err := probeFile(in)
+	if err != nil {
+		log.Printf("warning: unable to check %s for video, assuming it has some: %v\n", in, err)
+		return true
+	}
+	for _, s := range probe.streamsOfType("video") {
+		if s.Disposition["attached_pic"] == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// checkCoverArt catches a cover that can't be used before anything runs
+func checkCoverArt(settings Settings, out string) error {
+	cover := settings.Output.CoverArt
+	if _, err := os.Stat(cover); err != nil {
+		return fmt.Errorf("output.coverArt: %w", err)
+	}
+	if ext := strings.ToLower(filepath.Ext(cover)); ext != ".jpg" && ext != ".jpeg" && ext != ".png" {
+		return fmt.Errorf("output.coverArt %s should be a jpg or png, other images won't show up in most players", cover)
+	}
+	if container := outputContainer(settings.Output, out); !stringInList(container, coverArtContainers) {
+		return fmt.Errorf("output.coverArt can't go in a .%s file, it needs one of %s", container, strings.Join(coverArtContainers, ", "))
+	}
+	if len(settings.Time.Segments) > 0 {
+		return fmt.Errorf("output.coverArt can't be used with time.segments")
+	}
+	return nil
+}
+
+// coverArtArgs adds output.coverArt to an output's args as an attached picture.  The picture is a video
+// stream as far as ffmpeg is concerned, so the options meant for the real video are narrowed to the first
+// video track (-vf becomes -filter:v:0) and the picture is copied as it is.  Picking the picture's input
+// turns off ffmpeg's automatic stream selection, so the video and audio are mapped too if nothing else
+// mapped them.
+func coverArtArgs(settings Settings, in, out, audio string, args []string) ([]string, error) {
+	if err := checkCoverArt(settings, out); err != nil {
+		return nil, err
+	}
+
+	cover := 0
+	if !settings.Video.DisableVideo {
+		if !inputHasVideo(in) {
+			return nil, fmt.Errorf("%s has no video of its own, set video.disableVideo so output.coverArt is the only picture", in)
+		}
+		cover = 1
+	}
+
+	var withCover []string
+	for _, arg := range args {
+		switch {
+		case arg == "-vn":
+			//the cover is a video stream, -vn would leave it out too
+			continue
+		case cover == 1 && arg == "-vf":
+			arg = "-filter:v:0"
+		case cover == 1 && strings.HasPrefix(arg, "-") && strings.HasSuffix(arg, ":v"):
+			arg += ":0"
+		}
+		withCover = append(withCover, arg)
+	}
+
+	if !stringInList("-map", withCover) {
+		if cover == 1 {
+			withCover = append(withCover, []string{"-map", "0:v:0?"}...)
+		}
+		if audio != "" {
+			withCover = append(withCover, []string{"-map", audio + "?"}...)
+		}
+	}
+	return append(withCover, []string{
+		"-map", fmt.Sprintf("%d:v:0", coverArtInput(settings.Output)),
+		fmt.Sprintf("-c:v:%d", cover), "copy",
+		fmt.Sprintf("-disposition:v:%d", cover), "attached_pic",
+	}...), nil
+}
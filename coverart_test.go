@@ -0,0 +1,80 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCheckCoverArt(t *testing.T) {
+	dir := t.TempDir()
+	jpg := filepath.Join(dir, "cover.jpg")
+	webp := filepath.Join(dir, "cover.webp")
+	for _, f := range []string{jpg, webp} {
+		if err := os.WriteFile(f, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		name     string
+		settings Settings
+		out      string
+		wantErr  bool
+	}{
+		{"mp4", Settings{Output: Output{CoverArt: jpg}}, "out.mp4", false},
+		{"flac", Settings{Output: Output{CoverArt: jpg}}, "out.flac", false},
+		{"missing", Settings{Output: Output{CoverArt: filepath.Join(dir, "missing.jpg")}}, "out.mp4", true},
+		{"webp", Settings{Output: Output{CoverArt: webp}}, "out.mp4", true},
+		{"webm", Settings{Output: Output{CoverArt: jpg}}, "out.webm", true},
+		{"matroska format", Settings{Output: Output{CoverArt: jpg, Format: "matroska"}}, "out.webm", false},
+		{"segments", Settings{Output: Output{CoverArt: jpg}, Time: Time{Segments: []Segment{{Start: "0", End: "10"}}}}, "out.mp4", true},
+	}
+	for _, tt := range tests {
+		if err := checkCoverArt(tt.settings, tt.out); (err != nil) != tt.wantErr {
+			t.Errorf("%s: checkCoverArt = %v, want error %t", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestCoverArtArgs(t *testing.T) {
+	fakeFfprobe(t, `case "$7" in
+song.flac) echo '{"streams":[{"codec_type":"audio"},{"codec_type":"video","disposition":{"attached_pic":1}}]}' ;;
+*) echo '{"streams":[{"codec_type":"video"},{"codec_type":"audio"}]}' ;;
+esac`)
+	cover := filepath.Join(t.TempDir(), "cover.png")
+	if err := os.WriteFile(cover, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	video := Settings{Output: Output{CoverArt: cover}}
+	audioOnly := Settings{Video: Video{DisableVideo: true}, Output: Output{CoverArt: cover}}
+	chapters := Settings{Output: Output{CoverArt: cover, ChaptersFile: "chapters.txt"}}
+	tests := []struct {
+		name     string
+		settings Settings
+		in       string
+		args     []string
+		want     []string
+		wantErr  bool
+	}{
+		{"video", video, "in.mkv", []string{"-c:v", "libx264", "-vf", "scale=1280:720", "-c:a", "aac"},
+			[]string{"-c:v:0", "libx264", "-filter:v:0", "scale=1280:720", "-c:a", "aac", "-map", "0:v:0?", "-map", "0:a:0?", "-map", "1:v:0", "-c:v:1", "copy", "-disposition:v:1", "attached_pic"}, false},
+		{"audio only", audioOnly, "song.flac", []string{"-vn", "-c:a", "flac"},
+			[]string{"-c:a", "flac", "-map", "0:a:0?", "-map", "1:v:0", "-c:v:0", "copy", "-disposition:v:0", "attached_pic"}, false},
+		{"already mapped", video, "in.mkv", []string{"-map", "0:v", "-map", "0:a:1"},
+			[]string{"-map", "0:v", "-map", "0:a:1", "-map", "1:v:0", "-c:v:1", "copy", "-disposition:v:1", "attached_pic"}, false},
+		{"after chapters", chapters, "in.mkv", nil,
+			[]string{"-map", "0:v:0?", "-map", "0:a:0?", "-map", "2:v:0", "-c:v:1", "copy", "-disposition:v:1", "attached_pic"}, false},
+		{"no video of its own", video, "song.flac", nil, nil, true},
+	}
+	for _, tt := range tests {
+		got, err := coverArtArgs(tt.settings, tt.in, "out.mkv", "0:a:0", tt.args)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: coverArtArgs error = %v, want error %t", tt.name, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: coverArtArgs =\n%q\nwant\n%q", tt.name, got, tt.want)
+		}
+	}
+}
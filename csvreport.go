@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+var csvReportHeader = []string{"file", "status", "input bytes", "output bytes", "ratio", "seconds", "encoder"}
+
+// fileSize is the size of file, or -1 if it can't be read (like a skipped output that was never written)
+func fileSize(file string) int64 {
+	fh, err := os.Stat(file)
+	if err != nil {
+		return -1
+	}
+	return fh.Size()
+}
+
+// encoderUsed is what the video was encoded with, for reports
+func encoderUsed(v Video) string {
+	switch {
+	case v.DisableVideo:
+		return "none"
+	case v.JustCopy:
+		return "copy"
+	}
+	return videoEncoder(v)
+}
+
+// csvReportRow is one line of -csv-report.  Sizes that aren't known are left empty, and so is the ratio
+// (output size over input size) without both of them
+func csvReportRow(in, out, status string, settings Settings, elapsed time.Duration) []string {
+	inSize, outSize := fileSize(in), fileSize(out)
+	row := []string{in, status, "", "", "", fmt.Sprintf("%.1f", elapsed.Seconds()), encoderUsed(settings.Video)}
+	if inSize >= 0 {
+		row[2] = fmt.Sprintf("%d", inSize)
+	}
+	if outSize >= 0 && status != "failed" {
+		row[3] = fmt.Sprintf("%d", outSize)
+	}
+	if inSize > 0 && row[3] != "" {
+		row[4] = fmt.Sprintf("%.3f", float64(outSize)/float64(inSize))
+	}
+	return row
+}
+
+// appendCsvReport adds a row for one file to -csv-report, writing the header first if the report is new.
+// It's appended to so the rows from several runs end up in one spreadsheet.  Failing to write it
+// only gets a warning, the encode itself is what matters.
+func appendCsvReport(in, out, status string, settings Settings, elapsed time.Duration) {
+	if *csvReport == "" || *argsOnly {
+		return
+	}
+	f, err := os.OpenFile(*csvReport, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		log.Printf("warning: unable to open -csv-report %s: %v\n", *csvReport, err)
+		return
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	if fh, err := f.Stat(); err == nil && fh.Size() == 0 {
+		w.Write(csvReportHeader)
+	}
+	w.Write(csvReportRow(in, out, status, settings, elapsed))
+	w.Flush()
+	if err := w.Error(); err != nil {
+		log.Printf("warning: unable to write to -csv-report %s: %v\n", *csvReport, err)
+	}
+}
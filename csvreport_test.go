@@ -0,0 +1,39 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCsvReportRow(t *testing.T) {
+	dir := t.TempDir()
+	in, out := filepath.Join(dir, "in.mkv"), filepath.Join(dir, "out.mkv")
+	if err := os.WriteFile(in, make([]byte, 1000), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(out, make([]byte, 250), 0644); err != nil {
+		t.Fatal(err)
+	}
+	x264 := Settings{Video: Video{Encoder: "libx264"}}
+	tests := []struct {
+		out      string
+		status   string
+		settings Settings
+		want     []string
+	}{
+		{out, "ok", x264, []string{in, "ok", "1000", "250", "0.250", "90.5", "libx264"}},
+		{out, "failed", x264, []string{in, "failed", "1000", "", "", "90.5", "libx264"}},
+		{filepath.Join(dir, "missing.mkv"), "skipped", x264, []string{in, "skipped", "1000", "", "", "90.5", "libx264"}},
+		{out, "ok", Settings{Video: Video{JustCopy: true}}, []string{in, "ok", "1000", "250", "0.250", "90.5", "copy"}},
+		{out, "ok", Settings{Video: Video{DisableVideo: true, JustCopy: true}}, []string{in, "ok", "1000", "250", "0.250", "90.5", "none"}},
+	}
+	for _, tt := range tests {
+		got := csvReportRow(in, tt.out, tt.status, tt.settings, 90500*time.Millisecond)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("csvReportRow(%s, %s) = %v, want %v", tt.out, tt.status, got, tt.want)
+		}
+	}
+}
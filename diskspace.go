@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// checkDiskSpace fails before encoding if the output's volume doesn't have room for it.  The space needed
+// is -min-free if it's set, otherwise a rough estimate with 10% to spare: the bitrate times the duration for
+// cbr video, or else the input's size (cut down to time.totalTime), once per output.  -min-free 0 skips it.
+func checkDiskSpace(settings Settings, in string, outs []string) error {
+	if *minFree == "0" || isStdout(outs[0]) {
+		return nil
+	}
+
+	var needed int64
+	if *minFree != "" {
+		n, err := parseSize(*minFree, 1024)
+		if err != nil {
+			return fmt.Errorf("bad -min-free: %v", err)
+		}
+		needed = n
+	} else {
+		needed = estimateOutputSize(settings, in) * int64(len(outs)) * 11 / 10
+		if needed == 0 {
+			return nil
+		}
+	}
+
+	free, err := freeSpace(filepath.Dir(outs[0]))
+	if err != nil {
+		//not knowing isn't a reason to stop
+		return nil
+	}
+	if free < needed {
+		return fmt.Errorf("not enough disk space for %s: about %s needed, %s free.  Free some up, or set -min-free (0 skips this check)", outs[0], formatSize(needed), formatSize(free))
+	}
+	return nil
+}
+
+// estimateOutputSize guesses how big one output will be, 0 if there's nothing to go on
+func estimateOutputSize(settings Settings, in string) int64 {
+	if isURL(in) {
+		return 0
+	}
+	fh, err := os.Stat(in)
+	if err != nil {
+		return 0
+	}
+
+	probe, probeErr := probeFile(in)
+	inDuration := probe.duration()
+	v := settings.Video
+	if probeErr == nil && inDuration > 0 && v.Mode == "cbr" && v.VideoBitrate != "" && !v.JustCopy {
+		videoBits, err := parseSize(v.VideoBitrate, 1000)
+		audioBits, _ := parseSize(settings.Audio.AudioBitrate, 1000)
+		if settings.Audio.AudioBitrate == "" {
+			audioBits = 192000
+		}
+		if err == nil {
+			return int64(float64(videoBits+audioBits) / 8 * expectedDurationOf(settings, inDuration).Seconds())
+		}
+	}
+
+	size := fh.Size()
+	if probeErr == nil && inDuration > 0 && settings.Time.TotalTime != 0 {
+		size = int64(float64(size) * expectedDurationOf(settings, inDuration).Seconds() / inDuration.Seconds())
+	}
+	return size
+}
+
+// expectedDurationOf is how much of an input inDuration long gets encoded, going by the time settings
+func expectedDurationOf(settings Settings, inDuration time.Duration) time.Duration {
+	d := inDuration - time.Duration(settings.Time.TimeSkipIntro)*time.Second
+	if settings.Time.TotalTime != 0 && time.Duration(settings.Time.TotalTime)*time.Second < d {
+		d = time.Duration(settings.Time.TotalTime) * time.Second
+	}
+	return d
+}
+
+// parseSize reads sizes like 500M, 2G or 2000k, with each suffix step being base times the last
+func parseSize(s string, base int64) (n int64, err error) {
+	s = strings.TrimSpace(s)
+	multiplier := int64(1)
+	if i := strings.IndexAny(strings.ToUpper(s), "KMGT"); i == len(s)-1 && i > 0 {
+		for _, unit := range "KMGT" {
+			multiplier *= base
+			if rune(strings.ToUpper(s)[i]) == unit {
+				break
+			}
+		}
+		s = s[:i]
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || f < 0 {
+		return 0, fmt.Errorf("%q should be a size like 500M or 2G", s)
+	}
+	return int64(f * float64(multiplier)), nil
+}
+
+func formatSize(n int64) string {
+	units := []string{"B", "KiB", "MiB", "GiB", "TiB"}
+	size := float64(n)
+	i := 0
+	for size >= 1024 && i < len(units)-1 {
+		size /= 1024
+		i++
+	}
+	return fmt.Sprintf("%.1f %s", size, units[i])
+}
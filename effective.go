@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// effectiveSettingsPath is where -effective-settings writes what a run used: ep1.mkv gets ep1.mkv.effective.json
+func effectiveSettingsPath(out string) string {
+	if isStdout(out) {
+		return "ffmpegfront-stdout.effective.json"
+	}
+	return out + ".effective.json"
+}
+
+// effectiveSettings fills in what settings left to ffmpegfront or ffmpeg with what the run actually used: the
+// encoders, the default audio bitrate and the output's real resolution.  Measurements that aren't settings, like
+// the loudnorm analysis pass, are recorded in ready.notes.  They're measured again from the input on every run,
+// so they're a record of this run rather than something to set.
+func effectiveSettings(settings Settings, in, out string) Settings {
+	v, a := &settings.Video, &settings.Audio
+	var notes []string
+
+	if !v.DisableVideo && !v.JustCopy {
+		v.Encoder = videoEncoder(*v)
+		probe, err := probeFile(out)
+		if err != nil {
+			notes = append(notes, fmt.Sprintf("The output's resolution couldn't be checked: %v", err))
+		} else if streams := probe.streamsOfType("video"); len(streams) > 0 && streams[0].Width > 0 {
+			v.Resolution = fmt.Sprintf("%d:%d", streams[0].Width, streams[0].Height)
+		}
+	}
+
+	if !a.DisableAudio && !a.JustCopy {
+		if a.AudioCodec == "" {
+			a.AudioCodec = "aac"
+		}
+		a.AudioCodec = aacEncoder(a.AudioCodec, a.PreferFdkAac)
+		a.PreferFdkAac = false
+		if a.AudioBitrate == "" && a.AudioQuality == "" {
+			a.AudioBitrate = "192k"
+		}
+
+		stream := audioStream(*a, in)
+		if a.AudioLanguage != "" {
+			notes = append(notes, fmt.Sprintf("audio.audioLanguage %q picked audio stream %s", a.AudioLanguage, stream))
+		}
+		if albumGainSet {
+			notes = append(notes, fmt.Sprintf("-album-gain applied %+.2f dB, worked out from every file in the batch", albumGainDb))
+		} else if ln, ok := cachedLoudnorm(in, stream); ok && a.AudioFilter == "loudnorm" && a.Loudnorm2Pass {
+			notes = append(notes, fmt.Sprintf("The loudnorm analysis pass measured input_i=%s input_tp=%s input_lra=%s input_thresh=%s target_offset=%s.  These are measured again on every run",
+				ln.InputI, ln.InputTp, ln.InputLra, ln.InputThresh, ln.TargetOffset))
+		}
+	}
+
+	settings.Ready.Completed = false
+	notes = append([]string{fmt.Sprintf("The settings used to make %s from %s", out, in)}, notes...)
+	settings.Ready.Notes = strings.Join(notes, ".  ")
+	return settings
+}
+
+// writeEffectiveSettings is -effective-settings, it returns the file it wrote.  written is the file to check the
+// output's resolution on, which is the first piece when the output is split.
+func writeEffectiveSettings(settings Settings, in, out, written string) string {
+	file := effectiveSettingsPath(out)
+	writeJson(effectiveSettings(settings, in, written), file)
+	return file
+}
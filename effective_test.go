@@ -0,0 +1,53 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEffectiveSettings(t *testing.T) {
+	copyVideo := Video{JustCopy: true}
+	cacheLoudnorm("effective.mkv", "0:a:0", loudnormValues{InputI: "-23.0", InputTp: "-4.1", InputLra: "6.0", InputThresh: "-33.5", TargetOffset: "0.3"})
+	tests := []struct {
+		name      string
+		settings  Settings
+		albumGain bool
+		check     func(s Settings) bool
+		notes     []string
+	}{
+		{"audio defaults", Settings{Video: copyVideo, Ready: Ready{Completed: true}},
+			false, func(s Settings) bool {
+				return s.Audio.AudioCodec == "aac" && s.Audio.AudioBitrate == "192k" && !s.Ready.Completed && s.Video.JustCopy && s.Video.Encoder == ""
+			}, []string{"The settings used to make out.mkv from effective.mkv"}},
+		{"quality instead of bitrate", Settings{Video: copyVideo, Audio: Audio{AudioCodec: "libvorbis", AudioQuality: "5"}},
+			false, func(s Settings) bool { return s.Audio.AudioCodec == "libvorbis" && s.Audio.AudioBitrate == "" }, nil},
+		{"copied audio", Settings{Video: copyVideo, Audio: Audio{JustCopy: true}},
+			false, func(s Settings) bool { return s.Audio.AudioCodec == "" && s.Audio.AudioBitrate == "" }, nil},
+		{"loudnorm measurement", Settings{Video: copyVideo, Audio: Audio{AudioFilter: "loudnorm", Loudnorm2Pass: true}},
+			false, func(s Settings) bool { return true }, []string{"input_i=-23.0 input_tp=-4.1 input_lra=6.0 input_thresh=-33.5 target_offset=0.3"}},
+		{"album gain", Settings{Video: copyVideo, Audio: Audio{AudioFilter: "loudnorm", Loudnorm2Pass: true}},
+			true, func(s Settings) bool { return !strings.Contains(s.Ready.Notes, "input_i") }, []string{"-album-gain applied -2.50 dB"}},
+	}
+	defer func() { albumGainSet, albumGainDb = false, 0 }()
+	for _, tt := range tests {
+		albumGainSet, albumGainDb = tt.albumGain, -2.5
+		got := effectiveSettings(tt.settings, "effective.mkv", "out.mkv")
+		if !tt.check(got) {
+			t.Errorf("%s: effectiveSettings = %+v", tt.name, got)
+		}
+		for _, note := range tt.notes {
+			if !strings.Contains(got.Ready.Notes, note) {
+				t.Errorf("%s: notes %q don't have %q", tt.name, got.Ready.Notes, note)
+			}
+		}
+	}
+}
+
+func TestEffectiveSettingsPath(t *testing.T) {
+	if got := effectiveSettingsPath("/media/ep1.mkv"); got != "/media/ep1.mkv.effective.json" {
+		t.Errorf("effectiveSettingsPath = %s", got)
+	}
+	if got := effectiveSettingsPath("-"); got != "ffmpegfront-stdout.effective.json" {
+		t.Errorf("effectiveSettingsPath(-) = %s", got)
+	}
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// encodeEnv is the environment for the ffmpeg encode: this process's own with settings.env added on
+// top, so a setting like CUDA_VISIBLE_DEVICES=1 or LIBVA_DRIVER_NAME=iHD picks the gpu for that encode.
+// exec uses the last of any duplicated variable, so env wins over what's already set.
+func encodeEnv(env []string) (merged []string, err error) {
+	for _, e := range env {
+		kv := strings.SplitN(e, "=", 2)
+		if len(kv) != 2 || kv[0] == "" || strings.ContainsAny(kv[0], " \t") {
+			return nil, fmt.Errorf("env %q should be NAME=value", e)
+		}
+	}
+	if len(env) == 0 {
+		return nil, nil
+	}
+	return append(os.Environ(), env...), nil
+}
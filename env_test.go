@@ -0,0 +1,59 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEncodeEnv(t *testing.T) {
+	tests := []struct {
+		env     []string
+		wantErr bool
+	}{
+		{nil, false},
+		{[]string{"CUDA_VISIBLE_DEVICES=1"}, false},
+		{[]string{"LIBVA_DRIVER_NAME=iHD", "EMPTY="}, false},
+		{[]string{"OPTS=a=b"}, false},
+		{[]string{"CUDA_VISIBLE_DEVICES"}, true},
+		{[]string{"=1"}, true},
+		{[]string{"BAD NAME=1"}, true},
+		{[]string{"GOOD=1", "bad"}, true},
+	}
+	for _, tt := range tests {
+		got, err := encodeEnv(tt.env)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("encodeEnv(%q) error = %v, want error %t", tt.env, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		var want []string
+		if len(tt.env) > 0 {
+			want = append(os.Environ(), tt.env...)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("encodeEnv(%q) = %q, want %q", tt.env, got, want)
+		}
+	}
+}
+
+func TestEncodeEnvOverrides(t *testing.T) {
+	t.Setenv("FFMPEGFRONT_TEST_GPU", "0")
+	env, err := encodeEnv([]string{"FFMPEGFRONT_TEST_GPU=1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command("sh", "-c", "echo $FFMPEGFRONT_TEST_GPU")
+	cmd.Env = env
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(string(output)); got != "1" {
+		t.Errorf("FFMPEGFRONT_TEST_GPU in the encode's environment = %q, want 1", got)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// SettingsError is a settings file (or batch sidecar) that can't be read or parsed
+type SettingsError struct {
+	File string
+	Err  error
+}
+
+func (e *SettingsError) Error() string { return fmt.Sprintf("settings file %s: %v", e.File, e.Err) }
+func (e *SettingsError) Unwrap() error { return e.Err }
+
+// ProbeError is ffprobe failing on a file, or returning something that can't be parsed
+type ProbeError struct {
+	File string
+	Err  error
+}
+
+func (e *ProbeError) Error() string { return fmt.Sprintf("unable to probe %s: %v", e.File, e.Err) }
+func (e *ProbeError) Unwrap() error { return e.Err }
+
+// EncodeError is processFile failing for an input, Stage says how far it got: checking the input,
+// preparing the encode, the encode itself or moving the output into place
+type EncodeError struct {
+	Stage string
+	File  string
+	Err   error
+}
+
+func (e *EncodeError) Error() string { return e.Err.Error() }
+func (e *EncodeError) Unwrap() error { return e.Err }
+
+// Stages of processFile, for EncodeError
+const (
+	stageInput   = "checking the input"
+	stagePrepare = "preparing the encode"
+	stageEncode  = "encoding"
+	stageVerify  = "verifying the output"
+	stageOutput  = "writing the output"
+)
+
+// stageError wraps err as an EncodeError for stage of processing in.  nil stays nil.
+func stageError(stage, in string, err error) error {
+	if err == nil {
+		return nil
+	}
+	var encodeErr *EncodeError
+	if errors.As(err, &encodeErr) {
+		return err
+	}
+	return &EncodeError{Stage: stage, File: in, Err: err}
+}
+
+// describeError is how main reports an error: which stage failed and for which file, when that's known
+func describeError(err error) string {
+	var encodeErr *EncodeError
+	var probeErr *ProbeError
+	var settingsErr *SettingsError
+	switch {
+	case errors.As(err, &encodeErr):
+		if errors.As(encodeErr.Err, &probeErr) {
+			return fmt.Sprintf("%s failed while %s, probing: %v", encodeErr.File, encodeErr.Stage, probeErr.Err)
+		}
+		return fmt.Sprintf("%s failed while %s: %v", encodeErr.File, encodeErr.Stage, encodeErr.Err)
+	case errors.As(err, &probeErr):
+		return fmt.Sprintf("%s failed while probing: %v", probeErr.File, probeErr.Err)
+	case errors.As(err, &settingsErr):
+		return fmt.Sprintf("%s failed while loading settings: %v", settingsErr.File, settingsErr.Err)
+	}
+	return err.Error()
+}
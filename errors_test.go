@@ -0,0 +1,66 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestStageError(t *testing.T) {
+	if err := stageError(stageEncode, "in.mkv", nil); err != nil {
+		t.Errorf("stageError(nil) = %v, want nil", err)
+	}
+
+	cause := errors.New("boom")
+	err := stageError(stagePrepare, "in.mkv", cause)
+	var encodeErr *EncodeError
+	if !errors.As(err, &encodeErr) || encodeErr.Stage != stagePrepare || encodeErr.File != "in.mkv" || !errors.Is(err, cause) {
+		t.Errorf("stageError = %#v, want an EncodeError for preparing in.mkv wrapping boom", err)
+	}
+
+	//an error that already has its stage keeps it
+	again := stageError(stageEncode, "in.mkv", fmt.Errorf("outputs[1]: %w", err))
+	if !errors.As(again, &encodeErr) || encodeErr.Stage != stagePrepare {
+		t.Errorf("stageError of an EncodeError changed its stage to %q", encodeErr.Stage)
+	}
+}
+
+func TestDescribeError(t *testing.T) {
+	probeErr := &ProbeError{File: "in.mkv", Err: errors.New("invalid data")}
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{errors.New("plain"), "plain"},
+		{&EncodeError{Stage: stageEncode, File: "in.mkv", Err: errors.New("exit status 1")}, "in.mkv failed while encoding: exit status 1"},
+		{&EncodeError{Stage: stageInput, File: "in.mkv", Err: probeErr}, "in.mkv failed while checking the input, probing: invalid data"},
+		{probeErr, "in.mkv failed while probing: invalid data"},
+		{&SettingsError{File: "s.json", Err: errors.New("unexpected end of JSON input")}, "s.json failed while loading settings: unexpected end of JSON input"},
+		{fmt.Errorf("wrapped: %w", &SettingsError{File: "s.json", Err: errors.New("bad")}), "s.json failed while loading settings: bad"},
+	}
+	for _, tt := range tests {
+		if got := describeError(tt.err); got != tt.want {
+			t.Errorf("describeError(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestResolutionMap(t *testing.T) {
+	tests := []struct {
+		res     string
+		want    string
+		wantErr bool
+	}{
+		{"480p", "640:480", false},
+		{"720p", "1280:720", false},
+		{"1080p", "1920:1080", false},
+		{"4k", "3840:2160", false},
+		{"1440p", "", true},
+	}
+	for _, tt := range tests {
+		got, err := resolutionMap(tt.res)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("resolutionMap(%s) = %q, %v, want %q, error %t", tt.res, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
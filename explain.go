@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// noValueOptions are the options ffmpegfront generates that don't take a value
+var noValueOptions = []string{"-hide_banner", "-y", "-nostdin", "-vn", "-an"}
+
+// streamName describes a stream specifier: v is the video, a:1 is audio track 1, and none is every stream
+func streamName(spec string) string {
+	spec = strings.TrimPrefix(spec, "s:")
+	kind, index, _ := strings.Cut(spec, ":")
+	names := map[string]string{"v": "video", "V": "video", "a": "audio", "s": "subtitles"}
+	name, ok := names[kind]
+	if !ok {
+		return "every stream"
+	}
+	if index != "" {
+		return fmt.Sprintf("%s track %s", name, index)
+	}
+	return name
+}
+
+// argExplanations say what each option ffmpegfront generates does, keyed by the option without its stream
+// specifier.  They get the stream the option is for (see streamName) and its value.
+var argExplanations = map[string]func(stream, value string) string{
+	"-hide_banner": func(_, _ string) string { return "don't print ffmpeg's build information" },
+	"-y":           func(_, _ string) string { return "overwrite the output if it's already there" },
+	"-nostdin":     func(_, _ string) string { return "don't read keypresses from the terminal" },
+	"-vn":          func(_, _ string) string { return "leave the video out of this output" },
+	"-an":          func(_, _ string) string { return "leave the audio out of this output" },
+	"-i":           func(_, v string) string { return "read from " + v },
+	"-t":           func(_, v string) string { return fmt.Sprintf("stop after %s seconds", v) },
+	"-c": func(s, v string) string {
+		if v == "copy" {
+			return fmt.Sprintf("copy the %s as it is, without re-encoding", s)
+		}
+		return fmt.Sprintf("encode the %s with %s", s, v)
+	},
+	"-b":       func(s, v string) string { return fmt.Sprintf("%s bitrate of %s", s, v) },
+	"-maxrate": func(_, v string) string { return "cap the video bitrate at " + v },
+	"-bufsize": func(_, v string) string {
+		return fmt.Sprintf("rate control buffer of %s, how much the bitrate can vary over", v)
+	},
+	"-crf": func(_, v string) string {
+		return fmt.Sprintf("constant quality %s, lower is better looking and bigger", v)
+	},
+	"-cq": func(_, v string) string {
+		return fmt.Sprintf("nvenc constant quality %s, lower is better looking and bigger", v)
+	},
+	"-qp": func(_, v string) string {
+		return fmt.Sprintf("constant quantizer %s, lower is better looking and bigger", v)
+	},
+	"-rc":       func(_, v string) string { return "nvenc rate control mode " + v },
+	"-rc_mode":  func(_, v string) string { return "vaapi rate control mode " + v },
+	"-quality":  func(_, v string) string { return "encoder speed against quality setting " + v },
+	"-lossless": func(_, v string) string { return "lossless mode " + v },
+	"-tune":     func(_, v string) string { return fmt.Sprintf("tune the encoder for %s content", v) },
+	"-threads":  func(_, v string) string { return fmt.Sprintf("use at most %s threads", v) },
+	"-profile": func(s, v string) string {
+		return fmt.Sprintf("%s profile %s, which decides what players can decode it", s, v)
+	},
+	"-level": func(s, v string) string {
+		return fmt.Sprintf("%s level %s, a cap for devices that can't decode above it", s, v)
+	},
+	"-bf":     func(_, v string) string { return fmt.Sprintf("at most %s b-frames in a row", v) },
+	"-refs":   func(_, v string) string { return fmt.Sprintf("%s reference frames", v) },
+	"-vf":     func(_, v string) string { return "video filters, applied in order: " + v },
+	"-af":     func(_, v string) string { return "audio filters, applied in order: " + v },
+	"-filter": func(s, v string) string { return fmt.Sprintf("%s filters, applied in order: %s", s, v) },
+	"-q":      func(s, v string) string { return fmt.Sprintf("%s vbr quality %s", s, v) },
+	"-vbr":    func(_, v string) string { return "libfdk_aac vbr quality " + v },
+	"-ac":     func(_, v string) string { return fmt.Sprintf("mix the audio to %s channels", v) },
+	"-ar":     func(_, v string) string { return fmt.Sprintf("resample the audio to %s Hz", v) },
+	"-pass": func(_, v string) string {
+		if v == "1" {
+			return "first of two passes, only analyses the video for the second"
+		}
+		return "second of two passes, encodes using what the first one found"
+	},
+	"-passlogfile": func(_, _ string) string { return "where the first pass leaves its stats for the second" },
+	"-x265-params": func(_, v string) string { return "libx265's own options " + v },
+	"-vsync": func(_, v string) string {
+		return fmt.Sprintf("frame timing %s, cfr repeats or drops frames to keep a constant frame rate", v)
+	},
+	"-tag": func(s, v string) string {
+		return fmt.Sprintf("tag the %s as %s, the code players go by to pick a decoder", s, v)
+	},
+	"-bsf": func(s, v string) string {
+		return fmt.Sprintf("rewrite the %s packets without re-encoding them, with %s", s, v)
+	},
+	"-aspect": func(_, v string) string { return "flag the display aspect ratio as " + v },
+	"-map": func(_, v string) string {
+		return fmt.Sprintf("put input stream %s in the output (a ? means only if it's there)", v)
+	},
+	"-metadata": func(s, v string) string {
+		return fmt.Sprintf("tag %s with %s", strings.Replace(s, "every stream", "the file", 1), v)
+	},
+	"-frames":   func(s, v string) string { return fmt.Sprintf("stop after %s %s frames", v, s) },
+	"-f":        func(_, v string) string { return "write the output as " + v },
+	"-movflags": func(_, v string) string { return "mp4 muxer options " + v },
+	"-muxrate":  func(_, v string) string { return fmt.Sprintf("pad the mpegts stream out to a constant %s", v) },
+	"-hwaccel":  func(_, v string) string { return "decode on the gpu with " + v },
+	"-colorspace": func(_, v string) string {
+		return "tag the video's colour matrix as " + v
+	},
+	"-color_primaries":        func(_, v string) string { return "tag the video's colour primaries as " + v },
+	"-color_trc":              func(_, v string) string { return "tag the video's transfer characteristics as " + v },
+	"-hwaccel_output_format":  func(_, v string) string { return fmt.Sprintf("keep decoded frames in %s gpu memory", v) },
+	"-pcr_period":             func(_, v string) string { return fmt.Sprintf("write the mpegts clock every %s ms", v) },
+	"-map_chapters":           func(_, v string) string { return fmt.Sprintf("take the chapters from input %s", v) },
+	"-filter_complex":         func(_, v string) string { return "filter graph that cuts and joins the streams: " + v },
+	"-force_key_frames":       func(_, v string) string { return "force keyframes at " + v },
+	"-segment_time":           func(_, v string) string { return fmt.Sprintf("start a new piece every %s seconds", v) },
+	"-segment_format":         func(_, v string) string { return fmt.Sprintf("write each piece as %s", v) },
+	"-segment_format_options": func(_, v string) string { return "muxer options for each piece: " + v },
+	"-reset_timestamps":       func(_, _ string) string { return "start every piece's timestamps at 0 so each plays on its own" },
+	"-analyzeduration": func(_, v string) string {
+		return fmt.Sprintf("read %s microseconds of the input to find its streams", v)
+	},
+	"-probesize": func(_, v string) string { return fmt.Sprintf("read %s bytes of the input to find its streams", v) },
+	"-disposition": func(s, v string) string {
+		if v == "0" {
+			return fmt.Sprintf("clear the default flag on every %s track", s)
+		}
+		if v == "attached_pic" {
+			return fmt.Sprintf("mark %s as cover art rather than something to play", s)
+		}
+		return fmt.Sprintf("make %s the one players pick by default", s)
+	},
+}
+
+// explainArgs describes args one option at a time, for -explain.  -ss depends on where it is: before -i
+// it's a quick seek by keyframe, after it the input is decoded up to that point.  Anything ffmpegfront
+// didn't generate itself, like input.args, is listed as passed through.
+func explainArgs(args []string) (lines []string) {
+	seenInput := false
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "-" || !strings.HasPrefix(arg, "-") {
+			lines = append(lines, fmt.Sprintf("%s: write the output here", arg))
+			continue
+		}
+
+		value := ""
+		if !stringInList(arg, noValueOptions) && i+1 < len(args) {
+			i++
+			value = args[i]
+		}
+		option, spec, _ := strings.Cut(arg, ":")
+		group := arg
+		if value != "" {
+			group += " " + shellQuote(value)
+		}
+
+		var explanation string
+		switch describe, ok := argExplanations[option]; {
+		case option == "-ss" && !seenInput:
+			explanation = fmt.Sprintf("jump to %s seconds in before decoding, quick but lands on the keyframe before it", value)
+		case option == "-ss":
+			explanation = fmt.Sprintf("decode and throw away the first %s seconds, slow but exact", value)
+		case ok:
+			explanation = describe(streamName(spec), value)
+		default:
+			explanation = "not one of ffmpegfront's own, passed to ffmpeg as it is"
+		}
+		if option == "-i" {
+			seenInput = true
+		}
+		lines = append(lines, fmt.Sprintf("%s: %s", group, explanation))
+	}
+	return
+}
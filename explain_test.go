@@ -0,0 +1,47 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStreamName(t *testing.T) {
+	tests := []struct {
+		spec string
+		want string
+	}{
+		{"", "every stream"},
+		{"v", "video"},
+		{"V", "video"},
+		{"a", "audio"},
+		{"a:1", "audio track 1"},
+		{"s:a:0", "audio track 0"},
+		{"s", "subtitles"},
+		{"s:s:2", "subtitles track 2"},
+		{"0", "every stream"},
+	}
+	for _, tt := range tests {
+		if got := streamName(tt.spec); got != tt.want {
+			t.Errorf("streamName(%q) = %q, want %q", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestExplainArgs(t *testing.T) {
+	args := []string{"-hide_banner", "-y", "-ss", "10", "-i", "in file.mkv", "-ss", "2", "-c:v", "libx264", "-c:a", "copy", "-metadata:s:a:0", "language=eng", "-foo", "bar", "out.mp4"}
+	want := []string{
+		"-hide_banner: don't print ffmpeg's build information",
+		"-y: overwrite the output if it's already there",
+		"-ss 10: jump to 10 seconds in before decoding, quick but lands on the keyframe before it",
+		"-i 'in file.mkv': read from in file.mkv",
+		"-ss 2: decode and throw away the first 2 seconds, slow but exact",
+		"-c:v libx264: encode the video with libx264",
+		"-c:a copy: copy the audio as it is, without re-encoding",
+		"-metadata:s:a:0 language=eng: tag audio track 0 with language=eng",
+		"-foo bar: not one of ffmpegfront's own, passed to ffmpeg as it is",
+		"out.mp4: write the output here",
+	}
+	if got := explainArgs(args); !reflect.DeepEqual(got, want) {
+		t.Errorf("explainArgs(%q) =\n%q\nwant\n%q", args, got, want)
+	}
+}
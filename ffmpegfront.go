@@ -3,26 +3,96 @@ package main
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"os"
 	"os/exec"
 	"path"
+	"path/filepath"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
 var templateType = flag.String("make-template", "", "Write a template file: template, movie, tv-normal, tv-high are options")
+var templateFormat = flag.String("template-format", "json", "Format make-template writes: json or yaml (which can have comments)")
+var jsonSchema = flag.Bool("json-schema", false, "Print a JSON Schema for the settings file, for editors and other tools to check settings against")
+var interactive = flag.Bool("interactive", false, "Ask for the common settings on the terminal instead of reading a settings file, and optionally save them")
+var listPresets = flag.Bool("list-presets", false, "List the templates make-template can write, with what each is for")
+var selfTestFlag = flag.Bool("selftest", false, "Check ffmpeg, ffprobe and ffmpegfront work together by encoding a generated 2 second clip, reports PASS or FAIL")
 var argsOnly = flag.Bool("args-only", false, "Output the arguments instead of executing ffmpeg with them.")
 var inFile = flag.String("infile", "", "File to process with ffmpeg")
-var outFile = flag.String("outfile", "", "File to write output to")
-var settingsFile = flag.String("settings", "", "settings json file to read.")
+var outFile = flag.String("outfile", "", "File to write output to, or - to write it to stdout (needs output.format)")
+var settingsFile = flag.String("settings", "", "settings json file to read.  .yaml or .yml files are read as YAML")
 var logFile = flag.String("logfile", "", "log file to write to")
+var inDir = flag.String("indir", "", "Directory to batch process, or a glob matching several directories (ex: './Season */')")
+var outDir = flag.String("outdir", "", "Directory to write batch output to.  The layout under -indir is mirrored here")
+var recursive = flag.Bool("recursive", false, "Walk subdirectories of -indir in batch mode")
+var includeExt = flag.String("include-ext", "mkv,mp4,m4v,avi,mov,ts,webm", "Comma separated extensions to process in batch mode, or * for every file.  Files that turn out not to be audio or video are skipped either way")
+var excludeExt = flag.String("exclude-ext", "", "Comma separated extensions to skip in batch mode, checked after -include-ext")
+var outExt = flag.String("outext", "", "Extension for batch output files, ex: mp4.  Defaults to the input file's extension")
+var outName = flag.String("outname", "", "Name batch output files with a template, ex: {basename}_{resolution}_{codec}.mp4.  Placeholders are {basename}, {ext}, {resolution}, {codec} and {date}.  Outputs that would land on the same file get _2, _3 added")
+var overwrite = flag.Bool("overwrite", false, "In batch mode, re-process files whose output already exists")
+var statsPeriod = flag.Duration("stats-period", 0, "How often to log a progress line with percent done and ETA, ex: 30s.  0 disables them")
+var statsJson = flag.String("stats-json", "", "Append a json line to this file (or fd:N for an open file descriptor) for every progress update: file, frame, fps, speed, time, percent and eta.  Each file's last line has done set, for guis to tail")
+var mkOutDir = flag.Bool("mkoutdir", false, "Create the outfile's directory if it doesn't exist")
+var loudnormReport = flag.Bool("loudnorm-report", false, "After a loudnorm encode, measure the output and write a measured vs target report next to it")
+var loudnormTolerance = flag.Float64("loudnorm-tolerance", 1.0, "How far off target (in LU/dB) the loudnorm report allows before it says fail.  The run still succeeds, the report is only a check")
+var ffprobePath = flag.String("ffprobe-path", "", "ffprobe executable to use.  Defaults to $FFPROBE_PATH, then the ffprobe next to ffmpeg, then the one in PATH, then /usr/bin/ffprobe")
+var ffmpegPath = flag.String("ffmpeg-path", "", "ffmpeg executable to use.  Defaults to the one in PATH, then /usr/bin/ffmpeg")
+var resume = flag.Bool("resume", false, "Skip the run if the settings file has ready.completed set, and set it once the run succeeds")
+var force = flag.Bool("force", false, "Run even if -resume would skip it, or the output is the input or in a -protect directory")
+var protectDirs = flag.String("protect", "", "Comma separated directories outputs must never be written into, ex: your originals")
+var validate = flag.Bool("validate", false, "Check that the generated filters parse by running them on a dummy source before the real encode")
+var keepPartial = flag.Bool("keep-partial", false, "Keep the .part output of a failed encode instead of deleting it")
+var printCommand = flag.Bool("print-command", false, "Print the ffmpeg command (quoted so it can be pasted into a shell) and how long it took, while still running it")
+var chmodFlag = flag.String("chmod", "", "File mode to set on the output and log files, ex: 0644")
+var chownFlag = flag.String("chown", "", "Numeric uid:gid to give the output and log files, ex: 1000:1000.  Needs root")
+var benchmark = flag.Bool("benchmark", false, "Report the average encoding fps and speed (as a multiple of realtime) when the encode finishes")
+var benchmarkDuration = flag.Duration("benchmark-duration", 0, "With -benchmark, only encode this much of the input for a quick comparison, ex: 60s")
+var presetDir = flag.String("preset-dir", defaultPresetDir(), "Directory of user preset json files for -profile")
+var profile = flag.String("profile", "", "Name of a preset to use instead of -settings: <preset-dir>/<name>.json, or one of the built-in templates")
+var strictMode = flag.Bool("strict", false, "Fail the encode if ffmpeg's output has warnings about corrupt input or decode errors, even if ffmpeg itself succeeded")
+var strictExtraPatterns = flag.String("strict-patterns", "", "Comma separated extra regexes (case insensitive) that -strict treats as failures")
+var niceLevel = flag.Int("nice", 0, "Run ffmpeg at this niceness (1-19, higher is lower priority) so a long batch doesn't hog the machine.  Unix only")
+var ioniceIdle = flag.Bool("ionice-idle", false, "Run ffmpeg in the idle io class, so it only uses the disk when nothing else is.  Linux only")
+var minFree = flag.String("min-free", "", "Free space the output's disk needs before encoding starts, ex: 20G.  Defaults to an estimate of the output size, 0 turns the check off")
+var embedSettings = flag.Bool("embed-settings", false, "Tag the output with the settings used to make it, so ffprobe shows how it was made")
+var skipIfMatching = flag.String("skip-if-matching", "", "remux or skip.  Copy the video or audio instead of encoding it when the input is already the target codec (and size and bitrate), and with skip, don't output anything if both already match")
+var tmpDir = flag.String("tmpdir", os.TempDir(), "Directory for intermediate files, ffmpeg's included.  Point it at a roomy disk if /tmp is a small tmpfs")
+var logFfmpegOutput = flag.Bool("log-ffmpeg-output", false, "Write ffmpeg's output to the log even when it succeeds, not just when it fails")
+var logFfmpegOutputMax = flag.String("log-ffmpeg-output-max", "256K", "How much of the end of ffmpeg's output -log-ffmpeg-output keeps for a successful encode, 0 keeps all of it")
+var animatedPreviewFlag = flag.Bool("animated-preview", false, "Make a short animated preview of -infile instead of encoding it, -outfile ending in .gif or .webp")
+var previewStart = flag.Duration("preview-start", time.Minute, "Where in the input -animated-preview starts, ex: 90s")
+var previewDuration = flag.Duration("preview-duration", 3*time.Second, "How long -animated-preview is")
+var previewFps = flag.Int("preview-fps", 10, "Frame rate of -animated-preview")
+var previewWidth = flag.Int("preview-width", 480, "Width of -animated-preview, the height keeps the input's aspect ratio")
+var csvReport = flag.String("csv-report", "", "Append a row per processed file to this csv: file, status, sizes, ratio, time taken and encoder")
+var fileTimeout = flag.Duration("file-timeout", 0, "Kill an encode that's still going this long after its file started, ex: 2h.  In batch mode the rest of the files still get processed.  The loudnorm analysis pass isn't cut short, but counts towards it")
+var measureFirst = flag.Bool("measure-first", false, "In batch mode, run every file's loudnorm analysis pass before starting any encodes")
+var albumGainFlag = flag.Bool("album-gain", false, "In batch mode, measure the loudness of every file and apply the one gain that brings them all together to -16 LUFS, instead of loudnorming each file.  Quiet episodes stay quieter than loud ones")
+var measureJobs = flag.Int("measure-jobs", 2, "How many loudnorm analysis passes -measure-first runs at once")
+var deepVerifyFlag = flag.Bool("deep-verify", false, "After encoding, decode the whole output and fail if there are any decode errors.  Takes about as long again as decoding the input")
+var notifyFlag = flag.String("notify", "", "When the run finishes, POST the result as json to this http(s) url, or run this shell command with the result in FFMPEGFRONT_STATUS, FFMPEGFRONT_EXIT_CODE and other FFMPEGFRONT_ variables")
+var extractSubtitlesFlag = flag.String("extract-subtitles", "", "srt, vtt, ass or copy.  Instead of encoding, write every subtitle track of the input(s) to files named after the output, ex: ep1.eng.srt.  Doesn't need settings")
+var logMaxSize = flag.String("log-max-size", "0", "Rotate a log file once it gets this big, ex: 10M.  The full one is kept as .log.1, replacing the one before.  0 lets logs grow forever")
+var sampleClip = flag.Bool("sample-clip", false, "Encode only a short sample with the full settings, to outfile.sample.ext, to try settings out before a long encode")
+var sampleAt = flag.Duration("sample-at", 0, "Where -sample-clip's sample starts, ex: 45m.  Defaults to the middle of the input")
+var sampleLength = flag.Duration("sample-length", 30*time.Second, "How long -sample-clip's sample is")
+var explain = flag.Bool("explain", false, "Like -args-only, but also says what each argument does")
+var effectiveSettingsFlag = flag.Bool("effective-settings", false, "After a successful encode, write the settings as they were actually used, with encoders, resolution and loudnorm measurements filled in, to outfile.effective.json")
+var printStreamsFlag = flag.Bool("print-streams", false, "List -infile's tracks (index, output.maps specifier, type, codec, language, channels, resolution and title) and exit")
+var confirmOverwriteFlag = flag.Bool("confirm-overwrite", false, "Ask before overwriting an output that's already there.  Only when there's a terminal to ask on, otherwise ready.noOverwrite decides as usual")
+var remux = flag.Bool("remux", false, "Copy the audio and video streams into the outfile's container without re-encoding.  Settings are optional in this mode.")
 
-func resolutionMap(res string) (fullRes string) {
+func resolutionMap(res string) (fullRes string, err error) {
 	resolutions := map[string]string{
 		"480p":  "640:480",
 		"720p":  "1280:720",
@@ -34,244 +104,2161 @@ func resolutionMap(res string) (fullRes string) {
 		fullRes = resolutions[res]
 		return
 	}
-	log.Printf("%s is not a preprogramed resolution. Please enter it as w:h in the 'resolution' field.  ex: 'resolution': '1280:720'\n", res)
-	os.Exit(1)
-	return ""
+	err = fmt.Errorf("%s is not a preprogramed resolution. Please enter it as w:h in the 'resolution' field.  ex: 'resolution': '1280:720'", res)
+	return
+}
+
+func main() {
+	flag.Parse()
+
+	if *explain {
+		*argsOnly = true
+	}
+
+	if *listPresets {
+		listTemplates()
+		os.Exit(0)
+	}
+
+	if *jsonSchema {
+		printSchema()
+		os.Exit(0)
+	}
+
+	if *templateType != "" {
+		if *templateFormat != "json" && *templateFormat != "yaml" {
+			fatalf("-template-format should be json or yaml, not %q\n", *templateFormat)
+		}
+		templateJson := makeTemplate(*templateType)
+		writeJson(templateJson, "template."+*templateFormat)
+		os.Exit(0)
+	}
+
+	if *printStreamsFlag {
+		if *inFile == "" {
+			fatalf("-print-streams needs -infile")
+		}
+		printStreams(*inFile)
+	}
+
+	if *selfTestFlag {
+		resolveFfmpeg()
+		if err := setupTempDir(); err != nil {
+			fatalf("%v\n", err)
+		}
+		selfTest()
+	}
+
+	if *animatedPreviewFlag {
+		if *inFile == "" || *outFile == "" {
+			fatalf("-animated-preview needs -infile and -outfile")
+		}
+		resolveFfmpeg()
+		if err := setupTempDir(); err != nil {
+			fatalf("%v\n", err)
+		}
+		animatedPreview(*inFile, *outFile)
+		removeTempDir()
+		os.Exit(0)
+	}
+
+	singleFile := *inFile != "" && *outFile != ""
+	if *extractSubtitlesFlag != "" {
+		runExtractSubtitles(singleFile)
+	}
+	batch := *inDir != "" && *outDir != ""
+	if !(singleFile || batch) || (*settingsFile == "" && *profile == "" && !*remux && !*interactive) {
+		fatalf("Need the following flags to be used:\n\t-infile [file to process]\n\t-outfile [output target]\n\t-settings [settings json or yaml to use, or -profile [preset name], or -interactive to be asked, optional with -remux]\n\nOr, for batch mode, -indir and -outdir in place of -infile and -outfile\n\nOr, call with the make-template flag for it to spit out a template JSON to fill in, or -selftest to check ffmpeg is working")
+	}
+
+	resolveFfmpeg()
+	if err := setupTempDir(); err != nil {
+		fatalf("%v\n", err)
+	}
+	defer removeTempDir()
+
+	var settings Settings
+	if *settingsFile != "" {
+		settings = parseSettingsJson(*settingsFile)
+	} else if *profile != "" {
+		settings = loadPreset(*profile)
+	} else if *interactive {
+		settings = interactiveSettings()
+	}
+	if *remux {
+		settings.Video.JustCopy = true
+		settings.Audio.JustCopy = true
+	}
+	if settings.Video.HwAccel != "" {
+		checkHwAccel(settings.Video.HwAccel)
+	}
+	if settings.Subtitles.BurnInSubtitles && !settings.Video.JustCopy && settings.Subtitles.FontsDir == "" && !haveFontconfig() {
+		log.Printf("warning: fontconfig doesn't look to be set up, burned in subtitles may come out blank. Install fontconfig and some fonts, or set subtitles.fontsDir\n")
+	}
+	if len(settings.Time.Segments) > 0 && (settings.Video.JustCopy || settings.Audio.JustCopy || len(settings.Outputs) > 0) {
+		fatalf("time.segments can't be used with justCopy (cutting and joining needs a re-encode) or extra outputs\n")
+	}
+	if settings.Video.DisableVideo && settings.Audio.DisableAudio {
+		fatalf("video.disableVideo and audio.disableAudio are both set, there would be nothing to output\n")
+	}
+	for _, w := range ignoredSettings(settings) {
+		log.Printf("warning: %s\n", w)
+	}
+
+	if *skipIfMatching != "" && *skipIfMatching != "remux" && *skipIfMatching != "skip" {
+		fatalf("-skip-if-matching should be remux or skip, not %q\n", *skipIfMatching)
+	}
+
+	if *strictMode {
+		if _, err := strictPatterns(); err != nil {
+			fatalf("%v\n", err)
+		}
+	}
+
+	if err := openStatsFd(*statsJson); err != nil {
+		fatalf("%v\n", err)
+	}
+
+	if _, err := parseSize(*logFfmpegOutputMax, 1024); err != nil {
+		fatalf("-log-ffmpeg-output-max: %v\n", err)
+	}
+	if _, err := parseSize(*logMaxSize, 1024); err != nil {
+		fatalf("-log-max-size: %v\n", err)
+	}
+
+	if err := checkMovflags(settings.Output, *outFile); err != nil {
+		fatalf("%v\n", err)
+	}
+	if err := checkSplit(settings.Output, *outFile); err != nil {
+		fatalf("%v\n", err)
+	}
+	if err := checkHdrMode(settings.Video.HdrMode); err != nil {
+		fatalf("%v\n", err)
+	}
+	if err := checkMaps(settings); err != nil {
+		fatalf("%v\n", err)
+	}
+	if *sampleClip {
+		if err := checkSampleClip(*outFile); err != nil {
+			fatalf("%v\n", err)
+		}
+	}
+
+	if isStdout(*outFile) {
+		switch {
+		case settings.Output.Format == "":
+			fatalf("-outfile - needs output.format set, there's no file extension to pick the container from\n")
+		case len(settings.Outputs) > 0:
+			fatalf("-outfile - can't be used with extra outputs, they're named after the outfile\n")
+		case *printCommand || *benchmark:
+			fatalf("-print-command and -benchmark print to stdout, which -outfile - is using for the output\n")
+		case *loudnormReport:
+			log.Printf("warning: -loudnorm-report needs an output file to measure, not writing one for -outfile -\n")
+			*loudnormReport = false
+		}
+	}
+
+	if *benchmark && *benchmarkDuration > 0 {
+		settings.Time.TotalTime = int(benchmarkDuration.Seconds())
+	}
+
+	if *resume && settings.Ready.Completed && !*force {
+		log.Printf("%s is marked completed, skipping.  Use -force to run it anyway\n", *settingsFile)
+		removeTempDir()
+		os.Exit(0)
+	}
+
+	if batch {
+		if *albumGainFlag && (settings.Audio.JustCopy || settings.Audio.DisableAudio) {
+			fatalf("-album-gain changes the audio's volume, it can't be used with audio.justCopy or audio.disableAudio")
+		}
+		if *outName != "" {
+			if err := checkOutName(*outName); err != nil {
+				fatalf("%v\n", err)
+			}
+		}
+		runBatch(settings)
+	} else {
+		if *albumGainFlag {
+			fatalf("-album-gain evens out the loudness of a batch, it needs -indir and -outdir")
+		}
+		startTime := time.Now()
+		err := processFile(settings, *inFile, *outFile)
+		if errors.Is(err, errNotOverwriting) || errors.Is(err, errAlreadyMatches) {
+			log.Printf("skipping %s, %v\n", *inFile, err)
+			appendCsvReport(*inFile, *outFile, "skipped", settings, 0)
+			notifyRun(runResult{Input: *inFile, Output: *outFile, Skipped: 1})
+			//not overwriting isn't a failure, but it isn't done either, so -resume asks again next time
+			if errors.Is(err, errNotOverwriting) {
+				return
+			}
+		} else if err != nil {
+			log.Println(describeError(err))
+			status := "failed"
+			if errors.Is(err, errTimedOut) {
+				status = "timed out"
+			}
+			appendCsvReport(*inFile, *outFile, status, settings, time.Since(startTime))
+			result := runResult{ExitCode: 1, Input: *inFile, Output: *outFile, Failed: 1, Error: describeError(err)}
+			if errors.Is(err, errTimedOut) {
+				result.Failed, result.TimedOut = 0, 1
+			}
+			exitRun(result)
+		} else {
+			appendCsvReport(*inFile, *outFile, "ok", settings, time.Since(startTime))
+			notifyRun(runResult{Input: *inFile, Output: *outFile, Processed: 1})
+		}
+	}
+
+	if *resume && *settingsFile != "" && !*sampleClip && !*argsOnly {
+		markCompleted(*settingsFile)
+	}
+}
+
+// markCompleted rewrites the settings file with ready.completed set, so -resume skips it next time
+func markCompleted(file string) {
+	if isYamlFile(file) {
+		markYamlCompleted(file)
+		return
+	}
+	settings := parseSettingsJson(file)
+	settings.Ready.Completed = true
+	writeJson(settings, file)
+}
+
+// processFile runs the whole pipeline for one input/output pair, logging to that output's log file
+func processFile(settings Settings, in, out string) (err error) {
+	ctx, cancel := fileContext()
+	defer cancel()
+
+	if *sampleClip {
+		settings, out = sampleSettings(settings, in, out)
+	}
+
+	//errors are returned as EncodeErrors saying how far processing got, the caller reports them
+	err = stageError(stageInput, in, checkInput(in))
+	if err != nil {
+		return
+	}
+
+	//-args-only is a dry run, it leaves the disk alone
+	if !*argsOnly {
+		err = stageError(stagePrepare, in, ensureOutputDir(out))
+		if err != nil {
+			return
+		}
+	}
+
+	env, err := encodeEnv(settings.Env)
+	if err != nil {
+		err = stageError(stagePrepare, in, err)
+		return
+	}
+
+	if *skipIfMatching != "" || settings.Video.CopyIfMatching || settings.Audio.CopyIfMatching {
+		var skip bool
+		settings, skip = matchInput(settings, in)
+		if skip {
+			err = errAlreadyMatches
+			return
+		}
+	}
+
+	if settings.Subtitles.BurnInSubtitles && !settings.Video.JustCopy && !settings.Video.DisableVideo {
+		err = checkSubtitleSources(settings.Subtitles, in)
+		if err != nil {
+			err = stageError(stageInput, in, err)
+			return
+		}
+	}
+
+	if isLiveURL(in) && settings.Audio.Loudnorm2Pass && settings.Audio.AudioFilter == "loudnorm" {
+		log.Printf("warning: %s is a live stream, the first loudnorm pass would never finish. Using single pass loudnorm\n", in)
+		settings.Audio.Loudnorm2Pass = false
+	}
+
+	if settings.Output.ChaptersFile != "" {
+		var cleanup func()
+		settings.Output, cleanup, err = prepareChapters(settings.Output, in, out)
+		if err != nil {
+			err = stageError(stagePrepare, in, err)
+			return
+		}
+		defer cleanup()
+	}
+
+	var sidecars []subtitleSidecar
+	if settings.Subtitles.WebvttSidecar {
+		switch {
+		case isStdout(out):
+			log.Printf("warning: subtitles.webvttSidecar is ignored when writing to stdout, there's no file to put the .vtt next to\n")
+		case len(settings.Time.Segments) > 0:
+			log.Printf("warning: subtitles.webvttSidecar is ignored with time.segments, the cues wouldn't line up with the joined output\n")
+		default:
+			sidecars, err = subtitleSidecars(in, out, "vtt")
+			if err != nil {
+				log.Printf("warning: not writing webvtt subtitles: %v\n", err)
+				err = nil
+			}
+		}
+	}
+
+	var logOut io.Writer = io.Discard
+	if !*argsOnly {
+		f, err := os.OpenFile(getLogFilePath(out), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0666)
+		if err != nil {
+			log.Println(err)
+		}
+		maxLog, _ := parseSize(*logMaxSize, 1024)
+		logFile := newCappedLog(f, getLogFilePath(out), maxLog)
+		defer logFile.Close()
+		logOut = logFile
+	}
+	console := log.Default()
+	log := log.New(logOut, "ffmpegfront", log.LstdFlags)
+
+	log.Printf("loaded settings: %v", settings)
+
+	if settings.Video.JustCopy && settings.Audio.JustCopy {
+		if !checkRemuxCompatibility(settings.Output, in, out) {
+			log.Printf("some streams are not compatible with the %s container, see warnings", outputContainer(settings.Output, out))
+		}
+	}
+
+	//ffmpeg writes to .part files that only get renamed to the real outputs once it finishes cleanly, so an
+	//interrupted encode can't be mistaken for a finished one
+	extras, err := extraOutputPaths(settings, out)
+	if err != nil {
+		log.Println(err)
+		err = stageError(stagePrepare, in, err)
+		return
+	}
+	outs := append([]string{out}, extras...)
+
+	err = checkContainerVideo(settings, in, outs)
+	if err != nil {
+		log.Println(err)
+		err = stageError(stagePrepare, in, err)
+		return
+	}
+	if !*force {
+		err = checkOutputPaths(in, outs)
+		if err != nil {
+			log.Println(err)
+			err = stageError(stagePrepare, in, err)
+			return
+		}
+	}
+	var partials []string
+	for _, o := range outs {
+		if settings.Ready.NoOverwrite && !isStdout(o) {
+			if _, statErr := os.Stat(o); statErr == nil {
+				err = fmt.Errorf("%s already exists and ready.noOverwrite is set", o)
+				log.Println(err)
+				err = stageError(stagePrepare, in, err)
+				return
+			}
+		}
+		partials = append(partials, partialPath(o))
+	}
+	//noOverwrite has already stopped the run if any of them are there
+	if *confirmOverwriteFlag && !*argsOnly && !settings.Ready.NoOverwrite && stdinIsTerminal() {
+		if existing := existingOutputs(outs); len(existing) > 0 && !confirmOverwrite(stdinReader, os.Stderr, existing) {
+			err = fmt.Errorf("%w %s", errNotOverwriting, strings.Join(existing, ", "))
+			log.Printf("%v, skipping %s", err, in)
+			return
+		}
+	}
+
+	var passLog string
+	if settings.Video.TargetSize != "" {
+		settings, err = targetSizeSettings(settings, in)
+		if err != nil {
+			log.Println(err)
+			err = stageError(stagePrepare, in, err)
+			return
+		}
+		if *argsOnly {
+			passLog = argsOnlyPassLog()
+		} else {
+			var cleanup func()
+			passLog, cleanup, err = newPassLog()
+			if err != nil {
+				log.Println(err)
+				err = stageError(stagePrepare, in, err)
+				return
+			}
+			defer cleanup()
+		}
+	}
+
+	if *argsOnly {
+		//nothing gets written, so show the real outputs instead of the .part files
+		var args, firstPass []string
+		args, err = buildArgs(log, settings, in, outs)
+		if err == nil && passLog != "" {
+			firstPass, err = firstPassArgs(log, settings, in, passLog)
+		}
+		if err != nil {
+			log.Println(err)
+			err = stageError(stagePrepare, in, err)
+			return
+		}
+		if passLog != "" {
+			fmt.Println(commandLine(ffmpegBin, firstPass))
+			args = withPassArgs(args, settings.Video, 2, passLog)
+		}
+		fmt.Println(commandLine(ffmpegBin, args))
+		if *explain {
+			for _, line := range explainArgs(args) {
+				fmt.Printf("  %s\n", line)
+			}
+		}
+		for _, s := range sidecars {
+			fmt.Println(commandLine(ffmpegBin, subtitleSidecarArgs(settings.Time, in, s)))
+		}
+		return
+	}
+
+	err = checkDiskSpace(settings, in, outs)
+	if err != nil {
+		log.Println(err)
+		err = stageError(stagePrepare, in, err)
+		return
+	}
+
+	args, err := buildArgs(log, settings, in, partials)
+	if err != nil {
+		log.Println(err)
+		err = stageError(stagePrepare, in, err)
+		return
+	}
+	if passLog != "" {
+		args = withPassArgs(args, settings.Video, 2, passLog)
+	}
+
+	if *validate {
+		err = validateFilters(args)
+		if err != nil {
+			log.Printf("filter validation failed, not encoding: %v", err)
+			err = stageError(stagePrepare, in, fmt.Errorf("filter validation failed, not encoding: %w", err))
+			return
+		}
+		log.Printf("filters validated")
+	}
+
+	if passLog != "" {
+		err = runFirstPass(ctx, log, settings, in, passLog, env)
+		if err != nil {
+			log.Println(err)
+			err = stageError(stageEncode, in, err)
+			return
+		}
+	}
+
+	if *printCommand {
+		//pasted into a shell it should write the outputs themselves, nothing renames the .part files there
+		fmt.Println(commandLine(ffmpegBin, withFinalPaths(args, partials, outs)))
+	}
+
+	log.Printf("executing with these arguments: %v", args)
+	cmd := exec.CommandContext(ctx, ffmpegBin, args...)
+	cmd.Env = env
+
+	var total time.Duration
+	if *statsPeriod > 0 || *statsJson != "" {
+		total = expectedDuration(settings, in)
+	}
+	progress := newProgressWriter(*statsPeriod, func(p progressStats) {
+		log.Printf("%s: %s", in, p.String(total))
+		console.Printf("%s: %s", in, p.String(total))
+	})
+	var stats *statsJsonWriter
+	if *statsJson != "" {
+		stats, err = newStatsJsonWriter(*statsJson, in, total)
+		if err != nil {
+			log.Printf("warning: %v", err)
+			console.Printf("warning: %v", err)
+			err = nil
+		} else {
+			defer stats.Close()
+			progress.onStatus = stats.progress
+		}
+	}
+	//ffmpeg's messages and progress all go to stderr, stdout is only the output itself when it's piped
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = progress
+	if isStdout(out) {
+		cmd.Stdout = os.Stdout
+	}
+
+	startTime := time.Now()
+	err = timeoutError(ctx, runLowPriority(cmd))
+	log.Printf("finished with exit status: %v", err)
+	if err == nil && *strictMode {
+		//checked in main, so this can't fail
+		regexes, _ := strictPatterns()
+		if problems := strictProblems(progress.Output.String(), regexes); len(problems) > 0 {
+			for _, p := range problems {
+				log.Printf("strict: %s", p)
+			}
+			err = fmt.Errorf("ffmpeg exited cleanly but reported %d problems, failing because of -strict.  The first one: %s", len(problems), problems[0])
+		}
+	}
+	if stats != nil {
+		stats.finish(progress.Latest, err)
+	}
+	if err != nil {
+		log.Printf("output: %s", progress.Output.String())
+	} else if *logFfmpegOutput {
+		max, _ := parseSize(*logFfmpegOutputMax, 1024)
+		log.Printf("output: %s", outputTail(progress.Output.String(), max))
+	} else {
+		//a clean exit can still have said something worth keeping
+		for _, line := range stderrNotes(progress.Output.String()) {
+			log.Printf("ffmpeg: %s", line)
+		}
+	}
+	if stdout.Len() > 0 {
+		log.Printf("stdout: %s", stdout.String())
+	}
+	duration := time.Since(startTime)
+	log.Printf("Time elapsed: %s\n", duration)
+	if *printCommand {
+		fmt.Printf("finished in %s: %v\n", duration.Round(time.Millisecond), exitStatus(err))
+	}
+	if *benchmark && err == nil {
+		b := progress.Latest
+		fmt.Printf("benchmark %s: %d frames in %s, %.1f fps, %.2fx realtime\n", in, b.Frame, duration.Round(time.Millisecond), b.Fps, b.Speed)
+		log.Printf("benchmark: %d frames in %s, %.1f fps, %.2fx realtime", b.Frame, duration, b.Fps, b.Speed)
+	}
+
+	if isSplit(settings.Output) {
+		partials, outs = expandSplit(partials, outs)
+	}
+
+	//the .part files are checked, so an output that fails never gets its real name
+	if err == nil && *deepVerifyFlag && !isStdout(out) {
+		for _, partial := range partials {
+			if verifyErr := deepVerify(ctx, partial); verifyErr != nil {
+				log.Printf("deep verify failed: %v", verifyErr)
+				err = stageError(stageVerify, in, timeoutError(ctx, verifyErr))
+				break
+			}
+		}
+		if err == nil {
+			log.Printf("deep verify passed")
+		}
+	}
+
+	if err != nil {
+		if !*keepPartial {
+			for _, partial := range partials {
+				if !isStdout(partial) {
+					os.Remove(partial)
+				}
+			}
+		}
+		err = stageError(stageEncode, in, err)
+		return
+	}
+	for i, partial := range partials {
+		if isStdout(partial) {
+			continue
+		}
+		err = os.Rename(partial, outs[i])
+		if err != nil {
+			err = fmt.Errorf("unable to move %s to %s: %w", partial, outs[i], err)
+			log.Println(err)
+			err = stageError(stageOutput, in, err)
+			return
+		}
+	}
+
+	written := []string{getLogFilePath(out)}
+	if !isStdout(out) {
+		written = append(written, outs...)
+	}
+	if len(sidecars) > 0 {
+		vtts := writeSubtitleSidecars(settings.Time, in, sidecars)
+		log.Printf("wrote webvtt subtitles: %v", vtts)
+		written = append(written, vtts...)
+	}
+	if *loudnormReport && isSplit(settings.Output) {
+		log.Printf("not writing a loudnorm report, the output is split into pieces")
+	} else if *loudnormReport && !settings.Audio.JustCopy && settings.Audio.AudioFilter == "loudnorm" {
+		reportFile := fmt.Sprintf("%s.loudnorm.txt", out)
+		pass, reportErr := writeLoudnormReport(reportFile, in, audioStream(settings.Audio, in), out)
+		if reportErr != nil {
+			log.Printf("warning: not writing the loudnorm report: %v", reportErr)
+			console.Printf("warning: not writing the loudnorm report: %v", reportErr)
+		} else {
+			log.Printf("wrote loudnorm report to %s, pass: %t", reportFile, pass)
+			if !pass {
+				console.Printf("warning: %s is further off the loudnorm targets than -loudnorm-tolerance, see %s", out, reportFile)
+			}
+			written = append(written, reportFile)
+		}
+	}
+	if *effectiveSettingsFlag {
+		file := writeEffectiveSettings(settings, in, out, outs[0])
+		log.Printf("wrote the effective settings to %s", file)
+		written = append(written, file)
+	}
+
+	for _, w := range setOwnership(written) {
+		log.Printf("warning: %s", w)
+		console.Printf("warning: %s", w)
+	}
+	return
+}
+
+// setOwnership applies -chmod and -chown to the files a run wrote.  Failures (like chown when not
+// running as root) aren't fatal since the encode itself worked, they're returned as warnings.
+func setOwnership(files []string) (warnings []string) {
+	var mode uint64
+	var uid, gid int
+	var err error
+
+	if *chmodFlag != "" {
+		mode, err = strconv.ParseUint(*chmodFlag, 8, 32)
+		if err != nil {
+			return []string{fmt.Sprintf("-chmod %s is not an octal file mode, ex: 0644", *chmodFlag)}
+		}
+	}
+	if *chownFlag != "" {
+		uid, gid, err = parseOwner(*chownFlag)
+		if err != nil {
+			return []string{err.Error()}
+		}
+	}
+
+	for _, f := range files {
+		if *chmodFlag != "" {
+			if err := os.Chmod(f, os.FileMode(mode)); err != nil {
+				warnings = append(warnings, fmt.Sprintf("unable to chmod %s: %v", f, err))
+			}
+		}
+		if *chownFlag != "" {
+			if err := os.Chown(f, uid, gid); err != nil {
+				warnings = append(warnings, fmt.Sprintf("unable to chown %s (chown usually needs root): %v", f, err))
+			}
+		}
+	}
+	return
+}
+
+// parseOwner reads uid:gid, or just uid to leave the group alone
+func parseOwner(owner string) (uid, gid int, err error) {
+	parts := strings.SplitN(owner, ":", 2)
+	uid, err = strconv.Atoi(parts[0])
+	if err != nil {
+		err = fmt.Errorf("-chown %s should be a numeric uid:gid, ex: 1000:1000", owner)
+		return
+	}
+	gid = -1
+	if len(parts) == 2 {
+		gid, err = strconv.Atoi(parts[1])
+		if err != nil {
+			err = fmt.Errorf("-chown %s should be a numeric uid:gid, ex: 1000:1000", owner)
+		}
+	}
+	return
+}
+
+// outputTail keeps the last max bytes of ffmpeg's output, where the summary and any late errors are.
+// Long encodes print a status line a second, which adds up.  max 0 keeps everything
+func outputTail(output string, max int64) string {
+	if max <= 0 || int64(len(output)) <= max {
+		return output
+	}
+	cut := int64(len(output)) - max
+	return fmt.Sprintf("[%s cut]...%s", formatSize(cut), output[cut:])
+}
+
+// commandLine renders bin and args as a command that can be pasted into a shell
+func commandLine(bin string, args []string) string {
+	quoted := []string{shellQuote(bin)}
+	for _, a := range args {
+		quoted = append(quoted, shellQuote(a))
+	}
+	return strings.Join(quoted, " ")
+}
+
+var shellSafe = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+
+func shellQuote(s string) string {
+	if shellSafe.MatchString(s) {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func exitStatus(err error) string {
+	if err == nil {
+		return "exit status 0"
+	}
+	return err.Error()
+}
+
+// validateFilters runs the video and audio filters from args over a generated null source, which fails in
+// a second or two if the filter string is broken instead of after however long the real encode takes
+func validateFilters(args []string) error {
+	for i := 0; i < len(args)-1; i++ {
+		var check []string
+		switch args[i] {
+		case "-vf":
+			//the null source is in system memory, so there's nothing for hwdownload to download
+			filter := strings.TrimPrefix(args[i+1], "hwdownload,format=nv12,")
+			check = []string{"-hide_banner", "-f", "lavfi", "-i", "nullsrc", "-vf", filter, "-frames:v", "1", "-f", "null", "-"}
+		case "-filter:a":
+			check = []string{"-hide_banner", "-f", "lavfi", "-i", "anullsrc", "-af", args[i+1], "-t", "1", "-f", "null", "-"}
+		default:
+			continue
+		}
+
+		output, err := exec.Command(ffmpegBin, check...).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("%s %q: %v\n%s", args[i], args[i+1], err, output)
+		}
+	}
+	return nil
+}
+
+// ignoredSettings lists the settings that are set but won't do anything because of some other setting
+func ignoredSettings(settings Settings) (warnings []string) {
+	v, a, s := settings.Video, settings.Audio, settings.Subtitles
+
+	if v.DisableVideo {
+		why := "video.disableVideo leaves video out of the output"
+		if v.JustCopy {
+			warnings = append(warnings, fmt.Sprintf("video.justCopy is ignored: %s", why))
+		}
+		if s.BurnInSubtitles {
+			warnings = append(warnings, fmt.Sprintf("subtitles.burnInSubtitles is ignored: %s", why))
+		}
+	}
+	if len(settings.Time.Segments) > 0 {
+		why := "time.segments decides what gets cut"
+		if settings.Time.TimeSkipIntro != 0 || settings.Time.TotalTime != 0 {
+			warnings = append(warnings, fmt.Sprintf("time.timeSkipIntro and time.totalTime still apply on top of the segments, which is probably not what you want: %s", why))
+		}
+		if s.CopySubtitles {
+			warnings = append(warnings, fmt.Sprintf("subtitles.copySubtitles is ignored: %s and subtitles can't be cut with it", why))
+		}
+		if settings.Output.ChaptersFile != "" {
+			warnings = append(warnings, fmt.Sprintf("output.chaptersFile times are from the uncut input and won't line up: %s", why))
+		}
+	}
+	if len(s.Burns) > 0 {
+		if !s.BurnInSubtitles {
+			warnings = append(warnings, "subtitles.burns is ignored: subtitles.burnInSubtitles isn't set")
+		} else if s.SubtitleFile != "" || s.SubtitleTrack != 0 || s.SubtitleStyle != "" || s.SubtitleStylePreset != "" || s.SubtitleAlignment != 0 || s.SubtitleMarginV != 0 {
+			warnings = append(warnings, "subtitles.subtitleFile, subtitleTrack and the style settings are ignored: subtitles.burns has its own for each set of subtitles")
+		}
+	}
+	if len(settings.Output.Maps) > 0 && a.AudioLanguage != "" {
+		warnings = append(warnings, fmt.Sprintf("audio.audioLanguage %q doesn't pick the audio track: output.maps decides which streams go in", a.AudioLanguage))
+	}
+	if a.TrimSilence != "" && !a.JustCopy && !a.DisableAudio && !v.DisableVideo {
+		warnings = append(warnings, "audio.trimSilence only trims the audio, the video isn't cut to match so they'll be out of sync.  It's meant for audio only output, set video.disableVideo")
+	}
+	if a.DisableAudio && (a.JustCopy || a.AudioFilter != "") {
+		warnings = append(warnings, "audio.justCopy and audio.audioFilter are ignored: audio.disableAudio leaves audio out of the output")
+	}
+
+	if v.TargetSize != "" && (v.Mode != "" || v.VideoBitrate != "") {
+		warnings = append(warnings, "video.mode and video.videoBitrate are ignored: video.targetSize picks the bitrate")
+	}
+
+	if v.JustCopy && !v.DisableVideo {
+		why := "video.justCopy copies the video stream without decoding it"
+		if v.Resolution != "" {
+			warnings = append(warnings, fmt.Sprintf("video.resolution %q is ignored: %s", v.Resolution, why))
+		}
+		if s.BurnInSubtitles {
+			warnings = append(warnings, fmt.Sprintf("subtitles.burnInSubtitles is ignored: %s", why))
+		}
+		if v.Threads != 0 {
+			warnings = append(warnings, fmt.Sprintf("video.threads is ignored: %s", why))
+		}
+		if v.HwAccel != "" {
+			warnings = append(warnings, fmt.Sprintf("video.hwAccel %q is ignored: %s", v.HwAccel, why))
+		}
+		if v.HdrMode != "" {
+			warnings = append(warnings, fmt.Sprintf("video.hdrMode %q is ignored: %s, which keeps any HDR metadata as it is", v.HdrMode, why))
+		}
+		if v.FrameRate != "" {
+			warnings = append(warnings, fmt.Sprintf("video.frameRate %q is ignored: %s", v.FrameRate, why))
+		}
+		if v.Sharpen != "" {
+			warnings = append(warnings, fmt.Sprintf("video.sharpen %q is ignored: %s", v.Sharpen, why))
+		}
+		if v.VfrMode != "" {
+			warnings = append(warnings, fmt.Sprintf("video.vfrMode %q is ignored: %s, timestamps and all", v.VfrMode, why))
+		}
+	}
+
+	if a.JustCopy && !a.DisableAudio {
+		why := "audio.justCopy copies the audio stream without decoding it"
+		if a.AudioFilter != "" {
+			warnings = append(warnings, fmt.Sprintf("audio.audioFilter %q is ignored: %s", a.AudioFilter, why))
+		}
+		if a.Loudnorm2Pass {
+			warnings = append(warnings, fmt.Sprintf("audio.loudnorm2Pass is ignored: %s", why))
+		}
+		if a.AudioOffset != 0 {
+			warnings = append(warnings, fmt.Sprintf("audio.audioOffset is ignored: %s, shifting it needs a re-encode", why))
+		}
+		if a.TrimSilence != "" {
+			warnings = append(warnings, fmt.Sprintf("audio.trimSilence %q is ignored: %s", a.TrimSilence, why))
+		}
+	} else if a.Loudnorm2Pass && a.AudioFilter != "loudnorm" && !a.DisableAudio {
+		warnings = append(warnings, fmt.Sprintf("audio.loudnorm2Pass is ignored: audio.audioFilter is %q, not \"loudnorm\"", a.AudioFilter))
+	}
+	return
+}
+
+// buildArgs assembles the ffmpeg arguments for one input, logging its progress to log.  outs[0] gets the
+// main settings, and each of settings.Outputs gets the matching path after that.
+func buildArgs(log *log.Logger, settings Settings, in string, outs []string) (args []string, err error) {
+	if !settings.Video.DisableVideo {
+		args = append(args, hwAccelArgs(settings.Video)...)
+	}
+
+	log.Printf("Parsing time options")
+
+	//-ss before -i seeks by keyframe in the input, which is fast but can land a little before the requested time.
+	//After -i it decodes and discards everything up to that point, which is accurate but slow on long skips.
+	if settings.Time.FastSeek && settings.Time.TimeSkipIntro != 0 {
+		args = append(args, []string{"-ss", fmt.Sprintf("%d", settings.Time.TimeSkipIntro)}...)
+	}
+
+	args = append(args, inputArgs(settings.Input)...)
+	args = append(args, []string{"-i", in}...)
+	if settings.Output.ChaptersFile != "" {
+		args = append(args, []string{"-f", "ffmetadata", "-i", settings.Output.ChaptersFile}...)
+	}
+	if settings.Output.CoverArt != "" {
+		args = append(args, []string{"-i", settings.Output.CoverArt}...)
+	}
+
+	if !settings.Ready.NoOverwrite {
+		args = append(args, "-y")
+	}
+
+	spec, err := outputSpecArgs(log, settings, in, outs[0])
+	if err != nil {
+		return nil, err
+	}
+	args = append(args, spec...)
+
+	//every extra output reads the same decoded input, that's the point of doing them in one run
+	for i, extra := range settings.Outputs {
+		log.Printf("parsing extra output %d", i)
+		extraSettings := settings
+		extraSettings.Video = extra.Video
+		extraSettings.Audio = extra.Audio
+		extraSettings.Subtitles = Subtitles{}
+		extraSettings.Output = Output{Maps: extra.Maps}
+		extraSettings.Outputs = nil
+
+		//with audio.audioLanguage or maps outputSpecArgs maps the streams itself
+		if len(extra.Maps) == 0 && (extra.Audio.AudioLanguage == "" || extra.Audio.DisableAudio) {
+			if !extra.Video.DisableVideo {
+				args = append(args, []string{"-map", "0:v:0?"}...)
+			}
+			if !extra.Audio.DisableAudio {
+				args = append(args, []string{"-map", "0:a:0?"}...)
+			}
+		}
+		spec, err = outputSpecArgs(log, extraSettings, in, outs[i+1])
+		if err != nil {
+			return nil, fmt.Errorf("outputs[%d]: %w", i, err)
+		}
+		args = append(args, spec...)
+	}
+	return
+}
+
+// inputArgs are the options for reading the input, which ffmpeg only takes before -i
+func inputArgs(i Input) (args []string) {
+	if i.AnalyzeDuration != "" {
+		args = append(args, []string{"-analyzeduration", i.AnalyzeDuration}...)
+	}
+	if i.ProbeSize != "" {
+		args = append(args, []string{"-probesize", i.ProbeSize}...)
+	}
+	args = append(args, i.Args...)
+	return
+}
+
+// outputSpecArgs are the arguments for one output file, ending with the file itself.  Settings that can't be
+// turned into arguments are returned as errors.
+func outputSpecArgs(log *log.Logger, settings Settings, in, out string) (args []string, err error) {
+	if !settings.Time.FastSeek && settings.Time.TimeSkipIntro != 0 {
+		args = append(args, []string{"-ss", fmt.Sprintf("%d", settings.Time.TimeSkipIntro)}...)
+	}
+	if settings.Time.TotalTime != 0 {
+		args = append(args, []string{"-t", fmt.Sprintf("%d", settings.Time.TotalTime)}...)
+	}
+	log.Printf("parsing audio options.  Args so far:\n%v", args)
+
+	//disabling a stream wins over everything else for it, including justCopy
+	if settings.Audio.DisableAudio {
+		args = append(args, "-an")
+	} else {
+		settings.Audio, err = containerAudio(settings.Audio, settings.Output, in, out)
+		if err != nil {
+			return nil, err
+		}
+		if settings.Audio.JustCopy {
+			args = append(args, []string{"-c:a", "copy"}...)
+		} else {
+			audioArgs, err := parseAudioSettings(settings.Audio, in)
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, audioArgs...)
+		}
+		audioBsf, err := bsfArgs("a", "audio.bitstreamFilters", settings.Audio.BitstreamFilters)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, audioBsf...)
+		args = append(args, audioMetadataArgs(settings.Audio)...)
+		defaultArgs, err := dispositionArgs("a", settings.Audio.DefaultTrack)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, defaultArgs...)
+	}
+	log.Printf("parsing video options.  Args so far:\n%v", args)
+
+	if settings.Video.DisableVideo {
+		args = append(args, "-vn")
+	} else if settings.Video.JustCopy {
+		args = append(args, []string{"-c:v", "copy"}...)
+		if settings.Video.AspectRatio != "" {
+			//nothing is decoded, so only the container's aspect flag can be changed
+			if err = checkAspectRatio(settings.Video.AspectRatio); err != nil {
+				return nil, err
+			}
+			args = append(args, []string{"-aspect", settings.Video.AspectRatio}...)
+		}
+	} else {
+		videoArgs, err := parseVideoSettings(settings.Video, settings.Subtitles, in)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, videoArgs...)
+	}
+	if !settings.Video.DisableVideo {
+		tagArgs, err := codecTagArgs(settings.Video, settings.Output, in, out)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, tagArgs...)
+		videoBsf, err := bsfArgs("v", "video.bitstreamFilters", settings.Video.BitstreamFilters)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, videoBsf...)
+	}
+
+	var audio string
+	if !settings.Audio.DisableAudio {
+		audio = audioStream(settings.Audio, in)
+	}
+	if len(settings.Output.Maps) > 0 {
+		args = append(args, mapArgs(settings.Output.Maps)...)
+		if settings.Subtitles.CopySubtitles {
+			//the maps already say which subtitle tracks go in, only the codec is still needed
+			args = append(args, withoutMaps(subtitleCopyArgs(settings.Output, in, out, audio))...)
+		}
+	} else if len(settings.Time.Segments) > 0 {
+		if settings.Subtitles.BurnInSubtitles && !settings.Video.DisableVideo {
+			//the subtitles filter would run after the concat, on the joined timeline rather than the input's
+			return nil, fmt.Errorf("time.segments can't be used with subtitles.burnInSubtitles, the subtitles would drift out of sync after the first cut")
+		}
+		args, err = segmentsFilterArgs(settings.Time.Segments, args, !settings.Video.DisableVideo, audio)
+		if err != nil {
+			return nil, err
+		}
+	} else if settings.Subtitles.CopySubtitles {
+		args = append(args, subtitleCopyArgs(settings.Output, in, out, audio)...)
+	} else if settings.Audio.AudioLanguage != "" && audio != "" {
+		//mapping the audio turns off ffmpeg's automatic stream selection, so the video has to be mapped too
+		if !settings.Video.DisableVideo {
+			args = append(args, []string{"-map", "0:v:0?"}...)
+		}
+		args = append(args, []string{"-map", audio + "?"}...)
+	}
+	if settings.Output.CoverArt != "" {
+		args, err = coverArtArgs(settings, in, out, audio, args)
+		if err != nil {
+			return nil, err
+		}
+	}
+	defaultArgs, err := dispositionArgs("s", settings.Subtitles.DefaultTrack)
+	if err != nil {
+		return nil, err
+	}
+	args = append(args, defaultArgs...)
+
+	if *embedSettings {
+		args = append(args, settingsMetadataArgs(settings, out)...)
+	}
+	args = append(args, outputArgs(settings.Output, out)...)
+	if isSplit(settings.Output) {
+		var split []string
+		split, out, err = splitOutput(settings, in, out)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, split...)
+	}
+	log.Printf("args so far:%s", args)
+
+	//This needs to happen last:
+	args = append(args, out)
+	return
+}
+
+// extraOutputPaths are where settings.Outputs get written, next to out with each one's suffix
+func extraOutputPaths(settings Settings, out string) (paths []string, err error) {
+	base := strings.TrimSuffix(out, filepath.Ext(out))
+	for i, extra := range settings.Outputs {
+		if extra.Suffix == "" {
+			return nil, fmt.Errorf("outputs[%d] needs a suffix, ex: -web.mp4", i)
+		}
+		paths = append(paths, base+extra.Suffix)
+	}
+	return
+}
+
+// maxSettingsMetadata is the most settings json put in a tag.  Some players and taggers choke on long tags
+const maxSettingsMetadata = 4096
+
+// settingsMetadataArgs tag the output with the settings used to make it, as compact json.  mp4 and mov
+// only keep their standard tags, so it goes in the comment there, and in an ffmpegfront tag everywhere else.
+// If the json is too long only a summary of the main video and audio settings is written.
+func settingsMetadataArgs(settings Settings, out string) (args []string) {
+	settings.Ready = Ready{}
+	settings.Outputs = nil
+	data, err := json.Marshal(settings)
+	value := string(data)
+	if err != nil || len(value) > maxSettingsMetadata {
+		v, a := settings.Video, settings.Audio
+		value = fmt.Sprintf("encoder=%s mode=%s quality=%d bitrate=%s resolution=%s; audio codec=%s bitrate=%s filter=%s",
+			videoEncoder(v), v.Mode, v.Quality, v.VideoBitrate, v.Resolution, a.AudioCodec, a.AudioBitrate, a.AudioFilter)
+		log.Printf("warning: the settings are too long to embed in %s, embedding a summary instead\n", out)
+	}
+
+	key := "ffmpegfront"
+	if stringInList(outputContainer(settings.Output, out), []string{"mp4", "mov"}) {
+		key = "comment"
+		value = "ffmpegfront " + value
+	}
+	args = append(args, []string{"-metadata", fmt.Sprintf("%s=%s", key, value)}...)
+	return
+}
+
+// outputArgs are the container level options
+func outputArgs(o Output, out string) (args []string) {
+	//splitArgs has its own -f and movflags
+	if o.Format != "" && !isSplit(o) {
+		args = append(args, []string{"-f", o.Format}...)
+	}
+
+	if o.ChaptersFile != "" {
+		//only the chapters come from the metadata input, the title and other tags are still the input's
+		args = append(args, []string{"-map_chapters", "1"}...)
+	}
+
+	if !isSplit(o) {
+		args = append(args, movflagsArgs(o, out)...)
+	}
+
+	if outputContainer(o, out) == "ts" {
+		if o.MuxRate != "" {
+			args = append(args, []string{"-muxrate", o.MuxRate}...)
+		}
+		if o.PcrPeriod != 0 {
+			args = append(args, []string{"-pcr_period", fmt.Sprintf("%d", o.PcrPeriod)}...)
+		}
+	} else if o.MuxRate != "" || o.PcrPeriod != 0 {
+		log.Printf("warning: output.muxRate and output.pcrPeriod only apply to mpegts output, ignoring them for %s\n", out)
+	}
+	return
+}
+
+// movflagsArgs picks how an mp4 or mov is laid out.  fastStart moves the index to the front once the encode is
+// done so players can start before the whole file downloads, fragmented writes it in self contained pieces for
+// DASH and low latency streaming.  A normal mp4 goes back and writes the index once it's done, which a pipe can't
+// do, so stdout is always fragmented.  checkMovflags has already ruled out fastStart with either of those.
+func movflagsArgs(o Output, out string) (args []string) {
+	if !stringInList(outputContainer(o, out), []string{"mp4", "mov"}) {
+		if o.FastStart || o.Fragmented {
+			log.Printf("warning: output.fastStart and output.fragmented only apply to mp4 and mov output, ignoring them for %s\n", out)
+		}
+		return
+	}
+	switch {
+	case o.Fragmented:
+		args = []string{"-movflags", "frag_keyframe+empty_moov+default_base_moof"}
+	case isStdout(out):
+		args = []string{"-movflags", "frag_keyframe+empty_moov"}
+	case o.FastStart:
+		args = []string{"-movflags", "+faststart"}
+	}
+	return
+}
+
+// checkMovflags catches output settings that ask for two mp4 layouts at once
+func checkMovflags(o Output, out string) error {
+	if o.FastStart && o.Fragmented {
+		return fmt.Errorf("output.fastStart and output.fragmented can't both be set, a fragmented mp4 has no single index to move to the front")
+	}
+	if o.FastStart && isStdout(out) {
+		return fmt.Errorf("output.fastStart can't be used with -outfile -, it rewrites the file once the encode is done.  Use output.fragmented instead")
+	}
+	return nil
+}
+
+// expectedDuration is how long the output should be, used to work out percent done and ETA.  0 if unknown
+func expectedDuration(settings Settings, in string) time.Duration {
+	if settings.Time.TotalTime != 0 {
+		return time.Duration(settings.Time.TotalTime) * time.Second
+	}
+
+	probe, err := probeFile(in)
+	if err != nil {
+		log.Printf("unable to get the input duration, progress won't have an ETA: %v\n", err)
+		return 0
+	}
+	return probe.duration() - time.Duration(settings.Time.TimeSkipIntro)*time.Second
+}
+
+var urlRegex = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9+.-]*://`)
+
+// isURL reports whether in is something ffmpeg reads over the network (http://, rtmp://...) rather than a local file
+func isURL(in string) bool {
+	return urlRegex.MatchString(in)
+}
+
+// isLiveURL reports whether in is a streaming protocol that may never end
+func isLiveURL(in string) bool {
+	for _, scheme := range []string{"rtmp", "rtmps", "rtsp", "rtsps", "udp", "srt", "rtp"} {
+		if strings.HasPrefix(strings.ToLower(in), scheme+"://") {
+			return true
+		}
+	}
+	return false
+}
+
+// checkInput makes sure a local input exists before anything gets run on it.  URLs go straight to ffmpeg.
+func checkInput(in string) error {
+	if isURL(in) {
+		return nil
+	}
+	fh, err := os.Stat(in)
+	if err != nil {
+		return fmt.Errorf("can't read input %s: %v", in, err)
+	}
+	if fh.IsDir() {
+		return fmt.Errorf("input %s is a directory, use -indir for batch mode", in)
+	}
+	return nil
+}
+
+// checkOutputPaths refuses outputs that would overwrite the input, or that are inside a -protect directory.
+// Paths are compared after following symlinks, so ./a.mkv, /full/path/a.mkv and a link to it all match.
+func checkOutputPaths(in string, outs []string) error {
+	inPath := resolvedPath(in)
+	inInfo, inErr := os.Stat(in)
+	for _, out := range outs {
+		if isStdout(out) {
+			continue
+		}
+		outPath := resolvedPath(out)
+		if !isURL(in) && outPath == inPath {
+			return fmt.Errorf("output %s is the input file, refusing to overwrite it (-force to do it anyway)", out)
+		}
+		if outInfo, err := os.Stat(out); err == nil && inErr == nil && os.SameFile(inInfo, outInfo) {
+			return fmt.Errorf("output %s is a link to the input file, refusing to overwrite it (-force to do it anyway)", out)
+		}
+
+		for _, dir := range strings.Split(*protectDirs, ",") {
+			if dir = strings.TrimSpace(dir); dir == "" {
+				continue
+			}
+			rel, err := filepath.Rel(resolvedPath(dir), outPath)
+			if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+				return fmt.Errorf("output %s is in the protected directory %s, refusing to write it (-force to do it anyway)", out, dir)
+			}
+		}
+	}
+	return nil
+}
+
+// resolvedPath is the absolute path of file with symlinks followed, as far as they exist
+func resolvedPath(file string) string {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return filepath.Clean(file)
+	}
+	if real, err := filepath.EvalSymlinks(abs); err == nil {
+		return real
+	}
+	//the file doesn't exist yet, but its directory might be a link
+	if dir, err := filepath.EvalSymlinks(filepath.Dir(abs)); err == nil {
+		return filepath.Join(dir, filepath.Base(abs))
+	}
+	return abs
+}
+
+// ensureOutputDir checks that out's directory exists, creating it if -mkoutdir was given
+func ensureOutputDir(out string) error {
+	if isStdout(out) {
+		return nil
+	}
+	dir := filepath.Dir(out)
+	fh, err := os.Stat(dir)
+	if err == nil {
+		if !fh.IsDir() {
+			return fmt.Errorf("can't write %s: %s is not a directory", out, dir)
+		}
+		return nil
+	}
+	if !os.IsNotExist(err) {
+		return fmt.Errorf("can't write %s: %v", out, err)
+	}
+	if !*mkOutDir {
+		return fmt.Errorf("output directory %s does not exist. Create it, or run with -mkoutdir to have it created", dir)
+	}
+	return os.MkdirAll(dir, 0755)
+}
+
+// isStdout is true for -outfile -, which has ffmpeg write the output to stdout for piping into another program
+func isStdout(out string) bool {
+	return out == "-"
+}
+
+// partialPath is where out gets written until the encode finishes.  The extension stays last so ffmpeg
+// still picks the right container from it: show/ep1.mp4 -> show/ep1.part.mp4
+func partialPath(out string) string {
+	if isStdout(out) {
+		return out
+	}
+	ext := filepath.Ext(out)
+	return fmt.Sprintf("%s.part%s", strings.TrimSuffix(out, ext), ext)
+}
+
+// withFinalPaths is args with each of partials swapped for the output it's renamed to, outs in the same order
+func withFinalPaths(args, partials, outs []string) (final []string) {
+	for _, arg := range args {
+		for i, partial := range partials {
+			if arg == partial {
+				arg = outs[i]
+				break
+			}
+		}
+		final = append(final, arg)
+	}
+	return
+}
+
+func getLogFilePath(out string) (file string) {
+	if *logFile == "" {
+		file = logToOutputDir(out)
+		return
+	}
+
+	logPath := path.Dir(*logFile)
+	fh, err := os.Stat(logPath)
+
+	if err != nil || !fh.IsDir() {
+		file = logToOutputDir(out)
+		return
+	}
+
+	file = *logFile
+	return
+}
+
+func logToOutputDir(out string) (logfile string) {
+	if isStdout(out) {
+		return "ffmpegfront-stdout.log"
+	}
+	logfile = fmt.Sprintf("%s.log", out)
+	return
+}
+
+func parseVideoSettings(v Video, s Subtitles, f string) (args []string, err error) {
+	//subtitles options look like this: `-vf "subtitles=subs.srt:force_style='FontName=ubuntu,Fontsize=24,PrimaryColour=&H0000ff&'"`, so this string needs to get built :/
+	//also subtitle and scaling need to be part of the same filter so thats just great
+	var filters []string
+
+	encoder := videoEncoder(v)
+	if v.Encoder != "" || !v.SoftwareEncode {
+		args = append(args, []string{"-c:v", encoder}...)
+	}
+	profileArgs, err := profileLevelArgs(v, encoder)
+	if err != nil {
+		return nil, err
+	}
+	args = append(args, profileArgs...)
+
+	if encoder != "h264_omx" {
+		if v.Threads > 0 {
+			args = append(args, []string{"-threads", fmt.Sprintf("%d", v.Threads)}...)
+		}
+
+		rateArgs, err := rateControlArgs(v, encoder)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, rateArgs...)
+	} else {
+		//omx has no tune, this only catches one that was set anyway
+		if _, err = tuneArgs(v, encoder); err != nil {
+			return nil, err
+		}
+	}
+
+	if v.HdrMode != "" {
+		hdrArgs, hdrFilters, err := hdrSettings(v, f)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, hdrArgs...)
+		filters = append(filters, hdrFilters...)
+	}
+
+	//libx265's frame threads come from its own pools, which -threads doesn't limit
+	if encoder == "libx265" && v.Threads > 0 {
+		args = withX265Params(args, fmt.Sprintf("pools=%d", v.Threads))
+	}
+
+	if v.Resolution != "" {
+		var res string
+		regex := regexp.MustCompile(`^[0-9]*:[0-9]*$`)
+		if regex.MatchString(v.Resolution) {
+			res = v.Resolution
+		} else if res, err = resolutionMap(v.Resolution); err != nil {
+			return nil, err
+		}
+
+		if v.NoUpscale && sourceFits(res, f) {
+			log.Printf("%s already fits in %s, not scaling it up\n", f, res)
+		} else {
+			scale, err := scaleFilter(res, v.ScaleMode)
+			if err != nil {
+				return nil, err
+			}
+			filters = append(filters, scale)
+		}
+	}
+
+	//after scaling, so it sharpens the softness scaling down leaves rather than getting scaled away itself
+	if v.Sharpen != "" {
+		sharpen, err := sharpenFilter(v.Sharpen)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, sharpen)
+	}
+
+	//after scaling, so minterpolate has fewer pixels to work on
+	if v.FrameRate != "" {
+		frameRate, err := frameRateFilter(v)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, frameRate)
+	}
+
+	vfrArgs, vfrFilters, err := vfrSettings(v, f)
+	if err != nil {
+		return nil, err
+	}
+	args = append(args, vfrArgs...)
+	filters = append(filters, vfrFilters...)
+
+	if v.AspectRatio != "" {
+		if err = checkAspectRatio(v.AspectRatio); err != nil {
+			return nil, err
+		}
+		filters = append(filters, fmt.Sprintf("setdar=%s", strings.Replace(v.AspectRatio, ":", "/", 1)))
+	}
+
+	if s.BurnInSubtitles {
+		subtitles, err := subtitlesFilters(s, f)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, subtitles...)
+	}
+
+	frameArgs, err := frameStructureArgs(v)
+	if err != nil {
+		return nil, err
+	}
+	args = append(args, frameArgs...)
+
+	if len(filters) > 0 {
+		filter := strings.Join(filters, ", ")
+		if v.HwAccelOutputFormat != "" {
+			//frames are still in gpu memory, the software filters above can't touch them until they're downloaded
+			filter = fmt.Sprintf("hwdownload,format=nv12,%s", filter)
+		}
+		args = append(args, []string{"-vf", filter}...)
+	}
+
+	return
 }
 
-func main() {
-	flag.Parse()
+// encoderProfiles are the -profile:v values each encoder takes, and the profile used when video.profile is empty
+var encoderProfiles = map[string]struct {
+	Default  string
+	Profiles []string
+}{
+	"libx264":    {"high10", []string{"baseline", "main", "high", "high10", "high422", "high444"}},
+	"h264_omx":   {"high", []string{"baseline", "main", "high"}},
+	"h264_nvenc": {"", []string{"baseline", "main", "high", "high444p"}},
+	"h264_vaapi": {"", []string{"constrained_baseline", "main", "high"}},
+	"libx265":    {"", []string{"main", "main10", "main12", "main422-10", "main444-8", "main444-10"}},
+	"hevc_nvenc": {"", []string{"main", "main10", "rext"}},
+	"hevc_vaapi": {"", []string{"main", "main10"}},
+}
 
-	logFilePath := getLogFilePath()
+var h264Levels = []string{"1", "1b", "1.1", "1.2", "1.3", "2", "2.1", "2.2", "3", "3.1", "3.2", "4", "4.1", "4.2", "5", "5.1", "5.2", "6", "6.1", "6.2"}
+var hevcLevels = []string{"1", "2", "2.1", "3", "3.1", "4", "4.1", "5", "5.1", "5.2", "6", "6.1", "6.2"}
 
-	if *templateType != "" {
-		templateJson := makeTemplate(*templateType)
-		writeJson(templateJson, "template.json")
-		os.Exit(0)
+// profileLevelArgs emits -profile:v and -level:v, checked against what the encoder takes.  Without video.profile
+// the encoder's old hardcoded default is kept.  Encoders that aren't listed get whatever is set, with a warning.
+func profileLevelArgs(v Video, encoder string) (args []string, err error) {
+	known, ok := encoderProfiles[encoder]
+	profile := v.Profile
+	if profile == "" {
+		profile = known.Default
+	} else if !ok {
+		log.Printf("warning: unable to check video.profile %q for %s\n", profile, encoder)
+	} else if !stringInList(profile, known.Profiles) {
+		return nil, fmt.Errorf("video.profile %q is not valid for %s, it takes one of %s", profile, encoder, strings.Join(known.Profiles, ", "))
+	}
+	if profile != "" {
+		args = append(args, []string{"-profile:v", profile}...)
 	}
 
-	if (*inFile == "") || (*outFile == "") || (*settingsFile == "") {
-		log.Println("Need the following flags to be used:\n\t-infile [file to process]\n\t-outfile [output target]\n\t-settings [settings json to use]\n\nOr, call with the make-template flag for it to spit out a template JSON to fill in")
-		os.Exit(1)
+	if v.Level == "" {
+		return
 	}
+	var levels []string
+	switch {
+	case strings.HasPrefix(encoder, "hevc_") || encoder == "libx265":
+		levels = hevcLevels
+	case strings.HasPrefix(encoder, "h264_") || encoder == "libx264":
+		levels = h264Levels
+	}
+	if levels == nil {
+		log.Printf("warning: unable to check video.level %q for %s\n", v.Level, encoder)
+	} else if !stringInList(v.Level, levels) {
+		return nil, fmt.Errorf("video.level %q is not valid for %s, it takes one of %s", v.Level, encoder, strings.Join(levels, ", "))
+	}
+	args = append(args, []string{"-level:v", v.Level}...)
+	return
+}
 
-	f, err := os.OpenFile(logFilePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0666)
-	if err != nil {
-		log.Println(err)
+var frameRateRegex = regexp.MustCompile(`^[1-9][0-9]*(\.[0-9]+|/[1-9][0-9]*)?$`)
+
+// frameRateFilter changes the frame rate by video.frameRateMode:
+//   - drop (the default) drops or repeats frames, which is cheap but judders when the rates don't divide evenly
+//   - blend crossfades neighbouring frames with the framerate filter, smoother but can look ghosty
+//   - interpolate makes new in-between frames with minterpolate's motion estimation.  It's the smoothest but
+//     very slow, often slower than realtime even on a fast cpu, and can warp fast motion
+func frameRateFilter(v Video) (string, error) {
+	if !frameRateRegex.MatchString(v.FrameRate) {
+		return "", fmt.Errorf("video.frameRate %q should be a number like 24, 29.97 or 24000/1001", v.FrameRate)
+	}
+	switch v.FrameRateMode {
+	case "", "drop":
+		return fmt.Sprintf("fps=%s", v.FrameRate), nil
+	case "blend":
+		return fmt.Sprintf("framerate=fps=%s", v.FrameRate), nil
+	case "interpolate":
+		return fmt.Sprintf("minterpolate=fps=%s:mi_mode=mci:mc_mode=aobmc:me_mode=bidir:vsbmc=1", v.FrameRate), nil
 	}
-	defer f.Close()
-	log := log.New(f, "ffmpegfront", log.LstdFlags)
+	return "", fmt.Errorf("video.frameRateMode %q should be drop, blend or interpolate", v.FrameRateMode)
+}
 
-	settings := parseSettingsJson(*settingsFile)
-	log.Printf("loaded settings: %v", settings)
+// sharpenPresets are unsharp's luma matrix width, height and amount, chroma is left alone so colour edges don't fringe
+var sharpenPresets = map[string]string{
+	"light":  "5:5:0.5",
+	"medium": "5:5:1.0",
+	"strong": "5:5:1.5",
+}
 
-	args := []string{"-i", *inFile}
+var unsharpRegex = regexp.MustCompile(`^(([a-z_]+=)?-?[0-9]+(\.[0-9]+)?)(:([a-z_]+=)?-?[0-9]+(\.[0-9]+)?)*$`)
 
-	if !settings.Ready.NoOverwrite {
-		args = append(args, "-y")
+// sharpenFilter is the unsharp filter for video.sharpen, one of the presets or unsharp's own options, ex: 7:7:0.8
+func sharpenFilter(sharpen string) (string, error) {
+	if preset, ok := sharpenPresets[sharpen]; ok {
+		return "unsharp=" + preset, nil
 	}
-	log.Printf("Parsing time options")
+	params := strings.TrimPrefix(sharpen, "unsharp=")
+	if !unsharpRegex.MatchString(params) {
+		return "", fmt.Errorf("video.sharpen %q should be light, medium, strong or unsharp's options, ex: 5:5:0.8", sharpen)
+	}
+	return "unsharp=" + params, nil
+}
 
-	if settings.Time.TimeSkipIntro != 0 {
-		args = append(args, []string{"-ss", fmt.Sprintf("%d", settings.Time.TimeSkipIntro)}...)
+var aspectRatioRegex = regexp.MustCompile(`^([1-9][0-9]*[:/][1-9][0-9]*|[0-9]+(\.[0-9]+)?)$`)
+
+// checkAspectRatio is an error unless ratio is w:h, w/h or a decimal like 2.35
+func checkAspectRatio(ratio string) error {
+	if !aspectRatioRegex.MatchString(ratio) {
+		return fmt.Errorf("video.aspectRatio %q should look like 16:9, 4/3 or 2.35", ratio)
 	}
-	if settings.Time.TotalTime != 0 {
-		args = append(args, []string{"-t", fmt.Sprintf("%d", settings.Time.TotalTime)}...)
+	return nil
+}
+
+// frameLimits is the highest bFrames and refFrames each encoder accepts
+var frameLimits = map[string]struct{ BFrames, RefFrames int }{
+	"libx264":    {16, 16},
+	"libx265":    {16, 16},
+	"h264_nvenc": {4, 16},
+	"hevc_nvenc": {4, 16},
+}
+
+// frameStructureArgs emits -bf and -refs when they're set, checked against what the encoder can do
+func frameStructureArgs(v Video) (args []string, err error) {
+	encoder := videoEncoder(v)
+	limits, known := frameLimits[encoder]
+
+	check := func(name, value string, min, max int) (int, error) {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < min || (known && n > max) {
+			return 0, fmt.Errorf("video.%s %q is not valid for %s, it takes %d to %d", name, value, encoder, min, max)
+		}
+		return n, nil
 	}
-	log.Printf("parsing audio options.  Args so far:\n%v", args)
 
-	if settings.Audio.JustCopy {
-		args = append(args, []string{"-c:a", "copy"}...)
-	} else {
-		audioArgs := parseAudioSettings(settings.Audio, *inFile)
-		args = append(args, audioArgs...)
+	if v.BFrames != "" {
+		if !known {
+			log.Printf("warning: %s may ignore video.bFrames\n", encoder)
+		}
+		n, err := check("bFrames", v.BFrames, 0, limits.BFrames)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, []string{"-bf", fmt.Sprintf("%d", n)}...)
 	}
-	log.Printf("parsing video options.  Args so far:\n%v", args)
+	if v.RefFrames != "" {
+		if !known {
+			log.Printf("warning: %s may ignore video.refFrames\n", encoder)
+		}
+		n, err := check("refFrames", v.RefFrames, 1, limits.RefFrames)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, []string{"-refs", fmt.Sprintf("%d", n)}...)
+	}
+	return
+}
 
-	if settings.Video.JustCopy {
-		args = append(args, []string{"-c:v", "copy"}...)
+// checkSubtitleSource makes sure there's something to burn in before minutes get spent on an encode that
+// will fail at the subtitles filter: the subtitle file has to exist, and subtitleTrack has to be one of the
+// subtitle tracks in it (or in the input when there's no subtitle file).
+func checkSubtitleSource(s Subtitles, in string) error {
+	if s.SubtitleTrack < 0 {
+		return fmt.Errorf("subtitleTrack %d can't be negative, the first is 0", s.SubtitleTrack)
+	}
+	source := in
+	if s.SubtitleFile != "" {
+		if _, err := os.Stat(s.SubtitleFile); err != nil {
+			return fmt.Errorf("subtitle file %s: %w", s.SubtitleFile, err)
+		}
+		source = s.SubtitleFile
+	}
+
+	probe, err := probeFile(source)
+	if err != nil {
+		log.Printf("warning: unable to check %s for subtitles: %v\n", source, err)
+		return nil
+	}
+	tracks := len(probe.streamsOfType("subtitle"))
+	switch {
+	case tracks == 0 && s.SubtitleFile == "":
+		return fmt.Errorf("%s has no subtitle track to burn in, set subtitles.subtitleFile", in)
+	case tracks == 0:
+		return fmt.Errorf("subtitle file %s has no subtitles in it", s.SubtitleFile)
+	case s.SubtitleTrack >= tracks:
+		return fmt.Errorf("subtitleTrack %d is past the end of %s's %d subtitle tracks, the first is 0", s.SubtitleTrack, source, tracks)
+	}
+	return nil
+}
+
+// subtitlesFilter builds the filter that burns in s, reading the subtitle track from the input file f if no subtitle file is set
+func subtitlesFilter(s Subtitles, f string) (filter string, err error) {
+	var subFile string
+	filter = "subtitles='"
+
+	if s.SubtitleFile == "" {
+		subFile = f
 	} else {
-		videoArgs := parseVideoSettings(settings.Video, settings.Subtitles, *inFile)
-		args = append(args, videoArgs...)
+		subFile = s.SubtitleFile
 	}
-	log.Printf("args so far:%s", args)
+	filter = fmt.Sprintf("%s%s", filter, subFile)
 
-	//This needs to happen last before executing the command:
-	args = append(args, *outFile)
+	if s.SubtitleTrack != 0 {
+		filter = fmt.Sprintf("%s:si=%d", filter, s.SubtitleTrack)
+	}
 
-	log.Printf("executing with these arguments: %v", args)
-	cmd := exec.Command("/usr/bin/ffmpeg", args...)
-	startTime := time.Now()
-	output, err2 := cmd.CombinedOutput()
-	log.Printf("finished with exit status: %v", err)
-	if err2 != nil {
-		log.Printf("output: %s", string(output))
+	if s.FontsDir != "" {
+		filter = fmt.Sprintf("%s:fontsdir=%s", filter, s.FontsDir)
 	}
-	duration := time.Since(startTime)
-	log.Printf("Time elapsed: %s\n", duration)
+
+	style, err := subtitleStyle(s)
+	if err != nil {
+		return "", err
+	}
+	if style != "" {
+		filter = fmt.Sprintf("%s:force_style=%s", filter, style)
+	}
+
+	filter = fmt.Sprintf(`%s'`, filter)
+	return
 }
 
-func getLogFilePath() (file string) {
-	if *logFile == "" {
-		file = logToOutputDir()
-		return
+// scaleFilter builds the scale filter for res (w:h) according to the scale mode:
+// stretch (the default) forces exactly w:h, fit shrinks to fit inside w:h keeping the aspect ratio,
+// pad does the same then letterboxes up to w:h, fill covers w:h keeping the aspect ratio and crops the overflow
+func scaleFilter(res, mode string) (string, error) {
+	switch mode {
+	case "", "stretch":
+		return fmt.Sprintf("scale=%s", res), nil
+	case "fit":
+		return fmt.Sprintf("scale=%s:force_original_aspect_ratio=decrease:force_divisible_by=2", res), nil
+	case "pad":
+		return fmt.Sprintf("scale=%s:force_original_aspect_ratio=decrease:force_divisible_by=2,pad=%s:(ow-iw)/2:(oh-ih)/2", res, res), nil
+	case "fill":
+		return fmt.Sprintf("scale=%s:force_original_aspect_ratio=increase,crop=%s", res, res), nil
 	}
+	return "", fmt.Errorf("%s is not a scale mode. Use stretch, fit, pad or fill", mode)
+}
 
-	logPath := path.Dir(*logFile)
-	fh, err := os.Stat(logPath)
+// sourceFits is true if in's video is no bigger than res (w:h) either way, so scaling to res would only
+// upscale it.  If the input can't be probed it's scaled as usual.
+func sourceFits(res, in string) bool {
+	probe, err := probeFile(in)
+	if err != nil {
+		log.Printf("warning: unable to check the input size for video.noUpscale, scaling it anyway: %v\n", err)
+		return false
+	}
+	streams := probe.streamsOfType("video")
+	if len(streams) == 0 {
+		return false
+	}
+	w, h, _ := strings.Cut(res, ":")
+	width, _ := strconv.Atoi(w)
+	height, _ := strconv.Atoi(h)
+	return streams[0].Width <= width && streams[0].Height <= height
+}
 
-	if err != nil || !fh.IsDir() {
-		file = logToOutputDir()
-		return
+// haveFontconfig guesses whether libass will be able to find fonts on its own
+func haveFontconfig() bool {
+	for _, conf := range []string{"/etc/fonts/fonts.conf", "/usr/local/etc/fonts/fonts.conf"} {
+		if _, err := os.Stat(conf); err == nil {
+			return true
+		}
 	}
+	return false
+}
 
-	file = *logFile
-	return
+// videoEncoder is the encoder ffmpeg will end up using for v
+func videoEncoder(v Video) string {
+	if v.Encoder != "" {
+		return v.Encoder
+	}
+	if !v.SoftwareEncode {
+		return "h264_omx"
+	}
+	//no -c:v is given for software encodes, ffmpeg's default for mp4 and mkv is libx264
+	return "libx264"
 }
 
-func logToOutputDir() (logfile string) {
-	logfile = fmt.Sprintf("%s.log", *outFile)
+// hwAccelArgs are the decode options that have to go before -i
+func hwAccelArgs(v Video) (args []string) {
+	if v.HwAccel == "" {
+		return
+	}
+	args = append(args, []string{"-hwaccel", v.HwAccel}...)
+	if v.HwAccelOutputFormat != "" {
+		args = append(args, []string{"-hwaccel_output_format", v.HwAccelOutputFormat}...)
+	}
 	return
 }
 
-func parseVideoSettings(v Video, s Subtitles, f string) (args []string) {
-	//subtitles options look like this: `-vf "subtitles=subs.srt:force_style='FontName=ubuntu,Fontsize=24,PrimaryColour=&H0000ff&'"`, so this string needs to get built :/
-	//also subtitle and scaling need to be part of the same filter so thats just great
-	if !v.SoftwareEncode {
-		args = append(args, []string{"-c:v", "h264_omx", "-profile:v", "high"}...)
-	} else {
-		args = append(args, []string{"-profile:v", "high10"}...)
+// checkHwAccel exits if ffmpeg wasn't built with the requested hwaccel
+func checkHwAccel(hwAccel string) {
+	if hwAccel == "auto" {
+		return
+	}
 
-		if v.Mode == "cbr" && v.VideoBitrate != "" {
-			args = append(args, []string{"-b:v", v.VideoBitrate}...)
-		} else {
-			args = append(args, "-crf", fmt.Sprintf("%d", v.Quality), "-maxrate", fmt.Sprintf("%s", v.VideoMaxRate), "-bufsize", fmt.Sprintf("%s", v.VideoBufSize), "-tune", fmt.Sprintf("%s", v.Tune))
+	output, err := exec.Command(ffmpegBin, "-hide_banner", "-hwaccels").Output()
+	if err != nil {
+		fatalf("unable to list ffmpeg's hwaccels: %v\n", err)
+	}
+
+	//first line is "Hardware acceleration methods:", one method per line after that
+	var methods []string
+	for _, line := range strings.Split(string(output), "\n") {
+		m := strings.TrimSpace(line)
+		if m == "" || strings.HasSuffix(m, ":") {
+			continue
+		}
+		if m == hwAccel {
+			return
 		}
+		methods = append(methods, m)
 	}
+	fatalf("hwaccel %s is not supported by this ffmpeg. Available: %s\n", hwAccel, strings.Join(methods, ", "))
+}
 
-	if v.Resolution != "" || s.BurnInSubtitles {
-		filter := ""
-		if v.Resolution != "" {
-			var res string
-			regex := regexp.MustCompile(`^[0-9]*:[0-9]*$`)
-			if regex.MatchString(v.Resolution) {
-				res = v.Resolution
-			} else {
-				res = resolutionMap(v.Resolution)
-			}
+// encoderCache is ffmpeg's list of encoders, filled in the first time hasEncoder is called
+var encoderCache map[string]bool
 
-			filter = fmt.Sprintf("%sscale=%s", filter, res)
+// hasEncoder reports whether this ffmpeg was built with the named encoder
+func hasEncoder(name string) bool {
+	if encoderCache == nil {
+		encoderCache = map[string]bool{}
+		output, err := exec.Command(ffmpegBin, "-hide_banner", "-encoders").Output()
+		if err != nil {
+			log.Printf("unable to list ffmpeg's encoders: %v\n", err)
+		}
+		//encoder lines look like " A....D aac    AAC (Advanced Audio Coding)", after a legend and a " ------" line
+		for _, line := range strings.Split(string(output), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) >= 2 && len(fields[0]) == 6 && fields[0] != "------" {
+				encoderCache[fields[1]] = true
+			}
 		}
+	}
+	return encoderCache[name]
+}
 
-		if s.BurnInSubtitles {
-			var subFile string
-			filter = fmt.Sprintf("%s, subtitles='", filter)
+// aacEncoder picks libfdk_aac over ffmpeg's own aac encoder when it's asked for (by name, or with
+// audio.preferFdkAac) and this ffmpeg has it, and falls back to the native one with a warning when it doesn't
+func aacEncoder(codec string, preferFdk bool) string {
+	if codec != "libfdk_aac" && !(codec == "aac" && preferFdk) {
+		return codec
+	}
+	if !hasEncoder("libfdk_aac") {
+		log.Printf("warning: this ffmpeg (%s) wasn't built with libfdk_aac, using the native aac encoder\n", ffmpegBin)
+		return "aac"
+	}
+	return "libfdk_aac"
+}
 
-			if s.SubtitleFile == "" {
-				subFile = f
-			} else {
-				subFile = s.SubtitleFile
-			}
-			filter = fmt.Sprintf("%s%s", filter, subFile)
+// aacProfile maps a friendly aac profile name to ffmpeg's, switching to libfdk_aac for HE-AAC since ffmpeg's own aac encoder can't do it
+func aacProfile(codec, profile string) (encoder, ffProfile string, err error) {
+	profiles := map[string]string{
+		"lc":        "aac_low",
+		"aac_low":   "aac_low",
+		"he":        "aac_he",
+		"aac_he":    "aac_he",
+		"he_v2":     "aac_he_v2",
+		"aac_he_v2": "aac_he_v2",
+	}
+	encoder = codec
 
-			if s.SubtitleStyle != "" {
-				filter = fmt.Sprintf("%s:force_style=%s", filter, s.SubtitleStyle)
-			}
+	if codec != "aac" && codec != "libfdk_aac" {
+		log.Printf("audioProfile %s is only for aac, ignoring it for %s\n", profile, codec)
+		return
+	}
+	ffProfile, ok := profiles[strings.ToLower(profile)]
+	if !ok {
+		err = fmt.Errorf("%s is not an aac profile. Use lc, he or he_v2", profile)
+		return
+	}
 
-			filter = fmt.Sprintf(`%s'`, filter)
+	if ffProfile != "aac_low" && codec == "aac" {
+		if !hasEncoder("libfdk_aac") {
+			err = fmt.Errorf("audioProfile %s needs the libfdk_aac encoder, which this ffmpeg (%s) wasn't built with. Use lc, or an ffmpeg with --enable-libfdk-aac", profile, ffmpegBin)
+			return
 		}
-		filter = fmt.Sprintf(`%s`, filter)
-		args = append(args, []string{"-vf", filter}...)
+		encoder = "libfdk_aac"
 	}
-
 	return
 }
 
-func parseAudioSettings(a Audio, file string) (args []string) {
-	var codec, bitrate, filter string
+func parseAudioSettings(a Audio, file string) (args []string, err error) {
+	var codec, bitrate string
+	var filters []string
 
 	if a.AudioCodec != "" {
 		codec = a.AudioCodec
 	} else {
 		codec = "aac"
 	}
+	codec = aacEncoder(codec, a.PreferFdkAac)
+
+	var profile string
+	if a.AudioProfile != "" {
+		codec, profile, err = aacProfile(codec, a.AudioProfile)
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	args = append(args, []string{"-c:a", codec}...)
+	if profile != "" {
+		args = append(args, []string{"-profile:a", profile}...)
+	}
 
 	if a.AudioChannels != "" {
 		args = append(args, []string{"-ac", a.AudioChannels}...)
 	}
 
-	if a.AudioBitrate != "" {
-		bitrate = a.AudioBitrate
+	if a.AudioQuality != "" {
+		if a.AudioBitrate != "" {
+			return nil, fmt.Errorf("audio.audioQuality and audio.auidioBitrate can't both be set, pick vbr quality or a fixed bitrate")
+		}
+		qualityArgs, err := audioQualityArgs(codec, a.AudioQuality)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, qualityArgs...)
 	} else {
-		bitrate = "192k"
+		if a.AudioBitrate != "" {
+			bitrate = a.AudioBitrate
+		} else {
+			bitrate = "192k"
+		}
+
+		args = append(args, []string{"-b:a", bitrate}...)
+	}
+
+	//before loudnorm, which lifts quiet parts and can bring near silence back above the threshold
+	if a.TrimSilence != "" {
+		trim, err := silenceRemoveFilter(a)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, trim)
 	}
 
-	args = append(args, []string{"-b:a", bitrate}...)
+	if a.AudioOffset != 0 {
+		filters = append(filters, audioOffsetFilter(a.AudioOffset))
+	}
 
-	if a.AudioFilter == "loudnorm" || a.Loudnorm2Pass {
-		if a.Loudnorm2Pass {
-			lnJson := getLoudnormJson(file)
-			filter = fmt.Sprintf("loudnorm=I=-16:TP=-1.5:LRA=11:measured_I=%s:measured_LRA=%s:measured_TP=%s:measured_thresh=%s:offset=%s:linear=true", lnJson.OutputI, lnJson.OutputLra, lnJson.OutputTp, lnJson.OutputThresh, lnJson.TargetOffset)
+	if albumGainSet {
+		//the whole batch gets the same gain instead of each file being normalized on its own
+		filters = append(filters, fmt.Sprintf("volume=%.2fdB", albumGainDb))
+	} else if a.AudioFilter == "loudnorm" {
+		if a.Loudnorm2Pass && *argsOnly {
+			//the measurement pass is a whole ffmpeg run over the input, too much for a dry run
+			log.Printf("warning: -args-only doesn't run the loudnorm measurement pass, the command has single pass loudnorm in place of the second pass\n")
+			filters = append(filters, "loudnorm=I=-16:TP=-1.5:LRA=11")
+		} else if a.Loudnorm2Pass {
+			lnJson, err := getLoudnormJson(file, audioStream(a, file))
+			if err != nil {
+				return nil, err
+			}
+			filters = append(filters, loudnormFilter(a, lnJson))
+		} else {
+			filters = append(filters, "loudnorm=I=-16:TP=-1.5:LRA=11")
 		}
-	} else {
+	}
+
+	//resampling goes after loudnorm, which works at 192kHz internally and leaves the output there
+	if a.Resampler != "" {
+		filters = append(filters, resampleFilter(a))
+	} else if a.SampleRate != "" {
+		args = append(args, []string{"-ar", a.SampleRate}...)
+	}
+
+	if len(filters) == 0 {
+		return
+	}
+	args = append(args, []string{"-filter:a", strings.Join(filters, ",")}...)
+
+	return
+}
+
+// audioQualityRanges are the vbr quality scales of the encoders that have one, and the option that sets it
+var audioQualityRanges = map[string]struct {
+	Option   string
+	Min, Max float64
+	Note     string
+}{
+	"libvorbis":  {"-q:a", -1, 10, "higher is better"},
+	"libmp3lame": {"-q:a", 0, 9, "lower is better"},
+	"libfdk_aac": {"-vbr", 1, 5, "higher is better"},
+}
+
+// audioQualityArgs emits the vbr quality option for codec, checking quality is in the codec's range
+func audioQualityArgs(codec, quality string) (args []string, err error) {
+	aliases := map[string]string{"vorbis": "libvorbis", "mp3": "libmp3lame", "lame": "libmp3lame"}
+	if alias, ok := aliases[codec]; ok {
+		codec = alias
+	}
+
+	r, ok := audioQualityRanges[codec]
+	if !ok {
+		return nil, fmt.Errorf("audio.audioQuality needs a codec with a vbr quality mode (libvorbis, libmp3lame, libfdk_aac), not %s. Use auidioBitrate instead", codec)
+	}
+	q, err := strconv.ParseFloat(quality, 64)
+	if err != nil || q < r.Min || q > r.Max {
+		return nil, fmt.Errorf("audio.audioQuality %q is out of range for %s, it takes %g to %g (%s)", quality, codec, r.Min, r.Max, r.Note)
+	}
+	args = append(args, []string{r.Option, quality}...)
+	return
+}
+
+// audioOffsetFilter shifts the audio against the video by offset seconds.  Positive pads the start with
+// silence so the audio plays later, negative cuts the start off so it plays earlier.  This is done with
+// filters rather than -itsoffset on a second copy of the input so it works with everything else that maps
+// 0:a, but it means the audio has to be re-encoded.
+func audioOffsetFilter(offset float64) string {
+	if offset > 0 {
+		return fmt.Sprintf("adelay=delays=%dms:all=1", int(offset*1000))
+	}
+	return fmt.Sprintf("atrim=start=%g,asetpts=PTS-STARTPTS", -offset)
+}
+
+var silenceThresholdRegex = regexp.MustCompile(`^(-[0-9]+(\.[0-9]+)?dB|0?\.[0-9]+)$`)
+
+// silenceRemoveFilter trims dead air from the start, end or both ends of the audio.  Silence is anything quieter
+// than audio.silenceThreshold (-50dB by default) for at least audio.silenceDuration seconds (0.5 by default).
+// silenceremove's own stop options cut silence out of the middle too, so the end is trimmed by reversing the audio,
+// trimming its start and reversing it back.  areverse holds the whole track in memory, so trimming the end of
+// very long inputs takes a lot of it.
+func silenceRemoveFilter(a Audio) (string, error) {
+	threshold := a.SilenceThreshold
+	if threshold == "" {
+		threshold = "-50dB"
+	}
+	if !silenceThresholdRegex.MatchString(threshold) {
+		return "", fmt.Errorf("audio.silenceThreshold %q should be in dB like -50dB, or an amplitude between 0 and 1", threshold)
+	}
+	duration := a.SilenceDuration
+	if duration < 0 {
+		return "", fmt.Errorf("audio.silenceDuration %g can't be negative", duration)
+	} else if duration == 0 {
+		duration = 0.5
+	}
+
+	trim := fmt.Sprintf("silenceremove=start_periods=1:start_duration=%g:start_threshold=%s", duration, threshold)
+	switch a.TrimSilence {
+	case "start":
+		return trim, nil
+	case "end":
+		return fmt.Sprintf("areverse,%s,areverse", trim), nil
+	case "both":
+		return fmt.Sprintf("%s,areverse,%s,areverse", trim, trim), nil
+	}
+	return "", fmt.Errorf("audio.trimSilence %q should be start, end or both", a.TrimSilence)
+}
+
+// audioStream is the input audio stream to use: the first one, or the first one tagged with audio.audioLanguage.
+// If no track has that language it falls back to the first with a warning.
+func audioStream(a Audio, in string) string {
+	if a.AudioLanguage == "" {
+		return "0:a:0"
+	}
+	probe, err := probeFile(in)
+	if err != nil {
+		log.Printf("warning: unable to find the %s audio track, using the first one: %v\n", a.AudioLanguage, err)
+		return "0:a:0"
+	}
+
+	if n := audioLanguageIndex(probe, a.AudioLanguage); n >= 0 {
+		return fmt.Sprintf("0:a:%d", n)
+	}
+	var languages []string
+	for _, s := range probe.streamsOfType("audio") {
+		languages = append(languages, s.Tags["language"])
+	}
+	log.Printf("warning: no %s audio track in %s (it has %q), using the first one\n", a.AudioLanguage, in, languages)
+	return "0:a:0"
+}
+
+// audioLanguageIndex is which of probe's audio streams is the first tagged with language, -1 if none are
+func audioLanguageIndex(probe probeData, language string) int {
+	if language == "" {
+		return -1
+	}
+	for n, s := range probe.streamsOfType("audio") {
+		if strings.EqualFold(s.Tags["language"], language) {
+			return n
+		}
+	}
+	return -1
+}
+
+// resampleFilter builds the aresample filter for the chosen resampler (swr or soxr), precision and dither
+func resampleFilter(a Audio) string {
+	filter := fmt.Sprintf("aresample=resampler=%s", a.Resampler)
+	if a.SampleRate != "" {
+		filter = fmt.Sprintf("%s:osr=%s", filter, a.SampleRate)
+	}
+	if a.ResamplePrecision != 0 {
+		if a.Resampler != "soxr" {
+			log.Printf("audio.resamplePrecision only applies to the soxr resampler, ignoring it\n")
+		} else {
+			filter = fmt.Sprintf("%s:precision=%d", filter, a.ResamplePrecision)
+		}
+	}
+	if a.Dither != "" {
+		filter = fmt.Sprintf("%s:dither_method=%s", filter, a.Dither)
+	}
+	return filter
+}
+
+// audioMetadataArgs tags the output audio tracks.  Works with justCopy too since it's only container metadata
+func audioMetadataArgs(a Audio) (args []string) {
+	if a.Language != "" {
+		for i, lang := range strings.Split(a.Language, ",") {
+			args = append(args, []string{fmt.Sprintf("-metadata:s:a:%d", i), fmt.Sprintf("language=%s", strings.TrimSpace(lang))}...)
+		}
+	}
+	//titles are a list rather than comma separated like languages, they often have commas in them
+	for i, title := range a.TrackTitles {
+		if title == "" {
+			continue
+		}
+		args = append(args, []string{fmt.Sprintf("-metadata:s:a:%d", i), fmt.Sprintf("title=%s", title)}...)
+	}
+	return
+}
+
+// dispositionArgs makes output track index of streamType (a, s) the default and clears the flag on the rest
+func dispositionArgs(streamType, index string) (args []string, err error) {
+	if index == "" {
 		return
 	}
+	if _, err = strconv.Atoi(index); err != nil {
+		return nil, fmt.Errorf("default track %q is not a track number", index)
+	}
+	//the more specific :N specifier wins over the blanket one for that track
+	args = append(args, []string{fmt.Sprintf("-disposition:%s", streamType), "0", fmt.Sprintf("-disposition:%s:%s", streamType, index), "default"}...)
+	return
+}
+
+func loudnormFilter(a Audio, lnJson loudnormValues) (filter string) {
+	filter = fmt.Sprintf("loudnorm=I=-16:TP=-1.5:LRA=11:measured_I=%s:measured_LRA=%s:measured_TP=%s:measured_thresh=%s:offset=%s:linear=%t", lnJson.InputI, lnJson.InputLra, lnJson.InputTp, lnJson.InputThresh, lnJson.TargetOffset, !a.LoudnormDynamic)
+	if a.LoudnormDualMono {
+		filter = fmt.Sprintf("%s:dual_mono=true", filter)
+	}
+	return
+}
 
-	args = append(args, []string{"-filter:a", filter}...)
+// loudnormCache keeps each file and stream's measurement so it's only analysed once per run.  -measure-first
+// fills it from several goroutines, so it's only used through cachedLoudnorm and cacheLoudnorm
+var loudnormCache = map[string]loudnormValues{}
+var loudnormCacheMu sync.Mutex
 
+func cachedLoudnorm(file, stream string) (lnJson loudnormValues, ok bool) {
+	loudnormCacheMu.Lock()
+	defer loudnormCacheMu.Unlock()
+	lnJson, ok = loudnormCache[file+" "+stream]
 	return
 }
 
-func getLoudnormJson(file string) (lnJson loudnormValues) {
+func cacheLoudnorm(file, stream string, lnJson loudnormValues) {
+	loudnormCacheMu.Lock()
+	defer loudnormCacheMu.Unlock()
+	loudnormCache[file+" "+stream] = lnJson
+}
+
+// getLoudnormJson is the loudnorm measurement of stream of file, from the cache if it's been measured already
+func getLoudnormJson(file, stream string) (lnJson loudnormValues, err error) {
+	if cached, ok := cachedLoudnorm(file, stream); ok {
+		return cached, nil
+	}
+
 	log.Printf("getting loudnorm 2 pass values")
-	args := []string{"-i", file, "-vn", "-af", "loudnorm=I=-16:TP=-1.5:LRA=11:print_format=json", "-f", "null", "-"} //those values are pretty standard and I feel OK having them hardcoded.
-	cmd := exec.Command("ffmpeg", args...)
+	lnJson, err = measureLoudnorm(file, stream)
+	if err != nil {
+		return
+	}
+
+	cacheLoudnorm(file, stream, lnJson)
+	return
+}
+
+// measureLoudnorm runs the loudnorm analysis pass over stream of file
+func measureLoudnorm(file, stream string) (lnJson loudnormValues, err error) {
+	args := []string{"-i", file, "-map", stream, "-af", "loudnorm=I=-16:TP=-1.5:LRA=11:print_format=json", "-f", "null", "-"} //those values are pretty standard and I feel OK having them hardcoded.
+	cmd := exec.Command(ffmpegBin, args...)
 	var errb bytes.Buffer
 	cmd.Stderr = &errb
-	err := cmd.Run()
+	err = runLowPriority(cmd)
 	if err != nil {
-		log.Println(errb.String())
-		log.Println(err)
-		os.Exit(1)
+		err = fmt.Errorf("loudnorm analysis of %s failed: %w\n%s", file, err, errb.String())
+		return
 	}
 
 	lines := strings.Split(errb.String(), "\n")
+	if len(lines) < 13 {
+		err = fmt.Errorf("loudnorm analysis of %s didn't print its measurement", file)
+		return
+	}
 	jsonString := strings.Join(lines[len(lines)-13:len(lines)-1], " ") //The JSON data is the last 12 lines before some text in a bracket.  It would be wise to implement some form of json scanning algorithm, or deleting any text outside brackets
 	jsonByte := []byte(jsonString)
 
 	err = json.Unmarshal(jsonByte, &lnJson)
 	if err != nil {
-		log.Println(err)
-		os.Exit(1)
+		err = fmt.Errorf("unable to read the loudnorm measurement of %s: %w", file, err)
 	}
-
 	return
-
 }
 
+// parseSettingsJson loads a settings file, exiting if it can't be read or parsed
 func parseSettingsJson(file string) (settings Settings) {
-	jsonFile, err := os.Open(file)
+	settings, err := readSettings(file)
 	if err != nil {
-		log.Printf("unable to open json file %s: %v\n", file, err)
-		os.Exit(1)
+		fatalf("%v\n", describeError(err))
 	}
+	return
+}
 
-	jsonBytes, err := ioutil.ReadAll(jsonFile)
+// readSettings reads a json or yaml settings file, errors are SettingsErrors
+func readSettings(file string) (settings Settings, err error) {
+	jsonBytes, err := ioutil.ReadFile(file)
 	if err != nil {
-		log.Printf("unable to read json file %s: %v\n", file, err)
-		os.Exit(1)
+		err = &SettingsError{File: file, Err: err}
+		return
 	}
 
-	err = json.Unmarshal(jsonBytes, &settings)
+	if isYamlFile(file) {
+		err = unmarshalYaml(jsonBytes, &settings)
+	} else {
+		err = json.Unmarshal(jsonBytes, &settings)
+	}
 	if err != nil {
-		log.Printf("Unable to parse json file: %v\n", err)
+		err = &SettingsError{File: file, Err: fmt.Errorf("unable to parse it: %w", err)}
 	}
 	return
 }
 
 func writeJson(jsonData Settings, fileName string) {
+	if isYamlFile(fileName) {
+		yamlData, err := marshalYaml(jsonData)
+		if err != nil {
+			log.Printf("Nope can't marshal that, %s\n", err)
+			return
+		}
+		err = ioutil.WriteFile(fileName, yamlData, 0644)
+		if err != nil {
+			log.Printf("Failed to write file %s, %s\n", fileName, err)
+		}
+		return
+	}
 	if strings.HasSuffix(fileName, ".json") != true {
 		fileName = strings.Join([]string{fileName, ".json"}, "")
 	}
@@ -288,82 +2275,339 @@ func writeJson(jsonData Settings, fileName string) {
 }
 
 func makeTemplate(arg string) Settings {
+	jsonMap := templates()
+	if _, ok := jsonMap[arg]; ok {
+		return jsonMap[arg]
+	}
+
+	return jsonMap["template"]
+}
+
+// listTemplates prints each built-in template's name and notes
+func listTemplates() {
+	jsonMap := templates()
+	names := make([]string, 0, len(jsonMap))
+	for name := range jsonMap {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Printf("%-10s %s\n", name, jsonMap[name].Ready.Notes)
+	}
+
+	for _, name := range userPresets() {
+		preset := parseSettingsJson(filepath.Join(*presetDir, name+".json"))
+		fmt.Printf("%-10s %s\n", name, preset.Ready.Notes)
+	}
+}
+
+func templates() map[string]Settings {
 	jsonMap := make(map[string]Settings)
 
 	jsonMap["template"] = Settings{
-		Video{true, false, "ex-480p, 720p, 1080p, 4k", "crf or cbr", 23, "film, grain, animation are valid tunes", "ex-2000k", "ex: 4M, not really needed unless you plan to stream the video file over anything but lan, only needed with crf", "set this to about 1x-2x your maxrate, only needed with crf"},
-		Audio{true, "ex-vorbis, lame, aac, flac", "ex- 2, 5.1", "ex- loudnorm, might just make this a boolean 'UseLoudnorm' because what other filter am I likely to use?", "ex- 200k", false},
-		Subtitles{false, "ex-file.srt, file.mkv.  It will burn the first subtitle track if given a video file. If you want to burn in a different track, then you'll need to extract it from the video file and specify it.  If you need more complicated options, do it manually ¯\\_(ツ)_/¯", "styles look like this: 'FontName=ubuntu,Fontsize=24,PrimaryColour=&H0000ff&' note that the hex is BRG because fuck you that's why"},
-		Time{0, 0},
-		Ready{false, false, "if 'JustCopy' is set as true on either audio or video settings, all other settings will be ignored.  Loudnorm2pass will be ignored if audiofilter is not set to 'loudnorm'.  Subtitles are hard to work with and i might delete that setting"},
+		Video: Video{
+			SoftwareEncode:      true,
+			JustCopy:            false,
+			Resolution:          "ex-480p, 720p, 1080p, 4k",
+			Encoder:             "ex- libx264, libx265, h264_nvenc, hevc_vaapi.  Leave empty for libx264 with softwareEncode, h264_omx without",
+			Mode:                "crf or cbr for libx264/libx265, vbr, cq, cbr or constqp for nvenc, cqp, vbr or cbr for vaapi.  quality is the crf, cq or qp",
+			Quality:             23,
+			TargetSize:          "ex- 700M, 4.7G.  Picks the video bitrate that makes the output this size and encodes it in two passes, in place of mode and videoBitrate.  Needs a software encoder and audio with a fixed bitrate",
+			Tune:                "film, grain, animation, stillimage, fastdecode, zerolatency for libx264, libx265 has no film or stillimage, nvenc takes hq, ll, ull or lossless, libaom-av1 psnr or ssim.  vaapi, omx and the other encoders have no tune, leave it empty",
+			VideoBitrate:        "ex-2000k",
+			VideoMaxRate:        "ex: 4M, not really needed unless you plan to stream the video file over anything but lan, only needed with crf",
+			VideoBufSize:        "set this to about 1x-2x your maxrate, only needed with crf",
+			HwAccel:             "ex- cuda, vaapi, videotoolbox, auto.  Decodes the input on the gpu, leave empty to decode in software",
+			HwAccelOutputFormat: "ex- cuda, vaapi.  Keeps decoded frames on the gpu for a gpu encoder.  Leave empty unless you know you need it, scaling and subtitles have to download the frames again",
+			Threads:             0,
+			ScaleMode:           "stretch, fit, pad or fill.  stretch forces the exact resolution, fit keeps the aspect ratio inside it, pad is fit with black bars out to the full resolution, fill keeps the aspect ratio and crops whatever sticks out",
+			DisableVideo:        false,
+			HdrMode:             "passthrough or tonemap.  What to do with HDR10/HLG input: passthrough keeps the bt2020 color tags and mastering display info (needs an hevc encoder to carry the mastering info), tonemap converts it to normal SDR bt709.  Leave empty to not check",
+			BFrames:             "ex- 0, 3.  Max b-frames in a row, 0 for old hardware players that can't handle them.  Leave empty for the encoder's default",
+			RefFrames:           "ex- 1, 4.  Reference frames, fewer is easier on old players.  Leave empty for the encoder's default",
+			Profile:             "ex- baseline, main, high, main10.  Older TVs and chromecasts want main or baseline h264.  Leave empty for high10 with libx264, high with h264_omx and the encoder's default otherwise",
+			Level:               "ex- 4.1.  Caps the level for devices that can't decode above it.  Leave empty for the encoder's default",
+			FrameRate:           "ex- 24, 29.97, 24000/1001.  Changes the frame rate, leave empty to keep the input's",
+			VfrMode:             "cfr or passthrough.  Variable frame rate input (screen recordings, phone videos) can drift out of sync once transcoded: cfr makes the output a constant frame rate, passthrough keeps it variable.  Leave empty to only warn when the input is variable",
+			CopyIfMatching:      false,
+			CodecTag:            "ex- hvc1, avc1.  The codec tag players go by, leave empty for hvc1 on hevc in mp4/mov (which apple devices need) and ffmpeg's default otherwise",
+			BitstreamFilters:    []string{"ex- h264_mp4toannexb", "h264_metadata=level=4.1.  Bitstream filters that rewrite the video packets without re-encoding them, in order.  Mostly for justCopy remuxes, like h264 from mp4 into mpegts"},
+			Sharpen:             "light, medium, strong, or unsharp's own options, ex: 5:5:0.8.  Sharpens after scaling, for output that looks soft after downscaling",
+			FrameRateMode:       "drop, blend or interpolate.  How frameRate gets there: drop just drops or repeats frames (cheap, can judder), blend crossfades them, interpolate makes in-between frames with minterpolate, smoothest but very slow",
+			AspectRatio:         "ex- 16:9, 4:3, 2.35.  Fixes a wrong display aspect ratio without rescaling, with setdar when encoding or the container's aspect flag with justCopy",
+		},
+		Audio: Audio{
+			JustCopy:          true,
+			AudioCodec:        "ex-vorbis, lame, aac, flac",
+			AudioChannels:     "ex- 2, 5.1",
+			AudioFilter:       "ex- loudnorm, might just make this a boolean 'UseLoudnorm' because what other filter am I likely to use?",
+			AudioBitrate:      "ex- 200k",
+			Loudnorm2Pass:     false,
+			LoudnormDynamic:   false,
+			LoudnormDualMono:  false,
+			Language:          "ex- eng, or eng,jpn to tag the first and second audio tracks.  3 letter ISO 639-2 codes, it's what jellyfin and plex look for",
+			AudioLanguage:     "ex- eng.  Use the input's audio track tagged with this language instead of the first one, falls back to the first if there isn't one",
+			TrackTitles:       []string{"ex- English 5.1", "Director's Commentary.  The title players show for each output audio track, in order"},
+			AudioProfile:      "ex- lc, he, he_v2.  Only for aac.  he and he_v2 are much better at low bitrates but need an ffmpeg built with libfdk_aac",
+			DefaultTrack:      "ex- 0, 1.  Index of the output audio track players should pick by default, every other audio track gets its default flag cleared",
+			DisableAudio:      false,
+			SampleRate:        "ex- 44100, 48000.  Leave empty to keep the input's",
+			Resampler:         "ex- soxr, swr.  soxr is the higher quality one for downsampling hi-res audio, leave empty for ffmpeg's default",
+			ResamplePrecision: 28,
+			Dither:            "ex- triangular, triangular_hp, shibata.  Dither used when reducing bit depth, only with a resampler set",
+			AudioQuality:      "ex- 5.  Variable bitrate quality instead of auidioBitrate (don't set both).  libvorbis takes -1 to 10, libmp3lame 0 to 9 where lower is better, libfdk_aac 1 to 5",
+			TrimSilence:       "start, end or both.  Trims dead air off the ends of podcasts and lectures, before loudnorm.  Only for audio only output, the video isn't cut to match.  Leave empty to keep it",
+			SilenceThreshold:  "ex- -50dB.  Quieter than this counts as silence for trimSilence, leave empty for -50dB",
+			SilenceDuration:   0.5,
+			BitstreamFilters:  []string{"ex- aac_adtstoasc.  Bitstream filters that rewrite the audio packets without re-encoding them, in order.  Needed to copy aac from mpegts into mp4 with some ffmpeg versions"},
+			CopyIfMatching:    false,
+			IncompatibleAudio: "transcode or error.  What to do when the audio codec can't go in the output's container, like flac or dts into mp4: transcode encodes it to the container's usual codec (aac, opus for webm) with a warning, error stops before encoding.  Leave empty to transcode",
+		},
+		Subtitles: Subtitles{
+			BurnInSubtitles:     false,
+			SubtitleFile:        "ex-file.srt, file.mkv.  It will burn the first subtitle track if given a video file, or the one subtitleTrack picks (0 is the first).  If you need more complicated options, do it manually ¯\\_(ツ)_/¯",
+			SubtitleStyle:       "styles look like this: 'FontName=ubuntu,Fontsize=24,PrimaryColour=&H0000ff&' note that the hex is BRG because fuck you that's why",
+			SubtitleStylePreset: "large-yellow, netflix-like, boxed, or a json file of your own presets like {\"mine\": {\"font\": \"Arial\", \"size\": 24, \"colour\": \"#FFFF00\", \"outlineColour\": \"#000000\", \"outline\": 2, \"marginV\": 20}}, picked with file.json#mine.  Easier than subtitleStyle, which can still change bits of it",
+			SubtitleAlignment:   2,
+			SubtitleMarginV:     40,
+			CopySubtitles:       false,
+			FontsDir:            "ex- /usr/share/fonts/truetype.  Directory of fonts for burned in subtitles, needed on docker/alpine where fontconfig isn't set up.  Use FontName in the style to pick one of them",
+			DefaultTrack:        "ex- 0, 1.  Index of the output subtitle track players should pick by default",
+			Burns: []SubtitleBurn{
+				{SubtitleFile: "ex- signs.ass.  Burns in several sets of subtitles with their own styles, in place of subtitleFile and the style settings above, like translated signs at the top and dialogue at the bottom.  Each takes subtitleFile, subtitleTrack, subtitleStyle, subtitleStylePreset, subtitleAlignment and subtitleMarginV", SubtitleAlignment: 8},
+				{SubtitleFile: "ex- dialogue.srt", SubtitleStylePreset: "netflix-like", SubtitleAlignment: 2},
+			},
+		},
+		Time: Time{
+			TimeSkipIntro: 0,
+			TotalTime:     0,
+			FastSeek:      false,
+			Segments:      []Segment{{Start: "ex- 0, 00:00:00", End: "ex- 600, 00:10:00.  Each segment is cut out and they're all joined together, to drop ad breaks.  Needs video and audio to be encoded, not justCopy, and can't burn in subtitles"}},
+		},
+		Input: Input{
+			AnalyzeDuration: "ex- 100M.  How much of the input (in microseconds) ffmpeg reads to find its streams, raise it with probeSize when streams go missing.  Leave empty for ffmpeg's default",
+			ProbeSize:       "ex- 100M.  How many bytes of the input ffmpeg reads to find its streams.  Leave empty for ffmpeg's default",
+			Args:            []string{"ex- -f", "rawvideo.  Any other options that have to go before -i, one per entry"},
+		},
+		Output: Output{
+			Format:       "ex- mp4, matroska, mpegts.  Only needed when the outfile's extension doesn't say what container to use",
+			MuxRate:      "ex- 8M.  Constant mux rate for mpegts output (broadcast/iptv), pads the stream out to exactly this rate.  Set it above videoBitrate + audio bitrate in cbr mode or the muxer will complain",
+			PcrPeriod:    20,
+			SplitTime:    0,
+			SplitSize:    "ex- 2G.  Cuts the output into standalone pieces, outfile_000.mp4, outfile_001.mp4 and so on, for uploading somewhere with a size limit.  Set this or splitTime (seconds per piece), not both.  Pieces are sized from an estimate, so they're aimed a bit under it",
+			Maps:         []string{"ex- 0:v:0", "0:a:1", "0:s?.  Exactly which input streams go in the output and in what order, passed to ffmpeg as -map.  Replaces the automatic track picking, including audio.audioLanguage.  Leave empty to let ffmpegfront choose"},
+			CoverArt:     "ex- poster.jpg.  Embeds a jpg or png as the cover art media libraries and players show, in mp4, mov, m4a, mp3, flac or mkv output.  The picture is copied as it is, not encoded as video.  For audio only output set video.disableVideo",
+			ChaptersFile: "ex- chapters.txt.  Adds chapters to mkv/mp4 output, from an ffmetadata file or a list with one '<start> <title>' line per chapter, start in seconds or hh:mm:ss",
+		},
+		Outputs: []ExtraOutput{
+			{
+				Suffix: "ex- -web.mp4.  Each entry here is another file encoded in the same run from the same decoded input, saved as the outfile minus its extension plus this suffix.  It gets its own video and audio settings, time settings are shared",
+				Video:  Video{SoftwareEncode: true, Resolution: "720p", Mode: "crf", Quality: 26, Tune: "film", VideoMaxRate: "2M", VideoBufSize: "3M"},
+				Audio:  Audio{AudioCodec: "aac", AudioChannels: "2", AudioBitrate: "128k"},
+			},
+		},
+		Env: []string{"ex- CUDA_VISIBLE_DEVICES=1", "LIBVA_DRIVER_NAME=iHD.  Environment variables for the ffmpeg encode, added to the ones ffmpegfront was started with.  Handy to pick a gpu per file with batch sidecars"},
+		Ready: Ready{
+			NoOverwrite: false,
+			Completed:   false,
+			Notes:       "JustCopy copies that stream as it is, so settings that would change it are ignored with a warning.  Every setting is described in the output of -json-schema.  Subtitles are hard to work with and i might delete that setting",
+		},
 	}
 	jsonMap["movie"] = Settings{
-		Video{false, true, "unchanged", "none", 0, "none", "unchanged", "none", "none"},
-		Audio{false, "aac", "2", "loudnorm", "192k", true},
-		Subtitles{false, "no file", "no style"},
-		Time{0, 0},
-		Ready{false, true, "This is for movies. It leaves the video track untouched, while loudnorming the audio track"},
+		Video: Video{
+			SoftwareEncode: false,
+			JustCopy:       true,
+			Resolution:     "unchanged",
+			Mode:           "none",
+			Quality:        0,
+			Tune:           "none",
+			VideoBitrate:   "unchanged",
+			VideoMaxRate:   "none",
+			VideoBufSize:   "none",
+		},
+		Audio: Audio{
+			JustCopy:      false,
+			AudioCodec:    "aac",
+			AudioChannels: "2",
+			AudioFilter:   "loudnorm",
+			AudioBitrate:  "192k",
+			Loudnorm2Pass: true,
+		},
+		Subtitles: Subtitles{BurnInSubtitles: false, SubtitleFile: "no file", SubtitleStyle: "no style"},
+		Time:      Time{TimeSkipIntro: 0, TotalTime: 0},
+		Ready:     Ready{NoOverwrite: false, Completed: false, Notes: "This is for movies. It leaves the video track untouched, while loudnorming the audio track.  Copying the video and encoding only the audio is the quick way to fix a file's audio, the output container just has to be able to hold the input's video codec"},
 	}
 	jsonMap["tv-high"] = Settings{
-		Video{true, false, "1080p", "crf", 21, "film", "doesnt matter", "4M", "6M"},
-		Audio{false, "aac", "2", "loudnorm", "192k", true},
-		Subtitles{false, "no file", "no style"},
-		Time{0, 0},
-		Ready{false, true, "This is for TV Shows that need high-quality video stream, but were offered with a stupidly high bitrate because someone doesn't know how to use codecs other than xvid or something.  It also does a software encode in 10bit which is like 10x slower than using the broadcom gpu to do the encode"},
+		Video: Video{
+			SoftwareEncode: true,
+			JustCopy:       false,
+			Resolution:     "1080p",
+			Mode:           "crf",
+			Quality:        21,
+			Tune:           "film",
+			VideoBitrate:   "doesnt matter",
+			VideoMaxRate:   "4M",
+			VideoBufSize:   "6M",
+		},
+		Audio: Audio{
+			JustCopy:      false,
+			AudioCodec:    "aac",
+			AudioChannels: "2",
+			AudioFilter:   "loudnorm",
+			AudioBitrate:  "192k",
+			Loudnorm2Pass: true,
+		},
+		Subtitles: Subtitles{BurnInSubtitles: false, SubtitleFile: "no file", SubtitleStyle: "no style"},
+		Time:      Time{TimeSkipIntro: 0, TotalTime: 0},
+		Ready:     Ready{NoOverwrite: false, Completed: false, Notes: "This is for TV Shows that need high-quality video stream, but were offered with a stupidly high bitrate because someone doesn't know how to use codecs other than xvid or something.  It also does a software encode in 10bit which is like 10x slower than using the broadcom gpu to do the encode"},
 	}
 	jsonMap["tv-normal"] = Settings{
-		Video{true, false, "720p", "crf", 23, "film", "doesnt matter", "2M", "3M"},
-		Audio{false, "aac", "2", "loudnorm", "192k", true},
-		Subtitles{false, "no file", "no style"},
-		Time{0, 0},
-		Ready{false, true, "This is for most TV shows. Maybe it was distributed with a higher bitrate than appropriate, or had an obnoxious intro"},
+		Video: Video{
+			SoftwareEncode: true,
+			JustCopy:       false,
+			Resolution:     "720p",
+			Mode:           "crf",
+			Quality:        23,
+			Tune:           "film",
+			VideoBitrate:   "doesnt matter",
+			VideoMaxRate:   "2M",
+			VideoBufSize:   "3M",
+		},
+		Audio: Audio{
+			JustCopy:      false,
+			AudioCodec:    "aac",
+			AudioChannels: "2",
+			AudioFilter:   "loudnorm",
+			AudioBitrate:  "192k",
+			Loudnorm2Pass: true,
+		},
+		Subtitles: Subtitles{BurnInSubtitles: false, SubtitleFile: "no file", SubtitleStyle: "no style"},
+		Time:      Time{TimeSkipIntro: 0, TotalTime: 0},
+		Ready:     Ready{NoOverwrite: false, Completed: false, Notes: "This is for most TV shows. Maybe it was distributed with a higher bitrate than appropriate, or had an obnoxious intro"},
 	}
-	if _, ok := jsonMap[arg]; ok {
-		return jsonMap[arg]
-	}
-
-	return jsonMap["template"]
+	return jsonMap
 }
 
 type Settings struct {
-	Video     Video     `json:"video"`
-	Audio     Audio     `json:"audio"`
-	Subtitles Subtitles `json:"subtitles"`
-	Time      Time      `json:"time"`
-	Ready     Ready     `json:"ready"`
+	Video     Video         `json:"video" yaml:"video"`
+	Audio     Audio         `json:"audio" yaml:"audio"`
+	Subtitles Subtitles     `json:"subtitles" yaml:"subtitles"`
+	Time      Time          `json:"time" yaml:"time"`
+	Input     Input         `json:"input" yaml:"input"`
+	Output    Output        `json:"output" yaml:"output"`
+	Outputs   []ExtraOutput `json:"outputs" yaml:"outputs"`
+	Ready     Ready         `json:"ready" yaml:"ready"`
+	Env       []string      `json:"env" yaml:"env"`
+}
+type ExtraOutput struct {
+	Suffix string   `json:"suffix" yaml:"suffix"`
+	Video  Video    `json:"video" yaml:"video"`
+	Audio  Audio    `json:"audio" yaml:"audio"`
+	Maps   []string `json:"maps" yaml:"maps"`
 }
 type Video struct {
-	SoftwareEncode bool   `json:"softwareEncode"`
-	JustCopy       bool   `json:"justCopy"`
-	Resolution     string `json:"resolution"`
-	Mode           string `json:"mode"`
-	Quality        int    `json:"quality"`
-	Tune           string `json:"tune"`
-	VideoBitrate   string `json:"videoBitrate"`
-	VideoMaxRate   string `json:"videoMaxRate"`
-	VideoBufSize   string `json:"videoBufsize"`
+	SoftwareEncode      bool     `json:"softwareEncode" yaml:"softwareEncode"`
+	JustCopy            bool     `json:"justCopy" yaml:"justCopy"`
+	Resolution          string   `json:"resolution" yaml:"resolution"`
+	Encoder             string   `json:"encoder" yaml:"encoder"`
+	Mode                string   `json:"mode" yaml:"mode"`
+	Quality             int      `json:"quality" yaml:"quality"`
+	Tune                string   `json:"tune" yaml:"tune"`
+	TargetSize          string   `json:"targetSize" yaml:"targetSize"`
+	VideoBitrate        string   `json:"videoBitrate" yaml:"videoBitrate"`
+	VideoMaxRate        string   `json:"videoMaxRate" yaml:"videoMaxRate"`
+	VideoBufSize        string   `json:"videoBufsize" yaml:"videoBufsize"`
+	HwAccel             string   `json:"hwAccel" yaml:"hwAccel"`
+	HwAccelOutputFormat string   `json:"hwAccelOutputFormat" yaml:"hwAccelOutputFormat"`
+	Threads             int      `json:"threads" yaml:"threads"`
+	ScaleMode           string   `json:"scaleMode" yaml:"scaleMode"`
+	NoUpscale           bool     `json:"noUpscale" yaml:"noUpscale"`
+	DisableVideo        bool     `json:"disableVideo" yaml:"disableVideo"`
+	HdrMode             string   `json:"hdrMode" yaml:"hdrMode"`
+	BFrames             string   `json:"bFrames" yaml:"bFrames"`
+	Profile             string   `json:"profile" yaml:"profile"`
+	Level               string   `json:"level" yaml:"level"`
+	AspectRatio         string   `json:"aspectRatio" yaml:"aspectRatio"`
+	FrameRate           string   `json:"frameRate" yaml:"frameRate"`
+	FrameRateMode       string   `json:"frameRateMode" yaml:"frameRateMode"`
+	VfrMode             string   `json:"vfrMode" yaml:"vfrMode"`
+	CodecTag            string   `json:"codecTag" yaml:"codecTag"`
+	BitstreamFilters    []string `json:"bitstreamFilters" yaml:"bitstreamFilters"`
+	CopyIfMatching      bool     `json:"copyIfMatching" yaml:"copyIfMatching"`
+	Sharpen             string   `json:"sharpen" yaml:"sharpen"`
+	RefFrames           string   `json:"refFrames" yaml:"refFrames"`
 }
 type Audio struct {
-	JustCopy      bool   `json:"justCopy"`
-	AudioCodec    string `json:"audioCodec"`
-	AudioChannels string `json:"audioChannels"`
-	AudioFilter   string `json:"audioFilter"`
-	AudioBitrate  string `json:"auidioBitrate"`
-	Loudnorm2Pass bool   `json:"loudnorm2Pass"`
+	JustCopy          bool     `json:"justCopy" yaml:"justCopy"`
+	AudioCodec        string   `json:"audioCodec" yaml:"audioCodec"`
+	AudioChannels     string   `json:"audioChannels" yaml:"audioChannels"`
+	AudioFilter       string   `json:"audioFilter" yaml:"audioFilter"`
+	AudioBitrate      string   `json:"auidioBitrate" yaml:"auidioBitrate"`
+	Loudnorm2Pass     bool     `json:"loudnorm2Pass" yaml:"loudnorm2Pass"`
+	LoudnormDynamic   bool     `json:"loudnormDynamic" yaml:"loudnormDynamic"`
+	LoudnormDualMono  bool     `json:"loudnormDualMono" yaml:"loudnormDualMono"`
+	Language          string   `json:"language" yaml:"language"`
+	AudioLanguage     string   `json:"audioLanguage" yaml:"audioLanguage"`
+	TrackTitles       []string `json:"trackTitles" yaml:"trackTitles"`
+	AudioProfile      string   `json:"audioProfile" yaml:"audioProfile"`
+	PreferFdkAac      bool     `json:"preferFdkAac" yaml:"preferFdkAac"`
+	DefaultTrack      string   `json:"defaultTrack" yaml:"defaultTrack"`
+	DisableAudio      bool     `json:"disableAudio" yaml:"disableAudio"`
+	SampleRate        string   `json:"sampleRate" yaml:"sampleRate"`
+	Resampler         string   `json:"resampler" yaml:"resampler"`
+	ResamplePrecision int      `json:"resamplePrecision" yaml:"resamplePrecision"`
+	Dither            string   `json:"dither" yaml:"dither"`
+	AudioQuality      string   `json:"audioQuality" yaml:"audioQuality"`
+	AudioOffset       float64  `json:"audioOffset" yaml:"audioOffset"`
+	TrimSilence       string   `json:"trimSilence" yaml:"trimSilence"`
+	SilenceThreshold  string   `json:"silenceThreshold" yaml:"silenceThreshold"`
+	SilenceDuration   float64  `json:"silenceDuration" yaml:"silenceDuration"`
+	IncompatibleAudio string   `json:"incompatibleAudio" yaml:"incompatibleAudio"`
+	BitstreamFilters  []string `json:"bitstreamFilters" yaml:"bitstreamFilters"`
+	CopyIfMatching    bool     `json:"copyIfMatching" yaml:"copyIfMatching"`
 }
 type Subtitles struct {
-	BurnInSubtitles bool   `json:"burnInSubtitles"`
-	SubtitleFile    string `json:"subtitleFile"`
-	SubtitleStyle   string `json:"subtitleStyle"`
+	BurnInSubtitles     bool           `json:"burnInSubtitles" yaml:"burnInSubtitles"`
+	SubtitleFile        string         `json:"subtitleFile" yaml:"subtitleFile"`
+	SubtitleTrack       int            `json:"subtitleTrack" yaml:"subtitleTrack"`
+	SubtitleStyle       string         `json:"subtitleStyle" yaml:"subtitleStyle"`
+	SubtitleStylePreset string         `json:"subtitleStylePreset" yaml:"subtitleStylePreset"`
+	SubtitleAlignment   int            `json:"subtitleAlignment" yaml:"subtitleAlignment"`
+	SubtitleMarginV     int            `json:"subtitleMarginV" yaml:"subtitleMarginV"`
+	CopySubtitles       bool           `json:"copySubtitles" yaml:"copySubtitles"`
+	FontsDir            string         `json:"fontsDir" yaml:"fontsDir"`
+	DefaultTrack        string         `json:"defaultTrack" yaml:"defaultTrack"`
+	WebvttSidecar       bool           `json:"webvttSidecar" yaml:"webvttSidecar"`
+	Burns               []SubtitleBurn `json:"burns" yaml:"burns"`
 }
 type Time struct {
-	TimeSkipIntro int `json:"timeSkipIntro"`
-	TotalTime     int `json:"totalTime"`
+	TimeSkipIntro int       `json:"timeSkipIntro" yaml:"timeSkipIntro"`
+	TotalTime     int       `json:"totalTime" yaml:"totalTime"`
+	FastSeek      bool      `json:"fastSeek" yaml:"fastSeek"`
+	Segments      []Segment `json:"segments" yaml:"segments"`
+}
+type Input struct {
+	AnalyzeDuration string   `json:"analyzeDuration" yaml:"analyzeDuration"`
+	ProbeSize       string   `json:"probeSize" yaml:"probeSize"`
+	Args            []string `json:"args" yaml:"args"`
+}
+type Output struct {
+	Format       string   `json:"format" yaml:"format"`
+	MuxRate      string   `json:"muxRate" yaml:"muxRate"`
+	PcrPeriod    int      `json:"pcrPeriod" yaml:"pcrPeriod"`
+	ChaptersFile string   `json:"chaptersFile" yaml:"chaptersFile"`
+	CoverArt     string   `json:"coverArt" yaml:"coverArt"`
+	SplitTime    int      `json:"splitTime" yaml:"splitTime"`
+	SplitSize    string   `json:"splitSize" yaml:"splitSize"`
+	Maps         []string `json:"maps" yaml:"maps"`
+	FastStart    bool     `json:"fastStart" yaml:"fastStart"`
+	Fragmented   bool     `json:"fragmented" yaml:"fragmented"`
 }
 type Ready struct {
-	NoOverwrite bool   `json:"noOverwrite"`
-	Completed   bool   `json:"completed"`
-	Notes       string `json:"notes"`
+	NoOverwrite bool   `json:"noOverwrite" yaml:"noOverwrite"`
+	Completed   bool   `json:"completed" yaml:"completed"`
+	Notes       string `json:"notes" yaml:"notes"`
 }
 
 type loudnormValues struct {
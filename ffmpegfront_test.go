@@ -0,0 +1,964 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSilenceRemoveFilter(t *testing.T) {
+	start := "silenceremove=start_periods=1:start_duration=0.5:start_threshold=-50dB"
+	tests := []struct {
+		audio   Audio
+		want    string
+		wantErr bool
+	}{
+		{Audio{TrimSilence: "start"}, start, false},
+		{Audio{TrimSilence: "end"}, "areverse," + start + ",areverse", false},
+		{Audio{TrimSilence: "both"}, start + ",areverse," + start + ",areverse", false},
+		{Audio{TrimSilence: "start", SilenceThreshold: "-40.5dB", SilenceDuration: 2}, "silenceremove=start_periods=1:start_duration=2:start_threshold=-40.5dB", false},
+		{Audio{TrimSilence: "start", SilenceThreshold: "0.01"}, "silenceremove=start_periods=1:start_duration=0.5:start_threshold=0.01", false},
+		{Audio{TrimSilence: "middle"}, "", true},
+		{Audio{TrimSilence: "start", SilenceThreshold: "50dB"}, "", true},
+		{Audio{TrimSilence: "start", SilenceThreshold: "2"}, "", true},
+		{Audio{TrimSilence: "start", SilenceDuration: -1}, "", true},
+	}
+	for _, tt := range tests {
+		got, err := silenceRemoveFilter(tt.audio)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("silenceRemoveFilter(%+v) error = %v, want error %t", tt.audio, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("silenceRemoveFilter(%+v) = %q, want %q", tt.audio, got, tt.want)
+		}
+	}
+}
+
+func TestThreadArgs(t *testing.T) {
+	tests := []struct {
+		video Video
+		want  []string
+	}{
+		{Video{Encoder: "libx264", Threads: 4}, []string{"-threads", "4"}},
+		{Video{Encoder: "libx265", Threads: 2}, []string{"-threads", "2", "-x265-params", "pools=2"}},
+		{Video{Encoder: "libvpx-vp9", Threads: 8}, []string{"-threads", "8"}},
+		{Video{Encoder: "libx264"}, nil},
+		{Video{Encoder: "libx265"}, nil},
+	}
+	for _, tt := range tests {
+		args, err := parseVideoSettings(tt.video, Subtitles{}, "in.mkv")
+		if err != nil {
+			t.Errorf("parseVideoSettings(%+v) error = %v", tt.video, err)
+			continue
+		}
+		var got []string
+		for i := 0; i+1 < len(args); i++ {
+			if args[i] == "-threads" || args[i] == "-x265-params" {
+				got = append(got, args[i], args[i+1])
+			}
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseVideoSettings(%+v) thread args = %q, want %q", tt.video, got, tt.want)
+		}
+	}
+}
+
+func TestWithX265Params(t *testing.T) {
+	tests := []struct {
+		args []string
+		want []string
+	}{
+		{[]string{"-c:v", "libx265"}, []string{"-c:v", "libx265", "-x265-params", "pools=4"}},
+		{[]string{"-c:v", "libx265", "-x265-params", "hdr-opt=1"}, []string{"-c:v", "libx265", "-x265-params", "hdr-opt=1:pools=4"}},
+	}
+	for _, tt := range tests {
+		if got := withX265Params(append([]string{}, tt.args...), "pools=4"); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("withX265Params(%q, pools=4) = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestWithFinalPaths(t *testing.T) {
+	outs := []string{"show/ep1.mp4", "show/ep1-web.mp4", "-"}
+	var partials []string
+	for _, o := range outs {
+		partials = append(partials, partialPath(o))
+	}
+	args := []string{"-i", "in.mkv", "-c:v", "libx264", "show/ep1.part.mp4", "-c:v", "libx264", "show/ep1-web.part.mp4", "-f", "matroska", "-"}
+	want := []string{"-i", "in.mkv", "-c:v", "libx264", "show/ep1.mp4", "-c:v", "libx264", "show/ep1-web.mp4", "-f", "matroska", "-"}
+	if got := withFinalPaths(args, partials, outs); !reflect.DeepEqual(got, want) {
+		t.Errorf("withFinalPaths(%q) = %q, want %q", args, got, want)
+	}
+}
+
+// argValue is the value after opt in args, empty if opt isn't there
+func argValue(args []string, opt string) string {
+	for i := 0; i+1 < len(args); i++ {
+		if args[i] == opt {
+			return args[i+1]
+		}
+	}
+	return ""
+}
+
+func TestScaleFilter(t *testing.T) {
+	tests := []struct {
+		mode    string
+		want    string
+		wantErr bool
+	}{
+		{"", "scale=1280:720", false},
+		{"stretch", "scale=1280:720", false},
+		{"fit", "scale=1280:720:force_original_aspect_ratio=decrease:force_divisible_by=2", false},
+		{"pad", "scale=1280:720:force_original_aspect_ratio=decrease:force_divisible_by=2,pad=1280:720:(ow-iw)/2:(oh-ih)/2", false},
+		{"fill", "scale=1280:720:force_original_aspect_ratio=increase,crop=1280:720", false},
+		{"zoom", "", true},
+	}
+	for _, tt := range tests {
+		got, err := scaleFilter("1280:720", tt.mode)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("scaleFilter(1280:720, %q) error = %v, want error %t", tt.mode, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("scaleFilter(1280:720, %q) = %q, want %q", tt.mode, got, tt.want)
+		}
+	}
+}
+
+func TestNoUpscale(t *testing.T) {
+	//the input is 1280x720
+	fakeFfprobe(t, `echo '{"streams":[{"codec_type":"video","codec_name":"h264","width":1280,"height":720}]}'`)
+	tests := []struct {
+		video Video
+		want  string
+	}{
+		{Video{Encoder: "libx264", Resolution: "1080p"}, "scale=1920:1080"},
+		{Video{Encoder: "libx264", Resolution: "1080p", NoUpscale: true}, ""},
+		{Video{Encoder: "libx264", Resolution: "1080p", ScaleMode: "pad", NoUpscale: true}, ""},
+		{Video{Encoder: "libx264", Resolution: "480p", NoUpscale: true}, "scale=640:480"},
+		{Video{Encoder: "libx264", Resolution: "1280:720", NoUpscale: true}, ""},
+	}
+	for _, tt := range tests {
+		args, err := parseVideoSettings(tt.video, Subtitles{}, "in.mkv")
+		if err != nil {
+			t.Errorf("parseVideoSettings(%+v) error = %v", tt.video, err)
+			continue
+		}
+		if got := argValue(args, "-vf"); got != tt.want {
+			t.Errorf("parseVideoSettings(%+v) -vf = %q, want %q", tt.video, got, tt.want)
+		}
+	}
+}
+
+// testArgs runs buildArgs for in.mkv to out.mp4, the input probed as 1280x720 h264 with english aac
+func testArgs(t *testing.T, settings Settings) []string {
+	t.Helper()
+	fakeFfprobe(t, `echo '{"streams":[{"codec_type":"video","codec_name":"h264","width":1280,"height":720},{"codec_type":"audio","codec_name":"aac","channels":2,"tags":{"language":"eng"}},{"codec_type":"audio","codec_name":"ac3","channels":6,"tags":{"language":"fra"}}],"format":{"duration":"600.5"}}'`)
+	args, err := buildArgs(log.New(io.Discard, "", 0), settings, "in.mkv", []string{"out.mp4"})
+	if err != nil {
+		t.Fatalf("buildArgs(%+v) error = %v", settings, err)
+	}
+	return args
+}
+
+// argIndex is where opt first is in args, -1 if it isn't
+func argIndex(args []string, opt string) int {
+	for i, a := range args {
+		if a == opt {
+			return i
+		}
+	}
+	return -1
+}
+
+func TestSeekPlacement(t *testing.T) {
+	tests := []struct {
+		time        Time
+		beforeInput bool
+		seeks       bool
+	}{
+		{Time{TimeSkipIntro: 90}, false, true},
+		{Time{TimeSkipIntro: 90, FastSeek: true}, true, true},
+		{Time{FastSeek: true}, false, false},
+		{Time{}, false, false},
+	}
+	for _, tt := range tests {
+		args := testArgs(t, Settings{Video: Video{Encoder: "libx264"}, Time: tt.time})
+		ss, input := argIndex(args, "-ss"), argIndex(args, "-i")
+		if (ss >= 0) != tt.seeks {
+			t.Errorf("buildArgs with %+v: -ss in %q, want it %t", tt.time, args, tt.seeks)
+			continue
+		}
+		if tt.seeks && (ss < input) != tt.beforeInput {
+			t.Errorf("buildArgs with %+v: -ss at %d and -i at %d, want -ss before -i %t", tt.time, ss, input, tt.beforeInput)
+		}
+		if tt.seeks && args[ss+1] != "90" {
+			t.Errorf("buildArgs with %+v: -ss %s, want 90", tt.time, args[ss+1])
+		}
+	}
+}
+
+func TestAudioMetadataArgs(t *testing.T) {
+	tests := []struct {
+		audio Audio
+		want  []string
+	}{
+		{Audio{}, nil},
+		{Audio{Language: "eng"}, []string{"-metadata:s:a:0", "language=eng"}},
+		{Audio{Language: "eng, fra"}, []string{"-metadata:s:a:0", "language=eng", "-metadata:s:a:1", "language=fra"}},
+		{Audio{TrackTitles: []string{"Stereo, English"}}, []string{"-metadata:s:a:0", "title=Stereo, English"}},
+		{Audio{Language: "eng,jpn", TrackTitles: []string{"", "Commentary"}}, []string{"-metadata:s:a:0", "language=eng", "-metadata:s:a:1", "language=jpn", "-metadata:s:a:1", "title=Commentary"}},
+	}
+	for _, tt := range tests {
+		if got := audioMetadataArgs(tt.audio); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("audioMetadataArgs(%+v) = %q, want %q", tt.audio, got, tt.want)
+		}
+	}
+}
+
+func TestAacEncoder(t *testing.T) {
+	defer func(old map[string]bool) { encoderCache = old }(encoderCache)
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	tests := []struct {
+		codec     string
+		preferFdk bool
+		haveFdk   bool
+		want      string
+	}{
+		{"aac", false, true, "aac"},
+		{"aac", true, true, "libfdk_aac"},
+		{"aac", true, false, "aac"},
+		{"libfdk_aac", false, true, "libfdk_aac"},
+		{"libfdk_aac", false, false, "aac"},
+		{"libopus", true, true, "libopus"},
+	}
+	for _, tt := range tests {
+		encoderCache = map[string]bool{"aac": true, "libfdk_aac": tt.haveFdk}
+		if got := aacEncoder(tt.codec, tt.preferFdk); got != tt.want {
+			t.Errorf("aacEncoder(%q, %v) with libfdk_aac=%v = %q, want %q", tt.codec, tt.preferFdk, tt.haveFdk, got, tt.want)
+		}
+	}
+}
+
+func TestAacProfile(t *testing.T) {
+	defer func(old map[string]bool) { encoderCache = old }(encoderCache)
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	tests := []struct {
+		codec, profile string
+		haveFdk        bool
+		wantEncoder    string
+		wantProfile    string
+		wantErr        bool
+	}{
+		{"aac", "lc", false, "aac", "aac_low", false},
+		{"aac", "LC", false, "aac", "aac_low", false},
+		{"aac", "he", true, "libfdk_aac", "aac_he", false},
+		{"aac", "he_v2", true, "libfdk_aac", "aac_he_v2", false},
+		{"aac", "he", false, "", "", true},
+		{"aac", "he_v2", false, "", "", true},
+		{"libfdk_aac", "aac_he", true, "libfdk_aac", "aac_he", false},
+		{"aac", "main", true, "", "", true},
+		{"libopus", "he", true, "libopus", "", false},
+	}
+	for _, tt := range tests {
+		encoderCache = map[string]bool{"aac": true, "libfdk_aac": tt.haveFdk}
+		encoder, profile, err := aacProfile(tt.codec, tt.profile)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("aacProfile(%q, %q) with libfdk_aac=%v = nil error, want one", tt.codec, tt.profile, tt.haveFdk)
+			}
+			continue
+		}
+		if err != nil || encoder != tt.wantEncoder || profile != tt.wantProfile {
+			t.Errorf("aacProfile(%q, %q) with libfdk_aac=%v = %q, %q, %v, want %q, %q", tt.codec, tt.profile, tt.haveFdk, encoder, profile, err, tt.wantEncoder, tt.wantProfile)
+		}
+	}
+}
+
+func TestDispositionArgs(t *testing.T) {
+	tests := []struct {
+		streamType, index string
+		want              []string
+		wantErr           bool
+	}{
+		{"a", "", nil, false},
+		{"a", "1", []string{"-disposition:a", "0", "-disposition:a:1", "default"}, false},
+		{"s", "0", []string{"-disposition:s", "0", "-disposition:s:0", "default"}, false},
+		{"a", "eng", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := dispositionArgs(tt.streamType, tt.index)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("dispositionArgs(%q, %q) error = %v, want error %t", tt.streamType, tt.index, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("dispositionArgs(%q, %q) = %q, want %q", tt.streamType, tt.index, got, tt.want)
+		}
+	}
+}
+
+func TestCheckInput(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "in.mkv")
+	if err := os.WriteFile(file, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		in      string
+		wantErr bool
+	}{
+		{file, false},
+		{filepath.Join(dir, "missing.mkv"), true},
+		{dir, true},
+		{"http://example.com/missing.mkv", false},
+		{"rtmp://live.example.com/app/key", false},
+		{"rtsp://camera.local/stream", false},
+		{"srt://0.0.0.0:9000?mode=listener", false},
+		{"missing://", false},
+	}
+	for _, tt := range tests {
+		if err := checkInput(tt.in); (err != nil) != tt.wantErr {
+			t.Errorf("checkInput(%q) = %v, want error %t", tt.in, err, tt.wantErr)
+		}
+	}
+}
+
+func TestIsLiveURL(t *testing.T) {
+	tests := []struct {
+		in   string
+		want bool
+	}{
+		{"rtmp://live.example.com/app/key", true},
+		{"RTSP://camera.local/stream", true},
+		{"udp://239.0.0.1:1234", true},
+		{"srt://0.0.0.0:9000", true},
+		{"http://example.com/in.mkv", false},
+		{"https://example.com/live.m3u8", false},
+		{"rtmp.mkv", false},
+		{"in.mkv", false},
+	}
+	for _, tt := range tests {
+		if got := isLiveURL(tt.in); got != tt.want {
+			t.Errorf("isLiveURL(%q) = %t, want %t", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestResampleFilter(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	tests := []struct {
+		audio Audio
+		want  string
+	}{
+		{Audio{Resampler: "swr"}, "aresample=resampler=swr"},
+		{Audio{Resampler: "soxr", SampleRate: "48000"}, "aresample=resampler=soxr:osr=48000"},
+		{Audio{Resampler: "soxr", ResamplePrecision: 28}, "aresample=resampler=soxr:precision=28"},
+		{Audio{Resampler: "swr", ResamplePrecision: 28}, "aresample=resampler=swr"},
+		{Audio{Resampler: "soxr", SampleRate: "44100", ResamplePrecision: 20, Dither: "triangular"}, "aresample=resampler=soxr:osr=44100:precision=20:dither_method=triangular"},
+	}
+	for _, tt := range tests {
+		if got := resampleFilter(tt.audio); got != tt.want {
+			t.Errorf("resampleFilter(%+v) = %q, want %q", tt.audio, got, tt.want)
+		}
+	}
+}
+
+func TestFrameStructureArgs(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	tests := []struct {
+		video   Video
+		want    []string
+		wantErr bool
+	}{
+		{Video{Encoder: "libx264"}, nil, false},
+		{Video{Encoder: "libx264", BFrames: "0"}, []string{"-bf", "0"}, false},
+		{Video{Encoder: "libx264", RefFrames: "4"}, []string{"-refs", "4"}, false},
+		{Video{Encoder: "libx265", BFrames: "3", RefFrames: "5"}, []string{"-bf", "3", "-refs", "5"}, false},
+		{Video{Encoder: "libx264", BFrames: "16"}, []string{"-bf", "16"}, false},
+		{Video{Encoder: "libx264", BFrames: "17"}, nil, true},
+		{Video{Encoder: "h264_nvenc", BFrames: "4"}, []string{"-bf", "4"}, false},
+		{Video{Encoder: "h264_nvenc", BFrames: "5"}, nil, true},
+		{Video{Encoder: "libx264", BFrames: "-1"}, nil, true},
+		{Video{Encoder: "libx264", RefFrames: "0"}, nil, true},
+		{Video{Encoder: "libx264", RefFrames: "many"}, nil, true},
+		{Video{Encoder: "hevc_vaapi", BFrames: "8", RefFrames: "32"}, []string{"-bf", "8", "-refs", "32"}, false},
+	}
+	for _, tt := range tests {
+		got, err := frameStructureArgs(tt.video)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("frameStructureArgs(%+v) error = %v, want error %t", tt.video, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("frameStructureArgs(%+v) = %q, want %q", tt.video, got, tt.want)
+		}
+	}
+}
+
+func TestAudioQualityArgs(t *testing.T) {
+	tests := []struct {
+		codec, quality string
+		want           []string
+		wantErr        bool
+	}{
+		{"libvorbis", "5", []string{"-q:a", "5"}, false},
+		{"vorbis", "-1", []string{"-q:a", "-1"}, false},
+		{"libvorbis", "10.5", nil, true},
+		{"libmp3lame", "2", []string{"-q:a", "2"}, false},
+		{"mp3", "0", []string{"-q:a", "0"}, false},
+		{"lame", "9", []string{"-q:a", "9"}, false},
+		{"libmp3lame", "10", nil, true},
+		{"libfdk_aac", "4", []string{"-vbr", "4"}, false},
+		{"libfdk_aac", "0", nil, true},
+		{"libvorbis", "high", nil, true},
+		{"aac", "2", nil, true},
+		{"libopus", "5", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := audioQualityArgs(tt.codec, tt.quality)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("audioQualityArgs(%q, %q) error = %v, want error %t", tt.codec, tt.quality, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("audioQualityArgs(%q, %q) = %q, want %q", tt.codec, tt.quality, got, tt.want)
+		}
+	}
+}
+
+func TestAudioOffsetFilter(t *testing.T) {
+	tests := []struct {
+		offset float64
+		want   string
+	}{
+		{0.5, "adelay=delays=500ms:all=1"},
+		{2, "adelay=delays=2000ms:all=1"},
+		{-0.25, "atrim=start=0.25,asetpts=PTS-STARTPTS"},
+		{-3, "atrim=start=3,asetpts=PTS-STARTPTS"},
+	}
+	for _, tt := range tests {
+		if got := audioOffsetFilter(tt.offset); got != tt.want {
+			t.Errorf("audioOffsetFilter(%g) = %q, want %q", tt.offset, got, tt.want)
+		}
+	}
+}
+
+func TestAudioOffsetArgs(t *testing.T) {
+	tests := []struct {
+		audio      Audio
+		wantFilter string
+		wantWarn   bool
+	}{
+		{Audio{AudioCodec: "aac", AudioBitrate: "128k", AudioOffset: 0.5}, "adelay=delays=500ms:all=1", false},
+		{Audio{AudioCodec: "aac", AudioBitrate: "128k", AudioOffset: -1}, "atrim=start=1,asetpts=PTS-STARTPTS", false},
+		{Audio{AudioCodec: "aac", AudioBitrate: "128k"}, "", false},
+		{Audio{JustCopy: true, AudioOffset: 0.5}, "", true},
+	}
+	for _, tt := range tests {
+		settings := Settings{Video: Video{SoftwareEncode: true, Encoder: "libx264"}, Audio: tt.audio}
+		if got := argValue(testArgs(t, settings), "-filter:a"); !strings.Contains(got, tt.wantFilter) || (tt.wantFilter == "" && strings.Contains(got, "adelay")) {
+			t.Errorf("buildArgs with %+v -filter:a = %q, want it to have %q", tt.audio, got, tt.wantFilter)
+		}
+		warned := false
+		for _, w := range ignoredSettings(settings) {
+			if strings.HasPrefix(w, "audio.audioOffset") {
+				warned = true
+			}
+		}
+		if warned != tt.wantWarn {
+			t.Errorf("ignoredSettings with %+v warned about audioOffset = %t, want %t", tt.audio, warned, tt.wantWarn)
+		}
+	}
+}
+
+func TestProfileLevelArgs(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	tests := []struct {
+		video   Video
+		encoder string
+		want    []string
+		wantErr bool
+	}{
+		{Video{}, "libx264", []string{"-profile:v", "high10"}, false},
+		{Video{}, "h264_omx", []string{"-profile:v", "high"}, false},
+		{Video{}, "libx265", nil, false},
+		{Video{Profile: "main"}, "libx264", []string{"-profile:v", "main"}, false},
+		{Video{Profile: "main10"}, "libx264", nil, true},
+		{Video{Profile: "main10", Level: "5.1"}, "libx265", []string{"-profile:v", "main10", "-level:v", "5.1"}, false},
+		{Video{Level: "4.1"}, "h264_nvenc", []string{"-level:v", "4.1"}, false},
+		{Video{Level: "1b"}, "libx265", nil, true},
+		{Video{Level: "4.3"}, "libx264", nil, true},
+		{Video{Level: "5.2"}, "hevc_qsv", []string{"-level:v", "5.2"}, false},
+		{Video{Profile: "good", Level: "anything"}, "libsvtav1", []string{"-profile:v", "good", "-level:v", "anything"}, false},
+	}
+	for _, tt := range tests {
+		got, err := profileLevelArgs(tt.video, tt.encoder)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("profileLevelArgs(%+v, %q) error = %v, want error %t", tt.video, tt.encoder, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("profileLevelArgs(%+v, %q) = %q, want %q", tt.video, tt.encoder, got, tt.want)
+		}
+	}
+}
+
+func TestInputArgs(t *testing.T) {
+	tests := []struct {
+		input Input
+		want  []string
+	}{
+		{Input{}, nil},
+		{Input{AnalyzeDuration: "100M"}, []string{"-analyzeduration", "100M"}},
+		{Input{ProbeSize: "50M"}, []string{"-probesize", "50M"}},
+		{Input{AnalyzeDuration: "100M", ProbeSize: "50M", Args: []string{"-fflags", "+genpts"}}, []string{"-analyzeduration", "100M", "-probesize", "50M", "-fflags", "+genpts"}},
+	}
+	for _, tt := range tests {
+		if got := inputArgs(tt.input); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("inputArgs(%+v) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestHwAccelArgs(t *testing.T) {
+	tests := []struct {
+		video Video
+		want  []string
+	}{
+		{Video{}, nil},
+		{Video{HwAccelOutputFormat: "cuda"}, nil},
+		{Video{HwAccel: "vaapi"}, []string{"-hwaccel", "vaapi"}},
+		{Video{HwAccel: "cuda", HwAccelOutputFormat: "cuda"}, []string{"-hwaccel", "cuda", "-hwaccel_output_format", "cuda"}},
+	}
+	for _, tt := range tests {
+		if got := hwAccelArgs(tt.video); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("hwAccelArgs(%+v) = %q, want %q", tt.video, got, tt.want)
+		}
+	}
+}
+
+func TestInputArgsBeforeInput(t *testing.T) {
+	settings := Settings{
+		Video: Video{Encoder: "h264_nvenc", HwAccel: "cuda", HwAccelOutputFormat: "cuda"},
+		Input: Input{AnalyzeDuration: "100M", ProbeSize: "50M"},
+	}
+	args := testArgs(t, settings)
+	input := argIndex(args, "-i")
+	for _, opt := range []string{"-hwaccel", "-hwaccel_output_format", "-analyzeduration", "-probesize"} {
+		if i := argIndex(args, opt); i < 0 || i > input {
+			t.Errorf("%s is at %d and -i at %d in %q, want it before -i", opt, i, input, args)
+		}
+	}
+
+	settings.Video.DisableVideo = true
+	if args = testArgs(t, settings); argIndex(args, "-hwaccel") >= 0 {
+		t.Errorf("buildArgs with video.disableVideo = %q, want no -hwaccel", args)
+	}
+}
+
+func TestCheckOutputPaths(t *testing.T) {
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	defer func(p string) { *protectDirs = p }(*protectDirs)
+
+	for _, d := range []string{"originals", "originals2", "work"} {
+		if err := os.Mkdir(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	in := filepath.Join("work", "in.mkv")
+	if err := os.WriteFile(in, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(dir, in), "link.mkv"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		in      string
+		outs    []string
+		protect string
+		wantErr bool
+	}{
+		{"different file", in, []string{"work/out.mp4"}, "", false},
+		{"same path", in, []string{in}, "", true},
+		{"relative and absolute", in, []string{filepath.Join(dir, in)}, "", true},
+		{"uncleaned", in, []string{"./work/../work/in.mkv"}, "", true},
+		{"symlink to the input", in, []string{"link.mkv"}, "", true},
+		{"second output", in, []string{"work/out.mp4", in}, "", true},
+		{"stdout", in, []string{"-"}, "", false},
+		{"url input", "http://example.com/work/in.mkv", []string{in}, "", false},
+		{"protected", in, []string{"originals/out.mp4"}, "originals", true},
+		{"protected subdirectory", in, []string{"originals/a/b/out.mp4"}, filepath.Join(dir, "originals"), true},
+		{"protected, one of several", in, []string{"originals/out.mp4"}, "elsewhere, originals", true},
+		{"similar name", in, []string{"originals2/out.mp4"}, "originals", false},
+		{"next to protected", in, []string{"work/out.mp4"}, "originals", false},
+		{"stdout with protected", in, []string{"-"}, ".", false},
+	}
+	for _, tt := range tests {
+		*protectDirs = tt.protect
+		if err := checkOutputPaths(tt.in, tt.outs); (err != nil) != tt.wantErr {
+			t.Errorf("%s: checkOutputPaths(%q, %q) = %v, want error %t", tt.name, tt.in, tt.outs, err, tt.wantErr)
+		}
+	}
+}
+
+func TestAudioStream(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+	fakeFfprobe(t, `echo '{"streams":[{"codec_type":"video","codec_name":"h264"},{"codec_type":"audio","codec_name":"ac3"},{"codec_type":"audio","codec_name":"aac","tags":{"language":"fra"}},{"codec_type":"audio","codec_name":"aac","tags":{"language":"eng"}},{"codec_type":"audio","codec_name":"ac3","tags":{"language":"eng"}}]}'`)
+
+	tests := []struct {
+		language string
+		want     string
+	}{
+		{"", "0:a:0"},
+		{"fra", "0:a:1"},
+		{"eng", "0:a:2"},
+		{"ENG", "0:a:2"},
+		{"jpn", "0:a:0"},
+	}
+	for _, tt := range tests {
+		if got := audioStream(Audio{AudioLanguage: tt.language}, "in.mkv"); got != tt.want {
+			t.Errorf("audioStream(%q) = %q, want %q", tt.language, got, tt.want)
+		}
+	}
+
+	fakeFfprobe(t, "exit 1")
+	if got := audioStream(Audio{AudioLanguage: "eng"}, "in.mkv"); got != "0:a:0" {
+		t.Errorf("audioStream(%q) when probing fails = %q, want 0:a:0", "eng", got)
+	}
+}
+
+func TestSettingsMetadataArgs(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	settings := Settings{
+		Video:   Video{Encoder: "libx265", Quality: 24, Resolution: "1080p"},
+		Audio:   Audio{AudioCodec: "libopus", AudioBitrate: "128k"},
+		Outputs: []ExtraOutput{{Suffix: "_small"}},
+		Ready:   Ready{Completed: true, Notes: "done"},
+	}
+	args := settingsMetadataArgs(settings, "out.mkv")
+	if len(args) != 2 || args[0] != "-metadata" || !strings.HasPrefix(args[1], "ffmpegfront=") {
+		t.Fatalf("settingsMetadataArgs(out.mkv) = %q, want -metadata ffmpegfront=<json>", args)
+	}
+	var got Settings
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(args[1], "ffmpegfront=")), &got); err != nil {
+		t.Fatalf("settingsMetadataArgs(out.mkv) json = %v", err)
+	}
+	want := settings
+	want.Outputs, want.Ready = nil, Ready{}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("settingsMetadataArgs(out.mkv) embedded %+v, want %+v", got, want)
+	}
+	if len(settings.Outputs) != 1 {
+		t.Errorf("settingsMetadataArgs changed the caller's outputs to %+v", settings.Outputs)
+	}
+
+	for _, out := range []string{"out.mp4", "out.mov"} {
+		if args := settingsMetadataArgs(settings, out); !strings.HasPrefix(argValue(args, "-metadata"), "comment=ffmpegfront {") {
+			t.Errorf("settingsMetadataArgs(%s) = %q, want the json in the comment", out, args)
+		}
+	}
+
+	settings.Env = []string{strings.Repeat("x", maxSettingsMetadata)}
+	summary := "ffmpegfront=encoder=libx265 mode= quality=24 bitrate= resolution=1080p; audio codec=libopus bitrate=128k filter="
+	if args := settingsMetadataArgs(settings, "out.mkv"); argValue(args, "-metadata") != summary {
+		t.Errorf("settingsMetadataArgs with long settings = %q, want %q", args, summary)
+	}
+}
+
+func TestFrameRateFilter(t *testing.T) {
+	tests := []struct {
+		video   Video
+		want    string
+		wantErr bool
+	}{
+		{Video{FrameRate: "24"}, "fps=24", false},
+		{Video{FrameRate: "29.97", FrameRateMode: "drop"}, "fps=29.97", false},
+		{Video{FrameRate: "24000/1001", FrameRateMode: "blend"}, "framerate=fps=24000/1001", false},
+		{Video{FrameRate: "60", FrameRateMode: "interpolate"}, "minterpolate=fps=60:mi_mode=mci:mc_mode=aobmc:me_mode=bidir:vsbmc=1", false},
+		{Video{FrameRate: "60", FrameRateMode: "smooth"}, "", true},
+		{Video{FrameRate: "0"}, "", true},
+		{Video{FrameRate: "24/0"}, "", true},
+		{Video{FrameRate: "-24"}, "", true},
+		{Video{FrameRate: "24fps"}, "", true},
+		{Video{FrameRate: "ntsc"}, "", true},
+	}
+	for _, tt := range tests {
+		got, err := frameRateFilter(tt.video)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("frameRateFilter(%+v) error = %v, want error %t", tt.video, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("frameRateFilter(%+v) = %q, want %q", tt.video, got, tt.want)
+		}
+	}
+}
+
+func TestSourceFits(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	tests := []struct {
+		probe string
+		res   string
+		want  bool
+	}{
+		{`{"streams":[{"codec_type":"video","width":1280,"height":720}]}`, "1920:1080", true},
+		{`{"streams":[{"codec_type":"video","width":1280,"height":720}]}`, "1280:720", true},
+		{`{"streams":[{"codec_type":"video","width":1280,"height":720}]}`, "640:480", false},
+		{`{"streams":[{"codec_type":"video","width":1440,"height":1080}]}`, "1920:800", false},
+		{`{"streams":[{"codec_type":"video","width":720,"height":1280}]}`, "1280:720", false},
+		{`{"streams":[{"codec_type":"audio","codec_name":"flac"}]}`, "1920:1080", false},
+		{`not json`, "1920:1080", false},
+	}
+	for _, tt := range tests {
+		fakeFfprobe(t, "echo '"+tt.probe+"'")
+		if got := sourceFits(tt.res, "in.mkv"); got != tt.want {
+			t.Errorf("sourceFits(%q) of %s = %t, want %t", tt.res, tt.probe, got, tt.want)
+		}
+	}
+}
+
+func TestSharpenFilter(t *testing.T) {
+	tests := []struct {
+		sharpen string
+		want    string
+		wantErr bool
+	}{
+		{"light", "unsharp=5:5:0.5", false},
+		{"medium", "unsharp=5:5:1.0", false},
+		{"strong", "unsharp=5:5:1.5", false},
+		{"7:7:0.8", "unsharp=7:7:0.8", false},
+		{"unsharp=3:3:-0.5", "unsharp=3:3:-0.5", false},
+		{"luma_msize_x=7:luma_amount=1.2", "unsharp=luma_msize_x=7:luma_amount=1.2", false},
+		{"extreme", "", true},
+		{"5:5:1,hflip", "", true},
+		{"5:5:", "", true},
+		{"", "", true},
+	}
+	for _, tt := range tests {
+		got, err := sharpenFilter(tt.sharpen)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("sharpenFilter(%q) error = %v, want error %t", tt.sharpen, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("sharpenFilter(%q) = %q, want %q", tt.sharpen, got, tt.want)
+		}
+	}
+}
+
+func TestSubtitlesFilter(t *testing.T) {
+	tests := []struct {
+		subs    Subtitles
+		want    string
+		wantErr bool
+	}{
+		{Subtitles{}, "subtitles='in.mkv'", false},
+		{Subtitles{SubtitleTrack: 2}, "subtitles='in.mkv:si=2'", false},
+		{Subtitles{SubtitleFile: "subs.srt"}, "subtitles='subs.srt'", false},
+		{Subtitles{FontsDir: "/usr/share/fonts/truetype"}, "subtitles='in.mkv:fontsdir=/usr/share/fonts/truetype'", false},
+		{Subtitles{SubtitleFile: "subs.ass", SubtitleTrack: 1, FontsDir: "fonts", SubtitleStyle: "FontName=DejaVu Sans,Fontsize=24"},
+			"subtitles='subs.ass:si=1:fontsdir=fonts:force_style=FontName=DejaVu Sans,Fontsize=24'", false},
+		{Subtitles{FontsDir: "fonts", SubtitleAlignment: 8}, "subtitles='in.mkv:fontsdir=fonts:force_style=Alignment=8'", false},
+		{Subtitles{FontsDir: "fonts", SubtitleStyle: "Fontsize=big"}, "", true},
+	}
+	for _, tt := range tests {
+		got, err := subtitlesFilter(tt.subs, "in.mkv")
+		if (err != nil) != tt.wantErr {
+			t.Errorf("subtitlesFilter(%+v) error = %v, want error %t", tt.subs, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("subtitlesFilter(%+v) = %q, want %q", tt.subs, got, tt.want)
+		}
+	}
+}
+
+func TestDisableStreams(t *testing.T) {
+	tests := []struct {
+		name       string
+		settings   Settings
+		want       []string
+		wantAbsent []string
+	}{
+		{"video disabled", Settings{Video: Video{DisableVideo: true, Resolution: "720p"}},
+			[]string{"-vn"}, []string{"-an", "-c:v", "-vf", "-tag:v"}},
+		{"video disabled over justCopy", Settings{Video: Video{DisableVideo: true, JustCopy: true}},
+			[]string{"-vn"}, []string{"-c:v"}},
+		{"audio disabled", Settings{Audio: Audio{DisableAudio: true, AudioCodec: "aac", AudioBitrate: "128k", AudioFilter: "loudnorm"}},
+			[]string{"-an"}, []string{"-vn", "-c:a", "-b:a", "-filter:a"}},
+		{"audio disabled over justCopy", Settings{Audio: Audio{DisableAudio: true, JustCopy: true}},
+			[]string{"-an"}, []string{"-c:a"}},
+		{"video disabled, audio copied", Settings{Video: Video{DisableVideo: true}, Audio: Audio{JustCopy: true}},
+			[]string{"-vn", "-c:a"}, []string{"-an", "-c:v"}},
+		{"audio disabled, video copied", Settings{Video: Video{JustCopy: true}, Audio: Audio{DisableAudio: true}},
+			[]string{"-an", "-c:v"}, []string{"-vn", "-c:a"}},
+	}
+	for _, tt := range tests {
+		args := testArgs(t, tt.settings)
+		for _, opt := range tt.want {
+			if argIndex(args, opt) < 0 {
+				t.Errorf("%s: buildArgs = %q, want %s", tt.name, args, opt)
+			}
+		}
+		for _, opt := range tt.wantAbsent {
+			if argIndex(args, opt) >= 0 {
+				t.Errorf("%s: buildArgs = %q, want no %s", tt.name, args, opt)
+			}
+		}
+	}
+}
+
+func TestDisableStreamsIgnoredSettings(t *testing.T) {
+	tests := []struct {
+		settings Settings
+		want     []string
+	}{
+		{Settings{Video: Video{DisableVideo: true, JustCopy: true}, Subtitles: Subtitles{BurnInSubtitles: true}},
+			[]string{"video.justCopy is ignored", "subtitles.burnInSubtitles is ignored"}},
+		{Settings{Audio: Audio{DisableAudio: true, JustCopy: true}}, []string{"audio.justCopy and audio.audioFilter are ignored"}},
+		{Settings{Audio: Audio{DisableAudio: true, AudioFilter: "loudnorm", Loudnorm2Pass: true}}, []string{"audio.justCopy and audio.audioFilter are ignored"}},
+	}
+	for _, tt := range tests {
+		warnings := ignoredSettings(tt.settings)
+		if len(warnings) != len(tt.want) {
+			t.Errorf("ignoredSettings(%+v) = %q, want %d warnings", tt.settings, warnings, len(tt.want))
+			continue
+		}
+		for i, w := range warnings {
+			if !strings.HasPrefix(w, tt.want[i]) {
+				t.Errorf("ignoredSettings(%+v) warning %d = %q, want it to start %q", tt.settings, i, w, tt.want[i])
+			}
+		}
+	}
+}
+
+func TestCheckAspectRatio(t *testing.T) {
+	tests := []struct {
+		ratio   string
+		wantErr bool
+	}{
+		{"16:9", false},
+		{"4/3", false},
+		{"2.35", false},
+		{"2", false},
+		{"0:9", true},
+		{"16:", true},
+		{"16:9:1", true},
+		{"-1.5", true},
+		{"wide", true},
+	}
+	for _, tt := range tests {
+		if err := checkAspectRatio(tt.ratio); (err != nil) != tt.wantErr {
+			t.Errorf("checkAspectRatio(%q) = %v, want error %t", tt.ratio, err, tt.wantErr)
+		}
+	}
+}
+
+func TestAspectRatioArgs(t *testing.T) {
+	tests := []struct {
+		video      Video
+		wantVf     string
+		wantAspect string
+	}{
+		{Video{Encoder: "libx264", AspectRatio: "16:9"}, "setdar=16/9", ""},
+		{Video{Encoder: "libx264", AspectRatio: "2.35", Resolution: "720p"}, "scale=1280:720, setdar=2.35", ""},
+		{Video{JustCopy: true, AspectRatio: "4:3"}, "", "4:3"},
+		{Video{Encoder: "libx264"}, "", ""},
+	}
+	for _, tt := range tests {
+		args := testArgs(t, Settings{Video: tt.video})
+		if got := argValue(args, "-vf"); got != tt.wantVf {
+			t.Errorf("buildArgs with %+v -vf = %q, want %q", tt.video, got, tt.wantVf)
+		}
+		if got := argValue(args, "-aspect"); got != tt.wantAspect {
+			t.Errorf("buildArgs with %+v -aspect = %q, want %q", tt.video, got, tt.wantAspect)
+		}
+	}
+
+	for _, v := range []Video{{Encoder: "libx264", AspectRatio: "wide"}, {JustCopy: true, AspectRatio: "wide"}} {
+		if _, err := buildArgs(log.New(io.Discard, "", 0), Settings{Video: v}, "in.mkv", []string{"out.mp4"}); err == nil {
+			t.Errorf("buildArgs with %+v = nil error, want one", v)
+		}
+	}
+}
+
+func TestMovflagsArgs(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	tests := []struct {
+		output Output
+		out    string
+		want   []string
+	}{
+		{Output{}, "out.mp4", nil},
+		{Output{FastStart: true}, "out.mp4", []string{"-movflags", "+faststart"}},
+		{Output{FastStart: true}, "out.mov", []string{"-movflags", "+faststart"}},
+		{Output{Fragmented: true}, "out.mp4", []string{"-movflags", "frag_keyframe+empty_moov+default_base_moof"}},
+		{Output{Format: "mp4"}, "-", []string{"-movflags", "frag_keyframe+empty_moov"}},
+		{Output{Format: "mp4", Fragmented: true}, "-", []string{"-movflags", "frag_keyframe+empty_moov+default_base_moof"}},
+		{Output{FastStart: true}, "out.mkv", nil},
+		{Output{Fragmented: true}, "out.webm", nil},
+		{Output{Format: "matroska"}, "-", nil},
+	}
+	for _, tt := range tests {
+		if got := movflagsArgs(tt.output, tt.out); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("movflagsArgs(%+v, %q) = %q, want %q", tt.output, tt.out, got, tt.want)
+		}
+	}
+}
+
+func TestCheckMovflags(t *testing.T) {
+	tests := []struct {
+		output  Output
+		out     string
+		wantErr bool
+	}{
+		{Output{}, "out.mp4", false},
+		{Output{FastStart: true}, "out.mp4", false},
+		{Output{Fragmented: true}, "-", false},
+		{Output{FastStart: true, Fragmented: true}, "out.mp4", true},
+		{Output{FastStart: true}, "-", true},
+	}
+	for _, tt := range tests {
+		if err := checkMovflags(tt.output, tt.out); (err != nil) != tt.wantErr {
+			t.Errorf("checkMovflags(%+v, %q) = %v, want error %t", tt.output, tt.out, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// tonemapFilter converts HDR (pq or hlg, bt2020) to SDR bt709 via linear light
+const tonemapFilter = "zscale=t=linear:npl=100,format=gbrpf32le,zscale=p=bt709,tonemap=tonemap=hable:desat=0,zscale=t=bt709:m=bt709:r=tv,format=yuv420p"
+
+type hdrInfo struct {
+	ColorPrimaries string
+	ColorTransfer  string
+	ColorSpace     string
+	//MasterDisplay and MaxCll are in x265's master-display/max-cll formats, empty if the input doesn't have them
+	MasterDisplay string
+	MaxCll        string
+}
+
+func (h hdrInfo) isHdr() bool {
+	return h.ColorTransfer == "smpte2084" || h.ColorTransfer == "arib-std-b67"
+}
+
+// checkHdrMode is an error unless mode is one hdrSettings knows, or empty to not check
+func checkHdrMode(mode string) error {
+	switch mode {
+	case "", "passthrough", "tonemap":
+		return nil
+	}
+	return fmt.Errorf("video.hdrMode %q should be passthrough or tonemap", mode)
+}
+
+// hdrSettings probes f and returns the args (for passthrough) or filters (for tonemap) for v.HdrMode.  SDR input
+// gets neither, but a bad hdrMode is an error either way.
+func hdrSettings(v Video, f string) (args, filters []string, err error) {
+	if err = checkHdrMode(v.HdrMode); err != nil {
+		return
+	}
+	hdr, probeErr := probeHdr(f)
+	if probeErr != nil {
+		log.Printf("warning: unable to check %s for HDR, leaving its colors alone: %v\n", f, probeErr)
+		return
+	}
+	if !hdr.isHdr() {
+		return
+	}
+
+	switch v.HdrMode {
+	case "passthrough":
+		args = hdrPassthroughArgs(hdr, videoEncoder(v))
+	case "tonemap":
+		filters = append(filters, tonemapFilter)
+	}
+	return
+}
+
+// hdrPassthroughArgs tags the output with the input's HDR colors.  The mastering display and light level
+// info has to go in through the encoder, which only x265 supports.
+func hdrPassthroughArgs(hdr hdrInfo, encoder string) (args []string) {
+	args = append(args, []string{"-color_primaries", hdr.ColorPrimaries, "-color_trc", hdr.ColorTransfer, "-colorspace", hdr.ColorSpace}...)
+
+	if encoder != "libx265" {
+		if hdr.MasterDisplay != "" {
+			log.Printf("warning: %s can't carry HDR mastering display metadata, only the color tags will be kept\n", encoder)
+		}
+		return
+	}
+
+	params := []string{"hdr10-opt=1", "repeat-headers=1"}
+	if hdr.MasterDisplay != "" {
+		params = append(params, fmt.Sprintf("master-display=%s", hdr.MasterDisplay))
+	}
+	if hdr.MaxCll != "" {
+		params = append(params, fmt.Sprintf("max-cll=%s", hdr.MaxCll))
+	}
+	args = append(args, []string{"-x265-params", strings.Join(params, ":")}...)
+	return
+}
+
+type probeSideData struct {
+	SideDataType string      `json:"side_data_type"`
+	RedX         string      `json:"red_x"`
+	RedY         string      `json:"red_y"`
+	GreenX       string      `json:"green_x"`
+	GreenY       string      `json:"green_y"`
+	BlueX        string      `json:"blue_x"`
+	BlueY        string      `json:"blue_y"`
+	WhitePointX  string      `json:"white_point_x"`
+	WhitePointY  string      `json:"white_point_y"`
+	MinLuminance string      `json:"min_luminance"`
+	MaxLuminance string      `json:"max_luminance"`
+	MaxContent   json.Number `json:"max_content"`
+	MaxAverage   json.Number `json:"max_average"`
+}
+
+// probeHdr reads the first video stream's color info and HDR side data from its probeFile results
+func probeHdr(f string) (hdr hdrInfo, err error) {
+	probe, err := probeFile(f)
+	if err != nil {
+		return
+	}
+	video := probe.streamsOfType("video")
+	if len(video) == 0 {
+		err = fmt.Errorf("no video stream")
+		return
+	}
+
+	hdr.ColorPrimaries = video[0].ColorPrimaries
+	hdr.ColorTransfer = video[0].ColorTransfer
+	hdr.ColorSpace = video[0].ColorSpace
+	for _, sd := range video[0].SideDataList {
+		switch sd.SideDataType {
+		case "Mastering display metadata":
+			hdr.MasterDisplay = masterDisplay(sd)
+		case "Content light level metadata":
+			hdr.MaxCll = fmt.Sprintf("%s,%s", sd.MaxContent, sd.MaxAverage)
+		}
+	}
+	return
+}
+
+// masterDisplay converts ffprobe's mastering display fractions into x265's
+// G(x,y)B(x,y)R(x,y)WP(x,y)L(max,min), chromaticity in units of 0.00002 and luminance in 0.0001 cd/m2
+func masterDisplay(sd probeSideData) string {
+	c := func(v string) int64 { return rescaleFraction(v, 50000) }
+	l := func(v string) int64 { return rescaleFraction(v, 10000) }
+	return fmt.Sprintf("G(%d,%d)B(%d,%d)R(%d,%d)WP(%d,%d)L(%d,%d)",
+		c(sd.GreenX), c(sd.GreenY), c(sd.BlueX), c(sd.BlueY), c(sd.RedX), c(sd.RedY),
+		c(sd.WhitePointX), c(sd.WhitePointY), l(sd.MaxLuminance), l(sd.MinLuminance))
+}
+
+// rescaleFraction turns "num/den" into the equivalent numerator over the given denominator
+func rescaleFraction(v string, den int64) int64 {
+	parts := strings.SplitN(v, "/", 2)
+	num, err := strconv.ParseFloat(parts[0], 64)
+	if err != nil {
+		return 0
+	}
+	d := 1.0
+	if len(parts) == 2 {
+		d, err = strconv.ParseFloat(parts[1], 64)
+		if err != nil || d == 0 {
+			return 0
+		}
+	}
+	return int64(num/d*float64(den) + 0.5)
+}
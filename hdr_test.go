@@ -0,0 +1,120 @@
+package main
+
+import (
+	"io"
+	"log"
+	"os"
+	"reflect"
+	"testing"
+)
+
+const hdrProbe = `{"streams":[{"index":0,"codec_type":"video","codec_name":"hevc","color_primaries":"bt2020","color_transfer":"smpte2084","color_space":"bt2020nc",` +
+	`"side_data_list":[{"side_data_type":"Mastering display metadata","red_x":"34000/50000","red_y":"16000/50000","green_x":"13250/50000","green_y":"34500/50000",` +
+	`"blue_x":"7500/50000","blue_y":"3000/50000","white_point_x":"15635/50000","white_point_y":"16450/50000","min_luminance":"50/10000","max_luminance":"10000000/10000"},` +
+	`{"side_data_type":"Content light level metadata","max_content":1000,"max_average":400}]},{"index":1,"codec_type":"audio","codec_name":"eac3"}]}`
+
+var hdr10 = hdrInfo{
+	ColorPrimaries: "bt2020",
+	ColorTransfer:  "smpte2084",
+	ColorSpace:     "bt2020nc",
+	MasterDisplay:  "G(13250,34500)B(7500,3000)R(34000,16000)WP(15635,16450)L(10000000,50)",
+	MaxCll:         "1000,400",
+}
+
+func TestProbeHdr(t *testing.T) {
+	tests := []struct {
+		name    string
+		probe   string
+		want    hdrInfo
+		wantErr bool
+	}{
+		{"hdr10", hdrProbe, hdr10, false},
+		{"hlg without side data", `{"streams":[{"codec_type":"video","color_primaries":"bt2020","color_transfer":"arib-std-b67","color_space":"bt2020nc"}]}`,
+			hdrInfo{ColorPrimaries: "bt2020", ColorTransfer: "arib-std-b67", ColorSpace: "bt2020nc"}, false},
+		{"sdr", `{"streams":[{"codec_type":"video","color_primaries":"bt709","color_transfer":"bt709","color_space":"bt709"}]}`,
+			hdrInfo{ColorPrimaries: "bt709", ColorTransfer: "bt709", ColorSpace: "bt709"}, false},
+		{"audio only", `{"streams":[{"codec_type":"audio","codec_name":"flac"}]}`, hdrInfo{}, true},
+	}
+	for _, tt := range tests {
+		fakeFfprobe(t, "echo '"+tt.probe+"'")
+		got, err := probeHdr("in.mkv")
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: probeHdr error = %v, want error %t", tt.name, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: probeHdr = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestHdrPassthroughArgs(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	colors := []string{"-color_primaries", "bt2020", "-color_trc", "smpte2084", "-colorspace", "bt2020nc"}
+	hlg := hdrInfo{ColorPrimaries: "bt2020", ColorTransfer: "arib-std-b67", ColorSpace: "bt2020nc"}
+	tests := []struct {
+		hdr     hdrInfo
+		encoder string
+		want    []string
+	}{
+		{hdr10, "libx265", append(colors, "-x265-params", "hdr10-opt=1:repeat-headers=1:master-display="+hdr10.MasterDisplay+":max-cll=1000,400")},
+		{hdr10, "hevc_nvenc", colors},
+		{hlg, "libx265", []string{"-color_primaries", "bt2020", "-color_trc", "arib-std-b67", "-colorspace", "bt2020nc", "-x265-params", "hdr10-opt=1:repeat-headers=1"}},
+		{hlg, "libx264", []string{"-color_primaries", "bt2020", "-color_trc", "arib-std-b67", "-colorspace", "bt2020nc"}},
+	}
+	for _, tt := range tests {
+		if got := hdrPassthroughArgs(tt.hdr, tt.encoder); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("hdrPassthroughArgs(%+v, %q) = %q, want %q", tt.hdr, tt.encoder, got, tt.want)
+		}
+	}
+}
+
+func TestHdrSettings(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+	fakeFfprobe(t, "echo '"+hdrProbe+"'")
+
+	tests := []struct {
+		video       Video
+		wantArgs    bool
+		wantFilters []string
+		wantErr     bool
+	}{
+		{Video{Encoder: "libx265"}, false, nil, false},
+		{Video{Encoder: "libx265", HdrMode: "passthrough"}, true, nil, false},
+		{Video{Encoder: "libx264", HdrMode: "tonemap"}, false, []string{tonemapFilter}, false},
+		{Video{Encoder: "libx265", HdrMode: "keep"}, false, nil, true},
+	}
+	for _, tt := range tests {
+		args, filters, err := hdrSettings(tt.video, "in.mkv")
+		if (err != nil) != tt.wantErr {
+			t.Errorf("hdrSettings(%+v) error = %v, want error %t", tt.video, err, tt.wantErr)
+			continue
+		}
+		if (len(args) > 0) != tt.wantArgs || !reflect.DeepEqual(filters, tt.wantFilters) {
+			t.Errorf("hdrSettings(%+v) = %q, %q, want args %t, filters %q", tt.video, args, filters, tt.wantArgs, tt.wantFilters)
+		}
+	}
+}
+
+func TestRescaleFraction(t *testing.T) {
+	tests := []struct {
+		v    string
+		den  int64
+		want int64
+	}{
+		{"34000/50000", 50000, 34000},
+		{"17/25", 50000, 34000},
+		{"1000", 10000, 10000000},
+		{"0.005", 10000, 50},
+		{"1/0", 50000, 0},
+		{"", 50000, 0},
+	}
+	for _, tt := range tests {
+		if got := rescaleFraction(tt.v, tt.den); got != tt.want {
+			t.Errorf("rescaleFraction(%q, %d) = %d, want %d", tt.v, tt.den, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// interactiveSettings asks for the common settings on the terminal, starting from the tv-normal template,
+// and offers to save them.  It exits if stdin isn't a terminal, there'd be nobody to answer.
+func interactiveSettings() Settings {
+	if !stdinIsTerminal() {
+		fatalf("-interactive needs a terminal to ask questions on, use -settings or -profile instead")
+	}
+	return promptSettings(stdinReader, os.Stdout)
+}
+
+func promptSettings(r *bufio.Reader, w io.Writer) Settings {
+	settings := templates()["tv-normal"]
+	settings.Ready = Ready{}
+	v, a, s := &settings.Video, &settings.Audio, &settings.Subtitles
+
+	ask := func(question, def string) string {
+		fmt.Fprintf(w, "%s [%s]: ", question, def)
+		answer, err := r.ReadString('\n')
+		answer = strings.TrimSpace(answer)
+		if answer == "" {
+			if err == io.EOF {
+				fmt.Fprintln(w)
+			}
+			return def
+		}
+		return answer
+	}
+	askYesNo := func(question string, def bool) bool {
+		d := "n"
+		if def {
+			d = "y"
+		}
+		for {
+			switch strings.ToLower(ask(question+" (y/n)", d)) {
+			case "y", "yes":
+				return true
+			case "n", "no":
+				return false
+			}
+			fmt.Fprintln(w, "answer y or n")
+		}
+	}
+
+	if askYesNo("Copy the video without re-encoding it", false) {
+		v.JustCopy = true
+	} else {
+		v.Resolution = ask("Resolution (480p, 720p, 1080p, 4k, w:h, or 'keep')", v.Resolution)
+		if v.Resolution == "keep" {
+			v.Resolution = ""
+		}
+		v.Encoder = ask("Video encoder (libx264, libx265, h264_nvenc, hevc_vaapi, h264_omx...)", "libx264")
+		v.SoftwareEncode = v.Encoder != "h264_omx"
+		if v.Encoder != "h264_omx" {
+			for {
+				q, err := strconv.Atoi(ask("Quality, lower is better (crf for x264/x265, cq/qp for hardware encoders)", fmt.Sprintf("%d", v.Quality)))
+				if err == nil && q >= 0 {
+					v.Quality = q
+					break
+				}
+				fmt.Fprintln(w, "quality should be a whole number")
+			}
+			if encoderFamily(v.Encoder) != "software" {
+				v.Mode = rateControlModes[encoderFamily(v.Encoder)][0]
+			}
+		}
+	}
+
+	if askYesNo("Copy the audio without re-encoding it", false) {
+		a.JustCopy = true
+	} else {
+		a.AudioCodec = ask("Audio codec", a.AudioCodec)
+		a.AudioBitrate = ask("Audio bitrate", a.AudioBitrate)
+		a.AudioFilter = ""
+		a.Loudnorm2Pass = false
+		if askYesNo("Even out the loudness with loudnorm", true) {
+			a.AudioFilter = "loudnorm"
+			a.Loudnorm2Pass = askYesNo("Use two pass loudnorm (more accurate, reads the input twice)", true)
+		}
+	}
+
+	if !v.JustCopy && askYesNo("Burn subtitles into the video", false) {
+		s.BurnInSubtitles = true
+		s.SubtitleFile = ask("Subtitle file, or empty to use the input's own subtitles", "")
+		s.SubtitleStyle = ""
+	} else {
+		s.SubtitleFile = ""
+		s.SubtitleStyle = ""
+		s.CopySubtitles = askYesNo("Copy the input's subtitle tracks", false)
+	}
+
+	if file := ask("Save these settings to (empty to not save)", ""); file != "" {
+		writeJson(settings, file)
+		fmt.Fprintf(w, "saved, use -settings %s next time\n", file)
+	}
+	return settings
+}
@@ -0,0 +1,19 @@
+package main
+
+import "syscall"
+
+// the ioprio_set constants from linux/ioprio.h
+const (
+	ioprioWhoProcess = 1
+	ioprioClassIdle  = 3
+	ioprioClassShift = 13
+)
+
+// setIoIdle puts pid in the idle io class, so it only gets the disk when nothing else wants it
+func setIoIdle(pid int) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOPRIO_SET, ioprioWhoProcess, uintptr(pid), ioprioClassIdle<<ioprioClassShift)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package main
+
+import "errors"
+
+func setIoIdle(pid int) error {
+	return errors.New("-ionice-idle is only supported on linux")
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"os"
+)
+
+// cappedLog is a log file that's rotated once it reaches max bytes: the full file becomes file.1 (replacing
+// any older one) and logging carries on in a new, empty file.  So a log never takes more than about twice max.
+type cappedLog struct {
+	file *os.File
+	path string
+	size int64
+	max  int64
+}
+
+// newCappedLog wraps f, already opened for appending at path.  max 0 never rotates.
+func newCappedLog(f *os.File, path string, max int64) *cappedLog {
+	c := &cappedLog{file: f, path: path, max: max}
+	if fh, err := f.Stat(); err == nil {
+		c.size = fh.Size()
+	}
+	return c
+}
+
+func (c *cappedLog) Write(b []byte) (n int, err error) {
+	if c.max > 0 && c.size > 0 && c.size+int64(len(b)) > c.max {
+		c.rotate()
+	}
+	//one line bigger than the whole cap, like the output of an ffmpeg that's been stuck printing errors,
+	//only keeps its end
+	line := b
+	if c.max > 0 && int64(len(line)) > c.max {
+		line = []byte(outputTail(string(line), c.max))
+	}
+	written, err := c.file.Write(line)
+	c.size += int64(written)
+	if err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// rotate moves the full log aside and starts a new one.  If that fails it keeps writing to the old file,
+// losing log lines would be worse than going over the cap.
+func (c *cappedLog) rotate() {
+	if err := os.Rename(c.path, c.path+".1"); err != nil {
+		return
+	}
+	f, err := os.OpenFile(c.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0666)
+	if err != nil {
+		return
+	}
+	c.file.Close()
+	c.file = f
+	c.size = 0
+}
+
+func (c *cappedLog) Close() error {
+	return c.file.Close()
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func openCappedLog(t *testing.T, path string, max int64) *cappedLog {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := newCappedLog(f, path, max)
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func readLog(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestCappedLogRotates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.log")
+	if err := os.WriteFile(path, []byte("old line\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c := openCappedLog(t, path, 20)
+	for _, line := range []string{"first line\n", "second line\n", "third\n"} {
+		if n, err := c.Write([]byte(line)); err != nil || n != len(line) {
+			t.Fatalf("Write(%q) = %d, %v", line, n, err)
+		}
+	}
+	//the existing 9 bytes count towards the cap, so the second line starts a new file
+	if got, want := readLog(t, path+".1"), "old line\nfirst line\n"; got != want {
+		t.Errorf("rotated log = %q, want %q", got, want)
+	}
+	if got, want := readLog(t, path), "second line\nthird\n"; got != want {
+		t.Errorf("log = %q, want %q", got, want)
+	}
+}
+
+func TestCappedLogNoMax(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.log")
+	c := openCappedLog(t, path, 0)
+	long := strings.Repeat("x", 1000) + "\n"
+	c.Write([]byte(long))
+	c.Write([]byte(long))
+	if got := readLog(t, path); got != long+long {
+		t.Errorf("log without a max has %d bytes, want %d", len(got), 2*len(long))
+	}
+	if _, err := os.Stat(path + ".1"); err == nil {
+		t.Errorf("log without a max was rotated")
+	}
+}
+
+func TestCappedLogLongLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.log")
+	c := openCappedLog(t, path, 100)
+	long := strings.Repeat("a", 300) + "the end\n"
+	if n, err := c.Write([]byte(long)); err != nil || n != len(long) {
+		t.Fatalf("Write = %d, %v", n, err)
+	}
+	got := readLog(t, path)
+	if !strings.HasPrefix(got, "[") || !strings.HasSuffix(got, "the end\n") || strings.Count(got, "a") > 100 {
+		t.Errorf("a line longer than the cap should keep only its end, got %q", got)
+	}
+}
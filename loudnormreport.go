@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// These match the I=-16:TP=-1.5:LRA=11 targets used in the loudnorm filters
+const (
+	loudnormTargetI   = -16.0
+	loudnormTargetTp  = -1.5
+	loudnormTargetLra = 11.0
+)
+
+// writeLoudnormReport measures the finished output and writes a report comparing it to the input
+// measurement (of inStream, the audio track that was encoded) and the loudnorm targets.  It returns whether the output is within -loudnorm-tolerance.
+func writeLoudnormReport(reportFile, in, inStream, out string) (pass bool, err error) {
+	input, err := getLoudnormJson(in, inStream)
+	if err != nil {
+		return
+	}
+	achieved, err := getLoudnormJson(out, "0:a:0")
+	if err != nil {
+		return
+	}
+
+	achievedI := parseLoudness(achieved.InputI)
+	achievedTp := parseLoudness(achieved.InputTp)
+	pass = math.Abs(achievedI-loudnormTargetI) <= *loudnormTolerance && achievedTp <= loudnormTargetTp+*loudnormTolerance
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "loudnorm report\ninput:  %s\noutput: %s\n\n", in, out)
+	fmt.Fprintf(&b, "%-12s %12s %12s %12s\n", "", "input", "output", "target")
+	fmt.Fprintf(&b, "%-12s %12s %12s %12.1f LUFS\n", "integrated", input.InputI, achieved.InputI, loudnormTargetI)
+	fmt.Fprintf(&b, "%-12s %12s %12s %12.1f dBTP\n", "true peak", input.InputTp, achieved.InputTp, loudnormTargetTp)
+	fmt.Fprintf(&b, "%-12s %12s %12s %12.1f LU\n", "range", input.InputLra, achieved.InputLra, loudnormTargetLra)
+	fmt.Fprintf(&b, "%-12s %12s %12s\n", "threshold", input.InputThresh, achieved.InputThresh)
+	fmt.Fprintf(&b, "\nnormalization type: %s, target offset: %s\n", input.NormalizationType, input.TargetOffset)
+
+	result := "FAIL"
+	if pass {
+		result = "PASS"
+	}
+	fmt.Fprintf(&b, "result: %s (integrated within %.1f LU of target, true peak no more than %.1f dB over target)\n", result, *loudnormTolerance, *loudnormTolerance)
+
+	err = ioutil.WriteFile(reportFile, []byte(b.String()), 0644)
+	return
+}
+
+// parseLoudness reads one of loudnorm's values, which it reports as strings and "-inf" for silence
+func parseLoudness(value string) float64 {
+	f, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil {
+		return math.Inf(-1)
+	}
+	return f
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// mapRegex is the basic shape of an ffmpeg -map: an optional - to take streams back out, an input number, any
+// stream specifiers after it like :v:0, :a:1 or :m:language:eng, and a ? to allow it to match nothing.  A
+// [label] from a filtergraph is allowed as well.
+var mapRegex = regexp.MustCompile(`^(-?[0-9]+(:[^:\s?]+)*\??|\[[^\]\s]+\])$`)
+
+// mapArgs passes output.maps through as -map arguments, in the order given, since that's the order the streams
+// end up in the output
+func mapArgs(maps []string) (args []string) {
+	for _, m := range maps {
+		args = append(args, []string{"-map", m}...)
+	}
+	return
+}
+
+// withoutMaps drops the -map pairs from args and keeps the rest
+func withoutMaps(args []string) (kept []string) {
+	for i := 0; i < len(args); i++ {
+		if args[i] == "-map" {
+			i++
+			continue
+		}
+		kept = append(kept, args[i])
+	}
+	return
+}
+
+// checkMaps catches maps ffmpeg wouldn't understand before anything runs
+func checkMaps(settings Settings) error {
+	check := func(name string, maps []string) error {
+		for _, m := range maps {
+			if !mapRegex.MatchString(m) {
+				return fmt.Errorf("%s %q is not a stream map, they look like 0:v:0, 0:a:1 or 0:s?", name, m)
+			}
+		}
+		return nil
+	}
+	if err := check("output.maps", settings.Output.Maps); err != nil {
+		return err
+	}
+	if len(settings.Output.Maps) > 0 && len(settings.Time.Segments) > 0 {
+		return fmt.Errorf("output.maps can't be used with time.segments, the segments are joined by a filter that decides the streams")
+	}
+	for i, extra := range settings.Outputs {
+		if err := check(fmt.Sprintf("outputs[%d].maps", i), extra.Maps); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCheckMaps(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings Settings
+		wantErr  bool
+	}{
+		{"none", Settings{}, false},
+		{"streams", Settings{Output: Output{Maps: []string{"0:v:0", "0:a:1", "0:s?", "-0:a:2", "0:m:language:eng", "[out]"}}}, false},
+		{"whole input", Settings{Output: Output{Maps: []string{"1"}}}, false},
+		{"flag", Settings{Output: Output{Maps: []string{"-map 0"}}}, true},
+		{"empty", Settings{Output: Output{Maps: []string{""}}}, true},
+		{"no input", Settings{Output: Output{Maps: []string{"v:0"}}}, true},
+		{"with segments", Settings{Output: Output{Maps: []string{"0:v:0"}}, Time: Time{Segments: []Segment{{Start: "0", End: "10"}}}}, true},
+		{"extra output", Settings{Outputs: []ExtraOutput{{Suffix: "_small", Maps: []string{"0:a:x:"}}}}, true},
+	}
+	for _, tt := range tests {
+		if err := checkMaps(tt.settings); (err != nil) != tt.wantErr {
+			t.Errorf("%s: checkMaps = %v, want error %t", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestMapArgs(t *testing.T) {
+	args := mapArgs([]string{"0:v:0", "0:a:1"})
+	if want := []string{"-map", "0:v:0", "-map", "0:a:1"}; !reflect.DeepEqual(args, want) {
+		t.Errorf("mapArgs = %v, want %v", args, want)
+	}
+	kept := withoutMaps(append([]string{"-i", "in.mkv"}, append(args, "-c:v", "libx264")...))
+	if want := []string{"-i", "in.mkv", "-c:v", "libx264"}; !reflect.DeepEqual(kept, want) {
+		t.Errorf("withoutMaps = %v, want %v", kept, want)
+	}
+}
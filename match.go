@@ -0,0 +1,195 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// encoderCodecs is the codec ffprobe reports for what each encoder makes
+var encoderCodecs = map[string]string{
+	"libx264":           "h264",
+	"h264_omx":          "h264",
+	"h264_nvenc":        "h264",
+	"h264_vaapi":        "h264",
+	"h264_videotoolbox": "h264",
+	"libx265":           "hevc",
+	"hevc_nvenc":        "hevc",
+	"hevc_vaapi":        "hevc",
+	"hevc_videotoolbox": "hevc",
+	"libvpx-vp9":        "vp9",
+	"libaom-av1":        "av1",
+	"libsvtav1":         "av1",
+	"aac":               "aac",
+	"libfdk_aac":        "aac",
+	"libopus":           "opus",
+	"libvorbis":         "vorbis",
+	"vorbis":            "vorbis",
+	"libmp3lame":        "mp3",
+	"lame":              "mp3",
+	"flac":              "flac",
+	"ac3":               "ac3",
+	"eac3":              "eac3",
+	"dca":               "dts",
+}
+
+// bitrateSlack is how far over the target bitrate an input stream can be and still count as matching
+const bitrateSlack = 1.2
+
+// errAlreadyMatches is returned by processFile when -skip-if-matching skip found nothing to do.  The file is
+// skipped, not failed.
+var errAlreadyMatches = errors.New("it already matches the settings")
+
+// matchInput is for -skip-if-matching, and video.copyIfMatching and audio.copyIfMatching which do the same for
+// one stream from the settings.  It probes the input and switches the video and/or audio to justCopy when the
+// input stream is already what the settings would encode it to: the same codec, no filters that need a
+// re-encode, and no more than a bit over the target bitrate.  skip is true when both already match and
+// -skip-if-matching is skip, so there's nothing to do at all.
+func matchInput(settings Settings, in string) (matched Settings, skip bool) {
+	matched = settings
+	probe, err := probeFile(in)
+	if err != nil {
+		log.Printf("warning: unable to check if %s already matches the settings, encoding it: %v\n", in, err)
+		return
+	}
+
+	videoMatches := settings.Video.JustCopy || settings.Video.DisableVideo
+	checkVideo := *skipIfMatching != "" || settings.Video.CopyIfMatching
+	if streams := probe.streamsOfType("video"); checkVideo && !videoMatches && len(streams) > 0 {
+		why := videoMismatch(settings, streams[0])
+		videoMatches = why == ""
+		if videoMatches {
+			log.Printf("%s: video is already %s, copying it\n", in, streams[0].CodecName)
+			matched.Video.JustCopy = true
+		} else {
+			log.Printf("%s: encoding video, %s\n", in, why)
+		}
+	}
+
+	audioMatches := settings.Audio.JustCopy || settings.Audio.DisableAudio
+	checkAudio := *skipIfMatching != "" || settings.Audio.CopyIfMatching
+	if streams := probe.streamsOfType("audio"); checkAudio && !audioMatches && len(streams) > 0 {
+		//the track audioStream picks, which is the one that gets copied
+		s := streams[0]
+		if n := audioLanguageIndex(probe, settings.Audio.AudioLanguage); n >= 0 {
+			s = streams[n]
+		}
+		why := audioMismatch(settings.Audio, s)
+		audioMatches = why == ""
+		if audioMatches {
+			log.Printf("%s: audio is already %s, copying it\n", in, s.CodecName)
+			matched.Audio.JustCopy = true
+		} else {
+			log.Printf("%s: encoding audio, %s\n", in, why)
+		}
+	}
+
+	skip = videoMatches && audioMatches && *skipIfMatching == "skip"
+	return
+}
+
+// videoMismatch says why the input's video stream s can't just be copied, or "" if it can
+func videoMismatch(settings Settings, s probeStream) string {
+	v := settings.Video
+	want := encoderCodecs[videoEncoder(v)]
+	switch {
+	case want == "" || want != s.CodecName:
+		return fmt.Sprintf("it's %s, not %s", s.CodecName, videoEncoder(v))
+	case v.Resolution != "" && !resolutionMatches(v.Resolution, v.NoUpscale, s):
+		return fmt.Sprintf("it's %dx%d, not %s", s.Width, s.Height, v.Resolution)
+	case settings.Subtitles.BurnInSubtitles || len(settings.Subtitles.Burns) > 0:
+		return "subtitles are being burned in"
+	case v.HdrMode == "tonemap":
+		return "it's being tonemapped"
+	case v.Sharpen != "":
+		return "it's being sharpened"
+	case v.AspectRatio != "":
+		//copied, only the container's aspect flag would change instead of setdar's
+		return "video.aspectRatio is set with setdar"
+	case v.Profile != "" || v.Level != "":
+		//ffprobe's names for them don't line up with the encoders', so they can't be compared
+		return "video.profile and video.level need it re-encoded"
+	case v.FrameRate != "" && !frameRateMatches(v.FrameRate, s):
+		return fmt.Sprintf("it's %s fps, not %s", s.AvgFrameRate, v.FrameRate)
+	case v.VfrMode == "cfr" && s.isVfr():
+		return "it's variable frame rate and video.vfrMode is cfr"
+	case len(settings.Time.Segments) > 0:
+		return "time.segments needs it re-encoded"
+	case v.Mode == "cbr" && !bitrateMatches(v.VideoBitrate, s.BitRate):
+		return fmt.Sprintf("its bitrate %s is over %s", s.BitRate, v.VideoBitrate)
+	}
+	return ""
+}
+
+// audioMismatch says why the input's audio stream s can't just be copied, or "" if it can
+func audioMismatch(a Audio, s probeStream) string {
+	codec := a.AudioCodec
+	if codec == "" {
+		codec = "aac"
+	}
+	want := encoderCodecs[codec]
+	switch {
+	case want == "" || want != s.CodecName:
+		return fmt.Sprintf("it's %s, not %s", s.CodecName, codec)
+	case a.AudioFilter == "loudnorm":
+		return "it's being loudnormed"
+	case albumGainSet:
+		return "-album-gain is changing its volume"
+	case a.AudioOffset != 0:
+		return "it's being shifted by audio.audioOffset"
+	case a.TrimSilence != "":
+		return "its silence is being trimmed"
+	case a.AudioChannels != "" && a.AudioChannels != strconv.Itoa(s.Channels):
+		return fmt.Sprintf("it has %d channels, not %s", s.Channels, a.AudioChannels)
+	case a.SampleRate != "" && a.SampleRate != s.SampleRate:
+		return fmt.Sprintf("it's %sHz, not %sHz", s.SampleRate, a.SampleRate)
+	case a.AudioBitrate != "" && !bitrateMatches(a.AudioBitrate, s.BitRate):
+		return fmt.Sprintf("its bitrate %s is over %s", s.BitRate, a.AudioBitrate)
+	}
+	return ""
+}
+
+// resolutionMatches is true if the stream is already the size the resolution setting scales to, or
+// with noUpscale, no bigger than it
+func resolutionMatches(resolution string, noUpscale bool, s probeStream) bool {
+	if !strings.Contains(resolution, ":") {
+		var err error
+		if resolution, err = resolutionMap(resolution); err != nil {
+			//the encode reports it
+			return false
+		}
+	}
+	w, h, _ := strings.Cut(resolution, ":")
+	width, _ := strconv.Atoi(w)
+	height, _ := strconv.Atoi(h)
+	if noUpscale {
+		return s.Width <= width && s.Height <= height
+	}
+	return s.Width == width && s.Height == height
+}
+
+// frameRateMatches is true if the stream's average frame rate is within 1% of rate, the same slack isVfr allows
+func frameRateMatches(rate string, s probeStream) bool {
+	want, have := parseRate(rate), parseRate(s.AvgFrameRate)
+	if want == 0 || have == 0 {
+		return false
+	}
+	return math.Abs(want-have)/want <= 0.01
+}
+
+// bitrateMatches is true if the stream's bitrate (bits/s from ffprobe) isn't much over target (ex: 2000k).
+// Streams that don't report a bitrate, like most in mkv, can't be compared and don't match.
+func bitrateMatches(target, streamBitrate string) bool {
+	want, err := parseSize(target, 1000)
+	if err != nil {
+		return false
+	}
+	have, err := strconv.ParseInt(streamBitrate, 10, 64)
+	if err != nil {
+		return false
+	}
+	return float64(have) <= float64(want)*bitrateSlack
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestVideoMismatch(t *testing.T) {
+	h264 := probeStream{CodecName: "h264", Width: 1280, Height: 720, RFrameRate: "24000/1001", AvgFrameRate: "24000/1001", BitRate: "2000000"}
+	vfr := h264
+	vfr.AvgFrameRate = "27/1"
+	x264 := Video{Encoder: "libx264"}
+	with := func(f func(v *Video)) Settings {
+		v := x264
+		f(&v)
+		return Settings{Video: v}
+	}
+	tests := []struct {
+		name     string
+		settings Settings
+		stream   probeStream
+		mismatch bool
+	}{
+		{"same codec", Settings{Video: x264}, h264, false},
+		{"other codec", with(func(v *Video) { v.Encoder = "libx265" }), h264, true},
+		{"unknown encoder", with(func(v *Video) { v.Encoder = "mpeg4" }), probeStream{CodecName: "mpeg4"}, true},
+		{"same resolution", with(func(v *Video) { v.Resolution = "1280:720" }), h264, false},
+		{"other resolution", with(func(v *Video) { v.Resolution = "1920:1080" }), h264, true},
+		{"no upscale", with(func(v *Video) { v.Resolution = "1920:1080"; v.NoUpscale = true }), h264, false},
+		{"burn in", Settings{Video: x264, Subtitles: Subtitles{BurnInSubtitles: true}}, h264, true},
+		{"burns", Settings{Video: x264, Subtitles: Subtitles{Burns: []SubtitleBurn{{SubtitleFile: "a.ass"}}}}, h264, true},
+		{"tonemap", with(func(v *Video) { v.HdrMode = "tonemap" }), h264, true},
+		{"segments", Settings{Video: x264, Time: Time{Segments: []Segment{{Start: "0", End: "10"}}}}, h264, true},
+		{"cbr under", with(func(v *Video) { v.Mode = "cbr"; v.VideoBitrate = "2000k" }), h264, false},
+		{"cbr over", with(func(v *Video) { v.Mode = "cbr"; v.VideoBitrate = "1000k" }), h264, true},
+		{"sharpen", with(func(v *Video) { v.Sharpen = "light" }), h264, true},
+		{"aspect ratio", with(func(v *Video) { v.AspectRatio = "16:9" }), h264, true},
+		{"profile", with(func(v *Video) { v.Profile = "main" }), h264, true},
+		{"level", with(func(v *Video) { v.Level = "4.1" }), h264, true},
+		{"same frame rate", with(func(v *Video) { v.FrameRate = "23.976" }), h264, false},
+		{"other frame rate", with(func(v *Video) { v.FrameRate = "30" }), h264, true},
+		{"cfr on cfr", with(func(v *Video) { v.VfrMode = "cfr" }), h264, false},
+		{"cfr on vfr", with(func(v *Video) { v.VfrMode = "cfr" }), vfr, true},
+		{"passthrough on vfr", with(func(v *Video) { v.VfrMode = "passthrough" }), vfr, false},
+	}
+	for _, tt := range tests {
+		why := videoMismatch(tt.settings, tt.stream)
+		if (why != "") != tt.mismatch {
+			t.Errorf("%s: videoMismatch = %q, want mismatch %t", tt.name, why, tt.mismatch)
+		}
+	}
+}
+
+func TestAudioMismatch(t *testing.T) {
+	aac := probeStream{CodecName: "aac", Channels: 2, SampleRate: "48000", BitRate: "128000"}
+	tests := []struct {
+		name      string
+		audio     Audio
+		albumGain bool
+		mismatch  bool
+	}{
+		{"default aac", Audio{}, false, false},
+		{"other codec", Audio{AudioCodec: "libopus"}, false, true},
+		{"loudnorm", Audio{AudioFilter: "loudnorm"}, false, true},
+		{"album gain", Audio{}, true, true},
+		{"offset", Audio{AudioOffset: 0.5}, false, true},
+		{"trim silence", Audio{TrimSilence: "both"}, false, true},
+		{"same channels", Audio{AudioChannels: "2"}, false, false},
+		{"other channels", Audio{AudioChannels: "6"}, false, true},
+		{"same sample rate", Audio{SampleRate: "48000"}, false, false},
+		{"other sample rate", Audio{SampleRate: "44100"}, false, true},
+		{"bitrate under", Audio{AudioBitrate: "128k"}, false, false},
+		{"bitrate over", Audio{AudioBitrate: "96k"}, false, true},
+	}
+	defer func() { albumGainSet = false }()
+	for _, tt := range tests {
+		albumGainSet = tt.albumGain
+		why := audioMismatch(tt.audio, aac)
+		if (why != "") != tt.mismatch {
+			t.Errorf("%s: audioMismatch = %q, want mismatch %t", tt.name, why, tt.mismatch)
+		}
+	}
+}
+
+func TestProcessFileAlreadyMatches(t *testing.T) {
+	fakeFfprobe(t, `echo '{"streams":[{"codec_type":"video","codec_name":"h264","width":1280,"height":720},{"codec_type":"audio","codec_name":"aac","channels":2}]}'`)
+	dir := t.TempDir()
+	in := filepath.Join(dir, "in.mkv")
+	if err := os.WriteFile(in, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer func(s string) { *skipIfMatching = s }(*skipIfMatching)
+	*skipIfMatching = "skip"
+	settings := Settings{Video: Video{Encoder: "libx264"}}
+	if err := processFile(settings, in, filepath.Join(dir, "out.mkv")); !errors.Is(err, errAlreadyMatches) {
+		t.Errorf("processFile of an input that already matches = %v, want %v", err, errAlreadyMatches)
+	}
+}
+
+func TestMatchInputAudioLanguage(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+	fakeFfprobe(t, `echo '{"streams":[{"codec_type":"video","codec_name":"h264"},{"codec_type":"audio","codec_name":"ac3","channels":6,"tags":{"language":"fra"}},{"codec_type":"audio","codec_name":"aac","channels":2,"tags":{"language":"eng"}}]}'`)
+
+	tests := []struct {
+		audio    Audio
+		wantCopy bool
+	}{
+		{Audio{AudioCodec: "aac", CopyIfMatching: true}, false},
+		{Audio{AudioCodec: "aac", AudioLanguage: "eng", CopyIfMatching: true}, true},
+		{Audio{AudioCodec: "aac", AudioLanguage: "ENG", CopyIfMatching: true}, true},
+		{Audio{AudioCodec: "ac3", AudioLanguage: "eng", CopyIfMatching: true}, false},
+		{Audio{AudioCodec: "ac3", AudioLanguage: "fra", CopyIfMatching: true}, true},
+		{Audio{AudioCodec: "ac3", AudioLanguage: "jpn", CopyIfMatching: true}, true},
+	}
+	for _, tt := range tests {
+		matched, _ := matchInput(Settings{Video: Video{Encoder: "libx264"}, Audio: tt.audio}, "in.mkv")
+		if matched.Audio.JustCopy != tt.wantCopy {
+			t.Errorf("matchInput with %+v copies the audio = %t, want %t", tt.audio, matched.Audio.JustCopy, tt.wantCopy)
+		}
+	}
+}
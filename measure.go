@@ -0,0 +1,84 @@
+package main
+
+import (
+	"log"
+	"os"
+	"sync"
+)
+
+// needsLoudnormMeasurement is whether encoding in with settings starts with a loudnorm analysis pass
+func needsLoudnormMeasurement(settings Settings, in string) bool {
+	a := settings.Audio
+	return a.AudioFilter == "loudnorm" && a.Loudnorm2Pass && !a.JustCopy && !a.DisableAudio && !isLiveURL(in)
+}
+
+type measureJob struct {
+	File   string
+	Stream string
+}
+
+// measureBatch runs the loudnorm analysis for every job that will need it before any encoding starts,
+// -measure-jobs at a time since they only decode.  The results go in the loudnorm cache, where the encodes
+// pick them up.  A file that fails to measure is left out, and is measured again when it's encoded.  If that
+// fails too the file fails while preparing its encode, with the measurement's error, and the batch goes on.
+func measureBatch(jobs []batchJob, settings Settings) {
+	var pending []measureJob
+	for _, job := range jobs {
+		if _, err := os.Stat(job.Out); err == nil && !*overwrite {
+			continue
+		}
+		jobSettings, _, err := sidecarSettings(settings, job.In)
+		if err != nil || !needsLoudnormMeasurement(jobSettings, job.In) {
+			continue
+		}
+		pending = append(pending, measureJob{File: job.In, Stream: audioStream(jobSettings.Audio, job.In)})
+	}
+	if len(pending) == 0 {
+		return
+	}
+	measureAll(pending)
+}
+
+// measureAll runs the loudnorm analysis of pending, -measure-jobs at a time, into the loudnorm cache.  Ones
+// already in the cache aren't measured again.
+func measureAll(pending []measureJob) {
+	var todo []measureJob
+	for _, m := range pending {
+		if _, ok := cachedLoudnorm(m.File, m.Stream); !ok {
+			todo = append(todo, m)
+		}
+	}
+	pending = todo
+	if len(pending) == 0 {
+		return
+	}
+
+	workers := *measureJobs
+	if workers < 1 {
+		workers = 1
+	}
+	log.Printf("measuring loudness of %d files, %d at a time, before encoding\n", len(pending), workers)
+
+	queue := make(chan measureJob)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for m := range queue {
+				lnJson, err := measureLoudnorm(m.File, m.Stream)
+				if err != nil {
+					log.Printf("warning: %v\n", err)
+					continue
+				}
+				cacheLoudnorm(m.File, m.Stream, lnJson)
+				log.Printf("measured %s: %s LUFS\n", m.File, lnJson.InputI)
+			}
+		}()
+	}
+	for _, m := range pending {
+		queue <- m
+	}
+	close(queue)
+	wg.Wait()
+}
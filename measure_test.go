@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestNeedsLoudnormMeasurement(t *testing.T) {
+	twoPass := Audio{AudioFilter: "loudnorm", Loudnorm2Pass: true}
+	with := func(f func(a *Audio)) Settings {
+		a := twoPass
+		f(&a)
+		return Settings{Audio: a}
+	}
+	tests := []struct {
+		name     string
+		settings Settings
+		in       string
+		want     bool
+	}{
+		{"two pass", Settings{Audio: twoPass}, "in.mkv", true},
+		{"single pass", with(func(a *Audio) { a.Loudnorm2Pass = false }), "in.mkv", false},
+		{"no loudnorm", with(func(a *Audio) { a.AudioFilter = "" }), "in.mkv", false},
+		{"copied", with(func(a *Audio) { a.JustCopy = true }), "in.mkv", false},
+		{"no audio", with(func(a *Audio) { a.DisableAudio = true }), "in.mkv", false},
+		{"live", Settings{Audio: twoPass}, "rtmp://example.com/live", false},
+	}
+	for _, tt := range tests {
+		if got := needsLoudnormMeasurement(tt.settings, tt.in); got != tt.want {
+			t.Errorf("%s: needsLoudnormMeasurement = %t, want %t", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestGetLoudnormJson(t *testing.T) {
+	defer func(bin string) { ffmpegBin = bin }(ffmpegBin)
+	ffmpegBin = "/nonexistent/ffmpeg"
+
+	cached := loudnormValues{InputI: "-23.1", OutputI: "-16.0"}
+	cacheLoudnorm("cached.mkv", "0:a:0", cached)
+	got, err := getLoudnormJson("cached.mkv", "0:a:0")
+	if err != nil || got != cached {
+		t.Errorf("getLoudnormJson of a cached measurement = %+v, %v, want %+v", got, err, cached)
+	}
+	//already cached, so there's nothing to run
+	measureAll([]measureJob{{File: "cached.mkv", Stream: "0:a:0"}})
+
+	if _, err := getLoudnormJson("cached.mkv", "0:a:1"); err == nil {
+		t.Errorf("getLoudnormJson with no ffmpeg to measure with: got no error")
+	}
+	if _, ok := cachedLoudnorm("cached.mkv", "0:a:1"); ok {
+		t.Errorf("a failed measurement was cached")
+	}
+}
+
+func TestLoudnormFilter(t *testing.T) {
+	//the second pass is told what the first measured of the input, the output values are what it would have made
+	lnJson := loudnormValues{
+		InputI: "-16.2", InputLra: "5.1", InputTp: "-1.9", InputThresh: "-26.4",
+		OutputI: "-15.9", OutputLra: "4.8", OutputTp: "-1.5", OutputThresh: "-26.0",
+		TargetOffset: "0.2",
+	}
+	tests := []struct {
+		audio Audio
+		want  string
+	}{
+		{Audio{}, "loudnorm=I=-16:TP=-1.5:LRA=11:measured_I=-16.2:measured_LRA=5.1:measured_TP=-1.9:measured_thresh=-26.4:offset=0.2:linear=true"},
+		{Audio{LoudnormDynamic: true}, "loudnorm=I=-16:TP=-1.5:LRA=11:measured_I=-16.2:measured_LRA=5.1:measured_TP=-1.9:measured_thresh=-26.4:offset=0.2:linear=false"},
+		{Audio{LoudnormDualMono: true}, "loudnorm=I=-16:TP=-1.5:LRA=11:measured_I=-16.2:measured_LRA=5.1:measured_TP=-1.9:measured_thresh=-26.4:offset=0.2:linear=true:dual_mono=true"},
+	}
+	for _, tt := range tests {
+		if got := loudnormFilter(tt.audio, lnJson); got != tt.want {
+			t.Errorf("loudnormFilter(%+v) = %q, want %q", tt.audio, got, tt.want)
+		}
+	}
+
+	//-loudnorm-report and -album-gain judge the output against these
+	targets := fmt.Sprintf("loudnorm=I=%g:TP=%g:LRA=%g:", loudnormTargetI, loudnormTargetTp, loudnormTargetLra)
+	if got := loudnormFilter(Audio{}, lnJson); !strings.HasPrefix(got, targets) {
+		t.Errorf("loudnormFilter = %q, want the targets %q", got, targets)
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// runResult is what -notify reports about a finished run, single file or batch
+type runResult struct {
+	Status    string  `json:"status"`
+	ExitCode  int     `json:"exitCode"`
+	Input     string  `json:"input"`
+	Output    string  `json:"output"`
+	Processed int     `json:"processed"`
+	Skipped   int     `json:"skipped"`
+	Failed    int     `json:"failed"`
+	TimedOut  int     `json:"timedOut"`
+	Seconds   float64 `json:"seconds"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// runStart is when this run started, for runResult.Seconds
+var runStart = time.Now()
+
+// notifyRun tells -notify how the run went.  An http(s) url gets the result POSTed to it as json, anything
+// else is run as a shell command with the result in FFMPEGFRONT_* environment variables.  A notification that
+// fails is only a warning, it doesn't change how the run went.
+func notifyRun(r runResult) {
+	if *notifyFlag == "" {
+		return
+	}
+	r.Status = "ok"
+	if r.ExitCode != 0 {
+		r.Status = "failed"
+	}
+	r.Seconds = time.Since(runStart).Round(time.Millisecond).Seconds()
+	data, err := json.Marshal(r)
+	if err != nil {
+		log.Printf("warning: unable to build the -notify result: %v\n", err)
+		return
+	}
+
+	if strings.HasPrefix(*notifyFlag, "http://") || strings.HasPrefix(*notifyFlag, "https://") {
+		err = postResult(*notifyFlag, data)
+	} else {
+		err = runNotifyCommand(*notifyFlag, r, data)
+	}
+	if err != nil {
+		log.Printf("warning: -notify failed: %v\n", err)
+	}
+}
+
+// exitRun tells -notify how the run went and exits with r's exit code
+func exitRun(r runResult) {
+	notifyRun(r)
+	removeTempDir()
+	os.Exit(r.ExitCode)
+}
+
+// fatalf logs why the run can't go on and exits 1.  Every failure that ends the run comes through here or
+// exitRun, so -notify hears about bad flags and settings as well as failed encodes.
+func fatalf(format string, a ...interface{}) {
+	msg := strings.TrimSuffix(fmt.Sprintf(format, a...), "\n")
+	log.Println(msg)
+	r := runResult{ExitCode: 1, Input: *inFile, Output: *outFile, Error: msg}
+	if *inFile == "" {
+		r.Input, r.Output = *inDir, *outDir
+	}
+	exitRun(r)
+}
+
+func postResult(url string, data []byte) error {
+	client := http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s answered %s", url, resp.Status)
+	}
+	return nil
+}
+
+// runNotifyCommand runs command through the shell, so it can be a whole command line like 'notify-send done'
+func runNotifyCommand(command string, r runResult, data []byte) error {
+	cmd := exec.Command("sh", "-c", command)
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", command)
+	}
+	cmd.Env = append(os.Environ(),
+		"FFMPEGFRONT_STATUS="+r.Status,
+		fmt.Sprintf("FFMPEGFRONT_EXIT_CODE=%d", r.ExitCode),
+		"FFMPEGFRONT_INPUT="+r.Input,
+		"FFMPEGFRONT_OUTPUT="+r.Output,
+		fmt.Sprintf("FFMPEGFRONT_PROCESSED=%d", r.Processed),
+		fmt.Sprintf("FFMPEGFRONT_SKIPPED=%d", r.Skipped),
+		fmt.Sprintf("FFMPEGFRONT_FAILED=%d", r.Failed),
+		fmt.Sprintf("FFMPEGFRONT_TIMED_OUT=%d", r.TimedOut),
+		fmt.Sprintf("FFMPEGFRONT_SECONDS=%.3f", r.Seconds),
+		"FFMPEGFRONT_ERROR="+r.Error,
+		"FFMPEGFRONT_RESULT="+string(data),
+	)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
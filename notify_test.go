@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNotifyRunPost(t *testing.T) {
+	var got runResult
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("notify sent %s %s, want a json POST", r.Method, r.Header.Get("Content-Type"))
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("notify body isn't a runResult: %v", err)
+		}
+	}))
+	defer server.Close()
+	defer func(n string) { *notifyFlag = n }(*notifyFlag)
+	*notifyFlag = server.URL
+
+	tests := []struct {
+		result runResult
+		status string
+	}{
+		{runResult{Input: "in", Output: "out", Processed: 3, Skipped: 1}, "ok"},
+		{runResult{ExitCode: 1, Input: "in", Output: "out", Failed: 1, Error: "boom"}, "failed"},
+	}
+	for _, tt := range tests {
+		got = runResult{}
+		notifyRun(tt.result)
+		want := tt.result
+		want.Status, want.Seconds = tt.status, got.Seconds
+		if got != want {
+			t.Errorf("notifyRun posted %+v, want %+v", got, want)
+		}
+	}
+}
+
+func TestPostResultStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+	if err := postResult(server.URL, []byte("{}")); err == nil {
+		t.Errorf("postResult to a server answering 500: got no error")
+	}
+}
+
+func TestRunNotifyCommand(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "env")
+	r := runResult{Status: "failed", ExitCode: 1, Input: "/in", Output: "/out", Processed: 2, Skipped: 1, Failed: 1, TimedOut: 0, Seconds: 1.5, Error: "boom"}
+	command := `echo "$FFMPEGFRONT_STATUS $FFMPEGFRONT_EXIT_CODE $FFMPEGFRONT_INPUT $FFMPEGFRONT_OUTPUT $FFMPEGFRONT_PROCESSED $FFMPEGFRONT_SKIPPED $FFMPEGFRONT_FAILED $FFMPEGFRONT_TIMED_OUT $FFMPEGFRONT_SECONDS $FFMPEGFRONT_ERROR $FFMPEGFRONT_RESULT" > ` + file
+	if err := runNotifyCommand(command, r, []byte(`{"status":"failed"}`)); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `failed 1 /in /out 2 1 1 0 1.500 boom {"status":"failed"}`
+	if got := strings.TrimSpace(string(data)); got != want {
+		t.Errorf("runNotifyCommand environment = %q, want %q", got, want)
+	}
+
+	if err := runNotifyCommand("exit 3", r, nil); err == nil {
+		t.Errorf("runNotifyCommand of a failing command: got no error")
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var outNameRegex = regexp.MustCompile(`\{([^{}]*)\}`)
+
+// outNameValues are what -outname's placeholders turn into for in.  resolution and codec are what the
+// settings make, and what the input already is when they leave it alone.  They're functions so the input
+// is only probed if the name needs it.
+func outNameValues(settings Settings, in string) map[string]func() string {
+	base := filepath.Base(in)
+	inputVideo := func() (s probeStream) {
+		probe, err := probeFile(in)
+		if err != nil {
+			log.Printf("warning: unable to probe %s for -outname: %v\n", in, err)
+		}
+		if streams := probe.streamsOfType("video"); len(streams) > 0 {
+			s = streams[0]
+		}
+		return
+	}
+	v := settings.Video
+
+	return map[string]func() string{
+		"basename": func() string { return strings.TrimSuffix(base, filepath.Ext(base)) },
+		"ext":      func() string { return strings.TrimPrefix(filepath.Ext(base), ".") },
+		"date":     func() string { return time.Now().Format("2006-01-02") },
+		"resolution": func() string {
+			switch {
+			case v.DisableVideo:
+				return "none"
+			case v.Resolution != "" && !v.JustCopy:
+				//1280:720 would be an illegal file name on windows
+				return strings.Replace(v.Resolution, ":", "x", 1)
+			}
+			if s := inputVideo(); s.Height > 0 {
+				return fmt.Sprintf("%dp", s.Height)
+			}
+			return "unknown"
+		},
+		"codec": func() string {
+			switch encoder := encoderUsed(v); encoder {
+			case "none":
+				return "none"
+			case "copy":
+				if s := inputVideo(); s.CodecName != "" {
+					return s.CodecName
+				}
+				return "unknown"
+			default:
+				if codec, ok := encoderCodecs[encoder]; ok {
+					return codec
+				}
+				return encoder
+			}
+		},
+	}
+}
+
+// checkOutName catches placeholders -outname doesn't know before any file is processed
+func checkOutName(template string) error {
+	known := outNameValues(Settings{}, "")
+	for _, m := range outNameRegex.FindAllStringSubmatch(template, -1) {
+		if _, ok := known[m[1]]; !ok {
+			return fmt.Errorf("-outname has an unknown placeholder {%s}, it can use {basename}, {ext}, {resolution}, {codec} and {date}", m[1])
+		}
+	}
+	if rest := outNameRegex.ReplaceAllString(template, ""); strings.ContainsAny(rest, "{}") {
+		return fmt.Errorf("-outname %q has a { or } that isn't part of a placeholder", template)
+	}
+	return nil
+}
+
+// expandOutName fills in template's placeholders
+func expandOutName(template string, values map[string]func() string) string {
+	return outNameRegex.ReplaceAllStringFunc(template, func(m string) string {
+		return values[strings.Trim(m, "{}")]()
+	})
+}
+
+// uniqueOutPath keeps two inputs from being written to the same output.  Any output that's already taken
+// gets _2, _3 and so on added until it isn't.
+func uniqueOutPath(out string, used map[string]bool) string {
+	ext := filepath.Ext(out)
+	unique := out
+	for n := 2; used[unique]; n++ {
+		unique = fmt.Sprintf("%s_%d%s", strings.TrimSuffix(out, ext), n, ext)
+	}
+	used[unique] = true
+	return unique
+}
+
+// nameBatchJobs renames every job's output with -outname, in the same directory under -outdir it would
+// have had.  Each file's name uses its own settings, sidecar and all.
+func nameBatchJobs(jobs []batchJob, settings Settings, template string) {
+	used := map[string]bool{}
+	for i, job := range jobs {
+		jobSettings, _, err := sidecarSettings(settings, job.In)
+		if err != nil {
+			//runBatch reports the bad sidecar when it gets to this file
+			jobSettings = settings
+		}
+		out := batchOutPath(filepath.Join(filepath.Dir(job.Out), expandOutName(template, outNameValues(jobSettings, job.In))))
+		jobs[i].Out = uniqueOutPath(out, used)
+		if jobs[i].Out != out {
+			log.Printf("warning: %s would be written to %s like an earlier file, writing it to %s instead\n", job.In, out, jobs[i].Out)
+		}
+	}
+}
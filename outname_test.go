@@ -0,0 +1,56 @@
+package main
+
+import "testing"
+
+func TestCheckOutName(t *testing.T) {
+	tests := []struct {
+		template string
+		wantErr  bool
+	}{
+		{"{basename}.mp4", false},
+		{"{basename}_{resolution}_{codec}_{date}.{ext}", false},
+		{"plain.mkv", false},
+		{"{title}.mp4", true},
+		{"{basename.mp4", true},
+		{"{basename}}.mp4", true},
+	}
+	for _, tt := range tests {
+		if err := checkOutName(tt.template); (err != nil) != tt.wantErr {
+			t.Errorf("checkOutName(%q) = %v, want error %t", tt.template, err, tt.wantErr)
+		}
+	}
+}
+
+func TestExpandOutName(t *testing.T) {
+	tests := []struct {
+		template string
+		video    Video
+		want     string
+	}{
+		{"{basename}.mp4", Video{}, "my movie.mp4"},
+		{"{basename}_copy.{ext}", Video{}, "my movie_copy.mkv"},
+		{"{basename}_{resolution}.mp4", Video{Resolution: "1280:720"}, "my movie_1280x720.mp4"},
+		{"{basename}_{resolution}.m4a", Video{DisableVideo: true}, "my movie_none.m4a"},
+		{"{basename}_{codec}.mkv", Video{Encoder: "libx265"}, "my movie_hevc.mkv"},
+		{"{basename}_{codec}.mkv", Video{SoftwareEncode: true}, "my movie_h264.mkv"},
+		{"{basename}_{codec}.mkv", Video{Encoder: "mpeg4"}, "my movie_mpeg4.mkv"},
+	}
+	for _, tt := range tests {
+		values := outNameValues(Settings{Video: tt.video}, "/videos/my movie.mkv")
+		if got := expandOutName(tt.template, values); got != tt.want {
+			t.Errorf("expandOutName(%q) with %+v = %q, want %q", tt.template, tt.video, got, tt.want)
+		}
+	}
+}
+
+func TestUniqueOutPath(t *testing.T) {
+	used := map[string]bool{}
+	for _, want := range []string{"out/a.mp4", "out/a_2.mp4", "out/a_3.mp4"} {
+		if got := uniqueOutPath("out/a.mp4", used); got != want {
+			t.Errorf("uniqueOutPath(out/a.mp4) = %q, want %q", got, want)
+		}
+	}
+	if got := uniqueOutPath("out/b.mp4", used); got != "out/b.mp4" {
+		t.Errorf("uniqueOutPath(out/b.mp4) = %q, want out/b.mp4", got)
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// stdinReader is shared by every question asked on the terminal, so an answer typed ahead isn't lost in a
+// reader that's thrown away
+var stdinReader = bufio.NewReader(os.Stdin)
+
+// errNotOverwriting is returned by processFile when the answer to -confirm-overwrite was no.  The file is
+// skipped, not failed, and isn't marked completed.
+var errNotOverwriting = errors.New("not overwriting")
+
+// stdinIsTerminal reports whether there's someone at a terminal to answer questions
+func stdinIsTerminal() bool {
+	fh, err := os.Stdin.Stat()
+	return err == nil && fh.Mode()&os.ModeCharDevice != 0
+}
+
+// existingOutputs are the outputs that are already there and would be overwritten
+func existingOutputs(outs []string) (existing []string) {
+	for _, o := range outs {
+		if isStdout(o) {
+			continue
+		}
+		if _, err := os.Stat(o); err == nil {
+			existing = append(existing, o)
+		}
+	}
+	return
+}
+
+// confirmOverwrite asks whether existing can be overwritten, for -confirm-overwrite.  Anything but yes is
+// a no, including no answer at all.
+func confirmOverwrite(r *bufio.Reader, w io.Writer, existing []string) bool {
+	what := existing[0] + " already exists"
+	if len(existing) > 1 {
+		what = strings.Join(existing, ", ") + " already exist"
+	}
+	for {
+		fmt.Fprintf(w, "%s, overwrite? (y/n) [n]: ", what)
+		answer, err := r.ReadString('\n')
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "y", "yes":
+			return true
+		case "", "n", "no":
+			if err == io.EOF {
+				fmt.Fprintln(w)
+			}
+			return false
+		}
+		if err != nil {
+			fmt.Fprintln(w)
+			return false
+		}
+		fmt.Fprintln(w, "answer y or n")
+	}
+}
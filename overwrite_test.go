@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestConfirmOverwrite(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    bool
+		prompts int
+	}{
+		{"y\n", true, 1},
+		{"YES\n", true, 1},
+		{"n\n", false, 1},
+		{"\n", false, 1},
+		{"", false, 1},
+		{"y", true, 1},
+		{"maybe\ny\n", true, 2},
+		{"maybe\n", false, 2},
+		{"what\nhuh\nno\n", false, 3},
+	}
+	for _, tt := range tests {
+		var w bytes.Buffer
+		got := confirmOverwrite(bufio.NewReader(strings.NewReader(tt.input)), &w, []string{"out.mp4"})
+		if got != tt.want {
+			t.Errorf("confirmOverwrite(%q) = %t, want %t", tt.input, got, tt.want)
+		}
+		if prompts := strings.Count(w.String(), "overwrite? (y/n) [n]: "); prompts != tt.prompts {
+			t.Errorf("confirmOverwrite(%q) asked %d times, want %d:\n%s", tt.input, prompts, tt.prompts, w.String())
+		}
+	}
+}
+
+func TestConfirmOverwritePrompt(t *testing.T) {
+	var w bytes.Buffer
+	confirmOverwrite(bufio.NewReader(strings.NewReader("n\n")), &w, []string{"a.mp4", "a.srt"})
+	if want := "a.mp4, a.srt already exist, overwrite? (y/n) [n]: "; w.String() != want {
+		t.Errorf("confirmOverwrite asked %q, want %q", w.String(), want)
+	}
+}
+
+func TestExistingOutputs(t *testing.T) {
+	dir := t.TempDir()
+	there := filepath.Join(dir, "there.mp4")
+	if err := os.WriteFile(there, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	outs := []string{there, filepath.Join(dir, "missing.mp4"), "-"}
+	if got, want := existingOutputs(outs), []string{there}; !reflect.DeepEqual(got, want) {
+		t.Errorf("existingOutputs(%q) = %q, want %q", outs, got, want)
+	}
+}
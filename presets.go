@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// defaultPresetDir is where -profile looks for user presets when -preset-dir isn't given
+func defaultPresetDir() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "ffmpegfront", "presets")
+}
+
+// loadPreset finds a preset by name, exiting if there isn't one or it can't be read
+func loadPreset(name string) (settings Settings) {
+	settings, err := findPreset(name)
+	if err != nil {
+		fatalf("%v\n", describeError(err))
+	}
+	return
+}
+
+// findPreset finds a preset by name, first as <preset-dir>/<name>.json, then among the built-in templates
+func findPreset(name string) (settings Settings, err error) {
+	if name == "" || strings.ContainsAny(name, `/\`) {
+		err = fmt.Errorf("preset name %q can't be empty or contain a path, put the file in -preset-dir and use its name without .json", name)
+		return
+	}
+
+	if *presetDir != "" {
+		file := filepath.Join(*presetDir, name+".json")
+		if _, statErr := os.Stat(file); statErr == nil {
+			return readSettings(file)
+		}
+	}
+
+	if builtIn, ok := templates()[name]; ok && name != "template" {
+		return builtIn, nil
+	}
+
+	err = fmt.Errorf("no preset named %s in %s or the built-in templates. Available: %s", name, *presetDir, strings.Join(presetNames(), ", "))
+	return
+}
+
+// userPresets lists the names of the presets in -preset-dir
+func userPresets() (names []string) {
+	if *presetDir == "" {
+		return
+	}
+	files, err := filepath.Glob(filepath.Join(*presetDir, "*.json"))
+	if err != nil {
+		return
+	}
+	for _, f := range files {
+		names = append(names, strings.TrimSuffix(filepath.Base(f), ".json"))
+	}
+	sort.Strings(names)
+	return
+}
+
+// presetNames is every name -profile accepts
+func presetNames() (names []string) {
+	names = userPresets()
+	for name := range templates() {
+		if name != "template" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestFindPreset(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "team-1080p.json"), []byte(`{"video":{"resolution":"1080p","crf":"20"}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "movie.json"), []byte(`{"video":{"resolution":"4k"}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "broken.json"), []byte(`{"video":`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer func(d string) { *presetDir = d }(*presetDir)
+	*presetDir = dir
+
+	tests := []struct {
+		name           string
+		wantResolution string
+		wantErr        bool
+	}{
+		{"team-1080p", "1080p", false},
+		{"movie", "4k", false},
+		{"tv-high", templates()["tv-high"].Video.Resolution, false},
+		{"template", "", true},
+		{"missing", "", true},
+		{"", "", true},
+		{"../team-1080p", "", true},
+		{"broken", "", true},
+	}
+	for _, tt := range tests {
+		got, err := findPreset(tt.name)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("findPreset(%q) error = %v, want error %t", tt.name, err, tt.wantErr)
+			continue
+		}
+		if got.Video.Resolution != tt.wantResolution {
+			t.Errorf("findPreset(%q) resolution = %q, want %q", tt.name, got.Video.Resolution, tt.wantResolution)
+		}
+	}
+
+	var settingsErr *SettingsError
+	if _, err := findPreset("broken"); !errors.As(err, &settingsErr) {
+		t.Errorf("findPreset(%q) = %v, want a SettingsError", "broken", err)
+	}
+}
+
+func TestFindPresetWithoutPresetDir(t *testing.T) {
+	defer func(d string) { *presetDir = d }(*presetDir)
+	*presetDir = ""
+
+	got, err := findPreset("movie")
+	if err != nil {
+		t.Fatalf("findPreset(%q) = %v", "movie", err)
+	}
+	if want := templates()["movie"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("findPreset(%q) = %+v, want %+v", "movie", got, want)
+	}
+	if _, err := findPreset("team-1080p"); err == nil {
+		t.Errorf("findPreset(%q) with no -preset-dir = nil error, want one", "team-1080p")
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// previewFilter picks the frames and size shared by both gif passes, lanczos keeps small previews sharp
+func previewFilter(fps, width int) string {
+	return fmt.Sprintf("fps=%d,scale=%d:-2:flags=lanczos", fps, width)
+}
+
+// previewCut is the part of the input the preview is made from, seeking before -i so it's quick on long files
+func previewCut(start, duration time.Duration) []string {
+	return []string{"-ss", fmt.Sprintf("%.3f", start.Seconds()), "-t", fmt.Sprintf("%.3f", duration.Seconds())}
+}
+
+// gifPaletteArgs is the first gif pass, which builds a 256 colour palette from the preview's own frames.
+// Without it ffmpeg uses a generic palette and gifs come out banded and dithered.
+func gifPaletteArgs(in, palette string, start, duration time.Duration, fps, width int) (args []string) {
+	args = append(args, "-hide_banner")
+	args = append(args, previewCut(start, duration)...)
+	args = append(args, "-i", in, "-vf", previewFilter(fps, width)+",palettegen=stats_mode=diff", "-y", palette)
+	return
+}
+
+// gifEncodeArgs is the second gif pass, mapping the frames onto the palette from the first
+func gifEncodeArgs(in, palette, out string, start, duration time.Duration, fps, width int) (args []string) {
+	args = append(args, "-hide_banner")
+	args = append(args, previewCut(start, duration)...)
+	args = append(args, "-i", in, "-i", palette,
+		"-lavfi", fmt.Sprintf("%s[x];[x][1:v]paletteuse=dither=bayer:bayer_scale=5:diff_mode=rectangle", previewFilter(fps, width)),
+		"-an", "-loop", "0", "-y", out)
+	return
+}
+
+// webpArgs makes an animated webp in one go, it isn't limited to 256 colours so there's no palette pass
+func webpArgs(in, out string, start, duration time.Duration, fps, width int) (args []string) {
+	args = append(args, "-hide_banner")
+	args = append(args, previewCut(start, duration)...)
+	args = append(args, "-i", in, "-vf", previewFilter(fps, width), "-c:v", "libwebp", "-lossless", "0", "-quality", "75",
+		"-an", "-loop", "0", "-y", out)
+	return
+}
+
+// animatedPreviewCommands returns the ffmpeg runs that make out, picking gif or webp from its extension
+func animatedPreviewCommands(in, out, palette string) (commands [][]string, err error) {
+	if *previewDuration <= 0 || *previewFps <= 0 || *previewWidth <= 0 {
+		return nil, fmt.Errorf("-preview-duration, -preview-fps and -preview-width all need to be more than 0")
+	}
+	switch strings.ToLower(filepath.Ext(out)) {
+	case ".gif":
+		commands = append(commands, gifPaletteArgs(in, palette, *previewStart, *previewDuration, *previewFps, *previewWidth))
+		commands = append(commands, gifEncodeArgs(in, palette, out, *previewStart, *previewDuration, *previewFps, *previewWidth))
+	case ".webp":
+		commands = append(commands, webpArgs(in, out, *previewStart, *previewDuration, *previewFps, *previewWidth))
+	default:
+		err = fmt.Errorf("-animated-preview makes .gif or .webp files, not %s", out)
+	}
+	return
+}
+
+// animatedPreview makes a short animated gif or webp of -infile for library thumbnails.  The gif palette
+// goes in a directory under -tmpdir that's removed afterwards.
+func animatedPreview(in, out string) {
+	dir, err := os.MkdirTemp(runTempDir, "ffmpegfront-preview-")
+	if err != nil {
+		fatalf("unable to make a directory for the palette: %v\n", err)
+	}
+	defer os.RemoveAll(dir)
+
+	commands, err := animatedPreviewCommands(in, out, filepath.Join(dir, "palette.png"))
+	if err != nil {
+		os.RemoveAll(dir)
+		fatalf("%v\n", err)
+	}
+	for _, args := range commands {
+		if *argsOnly {
+			fmt.Println(commandLine(ffmpegBin, args))
+			continue
+		}
+		output, err := exec.Command(ffmpegBin, args...).CombinedOutput()
+		if err != nil {
+			os.RemoveAll(dir)
+			fatalf("unable to make the preview %s: %v\n%s", out, err, output)
+		}
+	}
+	if !*argsOnly {
+		log.Printf("wrote preview %s\n", out)
+	}
+}
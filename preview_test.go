@@ -0,0 +1,33 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAnimatedPreviewCommands(t *testing.T) {
+	tests := []struct {
+		out     string
+		want    [][]string
+		wantErr bool
+	}{
+		{"thumb.gif", [][]string{
+			{"-hide_banner", "-ss", "60.000", "-t", "3.000", "-i", "in.mkv", "-vf", "fps=10,scale=480:-2:flags=lanczos,palettegen=stats_mode=diff", "-y", "palette.png"},
+			{"-hide_banner", "-ss", "60.000", "-t", "3.000", "-i", "in.mkv", "-i", "palette.png", "-lavfi", "fps=10,scale=480:-2:flags=lanczos[x];[x][1:v]paletteuse=dither=bayer:bayer_scale=5:diff_mode=rectangle", "-an", "-loop", "0", "-y", "thumb.gif"},
+		}, false},
+		{"thumb.WEBP", [][]string{
+			{"-hide_banner", "-ss", "60.000", "-t", "3.000", "-i", "in.mkv", "-vf", "fps=10,scale=480:-2:flags=lanczos", "-c:v", "libwebp", "-lossless", "0", "-quality", "75", "-an", "-loop", "0", "-y", "thumb.WEBP"},
+		}, false},
+		{"thumb.png", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := animatedPreviewCommands("in.mkv", tt.out, "palette.png")
+		if (err != nil) != tt.wantErr {
+			t.Errorf("animatedPreviewCommands(%s) error = %v, want error %t", tt.out, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("animatedPreviewCommands(%s) = %v, want %v", tt.out, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"log"
+	"os/exec"
+)
+
+// runLowPriority runs cmd like cmd.Run, but drops it to -nice and -ionice-idle as soon as it's started.
+// Threads ffmpeg starts afterwards inherit the lower priority.  If the priority can't be changed (other
+// platforms, or raising it back up without root) the command still runs, with a warning.
+func runLowPriority(cmd *exec.Cmd) error {
+	err := cmd.Start()
+	if err != nil {
+		return err
+	}
+
+	if *niceLevel != 0 {
+		if err := setNice(cmd.Process.Pid, *niceLevel); err != nil {
+			log.Printf("warning: unable to set -nice %d on ffmpeg: %v\n", *niceLevel, err)
+		}
+	}
+	if *ioniceIdle {
+		if err := setIoIdle(cmd.Process.Pid); err != nil {
+			log.Printf("warning: unable to set -ionice-idle on ffmpeg: %v\n", err)
+		}
+	}
+	return cmd.Wait()
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package main
+
+import "errors"
+
+func setNice(pid, n int) error {
+	return errors.New("-nice is only supported on unix systems")
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type probeData struct {
+	Streams []probeStream `json:"streams"`
+	Format  probeFormat   `json:"format"`
+}
+
+type probeStream struct {
+	Index        int               `json:"index"`
+	CodecName    string            `json:"codec_name"`
+	CodecType    string            `json:"codec_type"`
+	Width        int               `json:"width"`
+	Height       int               `json:"height"`
+	RFrameRate   string            `json:"r_frame_rate"`
+	AvgFrameRate string            `json:"avg_frame_rate"`
+	Channels     int               `json:"channels"`
+	SampleRate   string            `json:"sample_rate"`
+	BitRate      string            `json:"bit_rate"`
+	Disposition  map[string]int    `json:"disposition"`
+	Tags         map[string]string `json:"tags"`
+	//color info and side data are what probeHdr looks at
+	ColorPrimaries string          `json:"color_primaries"`
+	ColorTransfer  string          `json:"color_transfer"`
+	ColorSpace     string          `json:"color_space"`
+	SideDataList   []probeSideData `json:"side_data_list"`
+}
+
+type probeFormat struct {
+	FormatName string `json:"format_name"`
+	Duration   string `json:"duration"`
+	Size       string `json:"size"`
+	BitRate    string `json:"bit_rate"`
+}
+
+// probeCache keeps each file's ffprobe results for the run, so the many settings that look at the input only
+// run ffprobe once.  The file's size and modification time are part of the key, so a file that's been written
+// since (an output, or an input that was still being copied) is probed again.  Failed probes aren't kept.
+// measureAll probes from several goroutines, so it's only used through probeFile.
+var probeCache = map[string]probeData{}
+var probeCacheMu sync.Mutex
+
+// probeCacheKey is file's key in probeCache.  URLs and missing files have only their name.
+func probeCacheKey(file string) string {
+	if fh, err := os.Stat(file); err == nil {
+		return fmt.Sprintf("%s %d %d", file, fh.Size(), fh.ModTime().UnixNano())
+	}
+	return file
+}
+
+// probeFile is ffprobe's streams and format for file, from probeCache if it's been probed already
+func probeFile(file string) (probe probeData, err error) {
+	key := probeCacheKey(file)
+	probeCacheMu.Lock()
+	probe, ok := probeCache[key]
+	probeCacheMu.Unlock()
+	if ok {
+		return
+	}
+
+	probe, err = runProbe(file)
+	if err != nil {
+		return
+	}
+	probeCacheMu.Lock()
+	probeCache[key] = probe
+	probeCacheMu.Unlock()
+	return
+}
+
+// runProbe runs ffprobe over file
+func runProbe(file string) (probe probeData, err error) {
+	bin, err := resolveFfprobe()
+	if err != nil {
+		return
+	}
+
+	args := []string{"-v", "error", "-show_streams", "-show_format", "-of", "json", file}
+	cmd := exec.Command(bin, args...)
+	var outb, errb bytes.Buffer
+	cmd.Stdout = &outb
+	cmd.Stderr = &errb
+	err = cmd.Run()
+	if err != nil {
+		err = &ProbeError{File: file, Err: fmt.Errorf("%w: %s", err, strings.TrimSpace(errb.String()))}
+		return
+	}
+
+	err = json.Unmarshal(outb.Bytes(), &probe)
+	if err != nil {
+		err = &ProbeError{File: file, Err: fmt.Errorf("unable to parse ffprobe output: %w", err)}
+	}
+	return
+}
+
+// duration is the container duration reported by ffprobe, or 0 if it didn't report one
+func (p probeData) duration() time.Duration {
+	seconds, err := strconv.ParseFloat(p.Format.Duration, 64)
+	if err != nil {
+		return 0
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// streamsOfType returns the probed streams with the given codec_type (video, audio, subtitle...)
+func (p probeData) streamsOfType(codecType string) (streams []probeStream) {
+	for _, s := range p.Streams {
+		if s.CodecType == codecType {
+			streams = append(streams, s)
+		}
+	}
+	return
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestProbeFileCache(t *testing.T) {
+	dir := t.TempDir()
+	runs := filepath.Join(dir, "runs")
+	//logs each file it's run on, and fails for anything named bad
+	fakeFfprobe(t, `echo "$7" >> `+runs+`; case "$7" in *bad*) exit 1;; esac; echo '{"streams":[{"codec_type":"video","codec_name":"h264"}]}'`)
+	countRuns := func(file string) (n int) {
+		data, _ := os.ReadFile(runs)
+		for _, line := range strings.Split(string(data), "\n") {
+			if line == file {
+				n++
+			}
+		}
+		return
+	}
+
+	in := filepath.Join(dir, "in.mkv")
+	if err := os.WriteFile(in, []byte("first"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := probeFile(in); err != nil {
+			t.Fatalf("probeFile(%s) = %v", in, err)
+		}
+	}
+	if n := countRuns(in); n != 1 {
+		t.Errorf("probing %s 3 times ran ffprobe %d times, want 1", in, n)
+	}
+
+	//written again, as an output being re-encoded would be
+	if err := os.WriteFile(in, []byte("second version"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(in, later, later); err != nil {
+		t.Fatal(err)
+	}
+	probeFile(in)
+	probeFile(in)
+	if n := countRuns(in); n != 2 {
+		t.Errorf("probing %s after it changed ran ffprobe %d times in all, want 2", in, n)
+	}
+
+	bad := filepath.Join(dir, "bad.mkv")
+	for i := 0; i < 2; i++ {
+		if _, err := probeFile(bad); err == nil {
+			t.Errorf("probeFile(%s) = nil error, want one", bad)
+		}
+	}
+	if n := countRuns(bad); n != 2 {
+		t.Errorf("probing %s twice ran ffprobe %d times, want 2 since failures aren't kept", bad, n)
+	}
+}
+
+func TestProbeFileConcurrent(t *testing.T) {
+	fakeFfprobe(t, `echo '{"streams":[{"codec_type":"audio","codec_name":"flac"}],"format":{"duration":"60"}}'`)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			probe, err := probeFile(filepath.Join("music", string(rune('a'+i%3))+".flac"))
+			if err != nil || probe.duration() != time.Minute {
+				t.Errorf("probeFile = %+v, %v", probe, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var progressRegex = regexp.MustCompile(`frame=\s*(\d+)\s+fps=\s*([\d.]+).*time=\s*(-?\d+):(\d+):([\d.]+).*speed=\s*([\d.]+)x`)
+
+type progressStats struct {
+	Frame int
+	Fps   float64
+	Time  time.Duration
+	Speed float64
+}
+
+// parseProgressLine pulls the numbers out of one of ffmpeg's "frame=... fps=... time=... speed=...x" status lines
+func parseProgressLine(line string) (p progressStats, ok bool) {
+	m := progressRegex.FindStringSubmatch(line)
+	if m == nil {
+		return
+	}
+	p.Frame, _ = strconv.Atoi(m[1])
+	p.Fps, _ = strconv.ParseFloat(m[2], 64)
+	hours, _ := strconv.Atoi(m[3])
+	minutes, _ := strconv.Atoi(m[4])
+	seconds, _ := strconv.ParseFloat(m[5], 64)
+	p.Time = time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute + time.Duration(seconds*float64(time.Second))
+	p.Speed, _ = strconv.ParseFloat(m[6], 64)
+	ok = true
+	return
+}
+
+// eta is how much wall clock time is left, given how far into total the encode is and how fast it's going
+func (p progressStats) eta(total time.Duration) time.Duration {
+	if total <= 0 || p.Speed <= 0 || p.Time >= total {
+		return 0
+	}
+	return time.Duration(float64(total-p.Time) / p.Speed)
+}
+
+func (p progressStats) percent(total time.Duration) float64 {
+	if total <= 0 {
+		return 0
+	}
+	pct := 100 * float64(p.Time) / float64(total)
+	if pct > 100 {
+		pct = 100
+	}
+	return pct
+}
+
+func (p progressStats) String(total time.Duration) string {
+	if total <= 0 {
+		return fmt.Sprintf("%s encoded, %.1f fps, %.2fx", p.Time.Round(time.Second), p.Fps, p.Speed)
+	}
+	return fmt.Sprintf("%.0f%% done, ETA %s (%.1f fps, %.2fx)", p.percent(total), p.eta(total).Round(time.Second), p.Fps, p.Speed)
+}
+
+// maxStderrNotes is the most lines stderrNotes keeps, so a warning ffmpeg repeats for every frame can't flood the log
+const maxStderrNotes = 50
+
+// stderrNotes picks what's worth logging out of a successful run's stderr without -log-ffmpeg-output: the
+// stream mapping, which says what went where, and the messages ffmpeg's components prefix with [name @ 0x...],
+// which is where warnings turn up.  Progress lines, the banner and the input/output listings are left out, and
+// a message repeated word for word is only kept once.
+func stderrNotes(output string) (notes []string) {
+	seen := map[string]bool{}
+	mapping := false
+	for _, line := range strings.FieldsFunc(output, func(r rune) bool { return r == '\r' || r == '\n' }) {
+		switch {
+		case strings.HasPrefix(line, "Stream mapping:"):
+			mapping = true
+		case mapping && strings.HasPrefix(line, "  "):
+		case strings.HasPrefix(line, "["):
+			mapping = false
+		default:
+			mapping = false
+			continue
+		}
+		if seen[line] {
+			continue
+		}
+		seen[line] = true
+		notes = append(notes, strings.TrimRight(line, " "))
+		if len(notes) == maxStderrNotes {
+			notes = append(notes, "...and more, use -log-ffmpeg-output for all of it")
+			return
+		}
+	}
+	return
+}
+
+// progressWriter sits on ffmpeg's stderr.  Everything written is kept in Output, and the status lines
+// (which ffmpeg ends with \r rather than \n) are parsed and handed to onProgress at most once per period.
+// Latest is always the most recent status line, ffmpeg's fps and speed there are averages over the whole run.
+// onStatus, if it's set, gets every status line as it comes.
+type progressWriter struct {
+	Output     bytes.Buffer
+	Latest     progressStats
+	period     time.Duration
+	onProgress func(progressStats)
+	onStatus   func(progressStats)
+	last       time.Time
+	partial    string
+}
+
+func newProgressWriter(period time.Duration, onProgress func(progressStats)) *progressWriter {
+	return &progressWriter{period: period, onProgress: onProgress, last: time.Now()}
+}
+
+func (w *progressWriter) Write(b []byte) (n int, err error) {
+	n, err = w.Output.Write(b)
+
+	lines := strings.FieldsFunc(w.partial+string(b), func(r rune) bool { return r == '\r' || r == '\n' })
+	w.partial = ""
+	if len(b) > 0 && b[len(b)-1] != '\r' && b[len(b)-1] != '\n' && len(lines) > 0 {
+		w.partial = lines[len(lines)-1]
+		lines = lines[:len(lines)-1]
+	}
+
+	for _, line := range lines {
+		p, ok := parseProgressLine(line)
+		if !ok {
+			continue
+		}
+		w.Latest = p
+		if w.onStatus != nil {
+			w.onStatus(p)
+		}
+		if w.period <= 0 || w.onProgress == nil || time.Since(w.last) < w.period {
+			continue
+		}
+		w.last = time.Now()
+		w.onProgress(p)
+	}
+	return
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestStderrNotes(t *testing.T) {
+	stderr := strings.Join([]string{
+		"ffmpeg version 6.0 Copyright (c) 2000-2023 the FFmpeg developers",
+		"Input #0, matroska,webm, from 'in.mkv':",
+		"  Duration: 00:10:00.50, start: 0.000000, bitrate: 2000 kb/s",
+		"Stream mapping:",
+		"  Stream #0:0 -> #0:0 (h264 (native) -> h264 (libx264))",
+		"  Stream #0:1 -> #0:1 (aac (native) -> aac (native))",
+		"Press [q] to stop, [?] for help",
+		"[libx264 @ 0x5581] using cpu capabilities: MMX2 SSE2Fast",
+		"Output #0, mp4, to 'out.mp4':",
+		"  Metadata:",
+		"frame=  100 fps=50 q=28.0 size=256kB time=00:00:04.00 bitrate=524.3kbits/s speed=2x\r" +
+			"[aac @ 0x5590] Queue input is backward in time",
+		"[aac @ 0x5590] Queue input is backward in time",
+	}, "\n")
+	want := []string{
+		"Stream mapping:",
+		"  Stream #0:0 -> #0:0 (h264 (native) -> h264 (libx264))",
+		"  Stream #0:1 -> #0:1 (aac (native) -> aac (native))",
+		"[libx264 @ 0x5581] using cpu capabilities: MMX2 SSE2Fast",
+		"[aac @ 0x5590] Queue input is backward in time",
+	}
+	if got := stderrNotes(stderr); !reflect.DeepEqual(got, want) {
+		t.Errorf("stderrNotes =\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestStderrNotesLimit(t *testing.T) {
+	var lines []string
+	for i := 0; i < maxStderrNotes+10; i++ {
+		lines = append(lines, fmt.Sprintf("[h264 @ 0x55] error %d", i))
+	}
+	notes := stderrNotes(strings.Join(lines, "\n"))
+	if len(notes) != maxStderrNotes+1 || !strings.HasPrefix(notes[maxStderrNotes], "...and more") {
+		t.Errorf("stderrNotes of %d messages kept %d lines, want %d and a note there's more", len(lines), len(notes), maxStderrNotes+1)
+	}
+}
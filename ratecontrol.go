@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// rateControlModes are the video.mode values each family of encoder understands, the first one is what an empty mode means
+var rateControlModes = map[string][]string{
+	"software": {"crf", "cbr"},
+	"nvenc":    {"vbr", "cq", "cbr", "constqp"},
+	"vaapi":    {"cqp", "vbr", "cbr"},
+}
+
+// encoderFamily groups encoders that take the same rate control options
+func encoderFamily(encoder string) string {
+	switch {
+	case encoder == "h264_omx":
+		return "omx"
+	case strings.HasSuffix(encoder, "_nvenc"):
+		return "nvenc"
+	case strings.HasSuffix(encoder, "_vaapi"):
+		return "vaapi"
+	}
+	return "software"
+}
+
+// encoderTunes are the video.tune values each encoder takes.  An encoder missing here doesn't have a -tune.
+var encoderTunes = map[string][]string{
+	"libx264":    {"film", "animation", "grain", "stillimage", "fastdecode", "zerolatency", "psnr", "ssim"},
+	"libx265":    {"animation", "grain", "fastdecode", "zerolatency", "psnr", "ssim"},
+	"h264_nvenc": {"hq", "ll", "ull", "lossless"},
+	"hevc_nvenc": {"hq", "ll", "ull", "lossless"},
+	"av1_nvenc":  {"hq", "ll", "ull", "lossless"},
+	"libaom-av1": {"psnr", "ssim"},
+}
+
+// tuneArgs checks video.tune is one encoder takes, since ffmpeg only finds out once the encode starts, or
+// passes a tune it doesn't know to an encoder that would reject it.
+func tuneArgs(v Video, encoder string) (args []string, err error) {
+	if v.Tune == "" {
+		return
+	}
+	tunes, ok := encoderTunes[encoder]
+	if !ok {
+		return nil, fmt.Errorf("video.tune %q can't be used with %s, it doesn't take a tune.  Leave tune empty", v.Tune, encoder)
+	}
+	tune := strings.ToLower(v.Tune)
+	if !stringInList(tune, tunes) {
+		return nil, fmt.Errorf("video.tune %q is not valid for %s, it takes one of %s", v.Tune, encoder, strings.Join(tunes, ", "))
+	}
+	return []string{"-tune", tune}, nil
+}
+
+// rateControlArgs turns video.mode, quality and the bitrates into the options encoder uses for them.
+// x264 style encoders take crf or cbr, nvenc takes vbr/cq (-cq is the quality), cbr and constqp, vaapi takes cqp, vbr and cbr.
+func rateControlArgs(v Video, encoder string) (args []string, err error) {
+	family := encoderFamily(encoder)
+	if family == "omx" {
+		//the omx encoder only does its own default bitrate
+		return
+	}
+
+	modes := rateControlModes[family]
+	mode := strings.ToLower(v.Mode)
+	if mode == "" {
+		mode = modes[0]
+	}
+	if !stringInList(mode, modes) {
+		return nil, fmt.Errorf("video.mode %q is not valid for %s, it takes one of %s", v.Mode, encoder, strings.Join(modes, ", "))
+	}
+	needBitrate := func() error {
+		if v.VideoBitrate == "" {
+			return fmt.Errorf("video.mode %s needs video.videoBitrate", mode)
+		}
+		return nil
+	}
+	maxRate := func() (a []string, err error) {
+		for name, rate := range map[string]string{"videoMaxRate": v.VideoMaxRate, "videoBufsize": v.VideoBufSize} {
+			if _, err := parseSize(rate, 1000); rate != "" && err != nil {
+				return nil, fmt.Errorf("video.%s %q should be a bitrate like 4M or 4000k", name, rate)
+			}
+		}
+		if v.VideoMaxRate != "" {
+			a = append(a, []string{"-maxrate", v.VideoMaxRate}...)
+		}
+		if v.VideoBufSize != "" {
+			a = append(a, []string{"-bufsize", v.VideoBufSize}...)
+		}
+		return
+	}
+
+	switch family {
+	case "nvenc":
+		switch mode {
+		case "vbr", "cq":
+			//-b:v 0 leaves the bitrate up to -cq instead of nvenc's default target
+			bitrate := v.VideoBitrate
+			if bitrate == "" {
+				bitrate = "0"
+			}
+			args = append(args, []string{"-rc", "vbr", "-cq", fmt.Sprintf("%d", v.Quality), "-b:v", bitrate}...)
+			rates, err := maxRate()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, rates...)
+		case "cbr":
+			if err = needBitrate(); err != nil {
+				return nil, err
+			}
+			args = append(args, []string{"-rc", "cbr", "-b:v", v.VideoBitrate}...)
+		case "constqp":
+			args = append(args, []string{"-rc", "constqp", "-qp", fmt.Sprintf("%d", v.Quality)}...)
+		}
+		tune, err := tuneArgs(v, encoder)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, tune...)
+	case "vaapi":
+		switch mode {
+		case "cqp":
+			args = append(args, []string{"-rc_mode", "CQP", "-qp", fmt.Sprintf("%d", v.Quality)}...)
+		case "vbr":
+			if err = needBitrate(); err != nil {
+				return nil, err
+			}
+			args = append(args, []string{"-rc_mode", "VBR", "-b:v", v.VideoBitrate}...)
+			rates, err := maxRate()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, rates...)
+		case "cbr":
+			if err = needBitrate(); err != nil {
+				return nil, err
+			}
+			args = append(args, []string{"-rc_mode", "CBR", "-b:v", v.VideoBitrate}...)
+		}
+		tune, err := tuneArgs(v, encoder)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, tune...)
+	default:
+		if mode == "cbr" {
+			if err = needBitrate(); err != nil {
+				return nil, err
+			}
+			args = append(args, []string{"-b:v", v.VideoBitrate}...)
+		} else {
+			//x264 and x265 refuse a maxrate without the buffer size it's measured over
+			if v.VideoMaxRate != "" && v.VideoBufSize == "" {
+				return nil, fmt.Errorf("video.videoMaxRate needs video.videoBufsize too, about 1x-2x the maxrate")
+			}
+			args = append(args, "-crf", fmt.Sprintf("%d", v.Quality))
+			rates, err := maxRate()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, rates...)
+		}
+		tune, err := tuneArgs(v, encoder)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, tune...)
+	}
+	return
+}
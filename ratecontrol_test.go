@@ -0,0 +1,124 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRateControlArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		video   Video
+		encoder string
+		want    []string
+		wantErr bool
+	}{
+		{"crf alone", Video{Quality: 23}, "libx264", []string{"-crf", "23"}, false},
+		{"crf with maxrate", Video{Quality: 23, VideoMaxRate: "4M", VideoBufSize: "8M"}, "libx264", []string{"-crf", "23", "-maxrate", "4M", "-bufsize", "8M"}, false},
+		{"crf with tune", Video{Quality: 20, Tune: "film"}, "libx264", []string{"-crf", "20", "-tune", "film"}, false},
+		{"maxrate without bufsize", Video{Quality: 23, VideoMaxRate: "4M"}, "libx264", nil, true},
+		{"bad maxrate", Video{Quality: 23, VideoMaxRate: "fast", VideoBufSize: "8M"}, "libx264", nil, true},
+		{"cbr", Video{Mode: "cbr", VideoBitrate: "2000k"}, "libx265", []string{"-b:v", "2000k"}, false},
+		{"cbr without bitrate", Video{Mode: "cbr"}, "libx264", nil, true},
+		{"software none", Video{Mode: "none"}, "libx264", nil, true},
+		{"software vbr", Video{Mode: "vbr", VideoBitrate: "2M"}, "libx265", nil, true},
+		{"nvenc default", Video{Quality: 28}, "h264_nvenc", []string{"-rc", "vbr", "-cq", "28", "-b:v", "0"}, false},
+		{"nvenc cbr", Video{Mode: "cbr", VideoBitrate: "5M"}, "hevc_nvenc", []string{"-rc", "cbr", "-b:v", "5M"}, false},
+		{"nvenc cbr without bitrate", Video{Mode: "cbr"}, "hevc_nvenc", nil, true},
+		{"nvenc crf", Video{Mode: "crf"}, "h264_nvenc", nil, true},
+		{"vaapi default", Video{Quality: 24}, "h264_vaapi", []string{"-rc_mode", "CQP", "-qp", "24"}, false},
+		{"vaapi vbr", Video{Mode: "vbr", VideoBitrate: "3M", VideoMaxRate: "6M"}, "hevc_vaapi", []string{"-rc_mode", "VBR", "-b:v", "3M", "-maxrate", "6M"}, false},
+		{"vaapi tune", Video{Tune: "film"}, "h264_vaapi", nil, true},
+		{"omx", Video{Mode: "cbr", Quality: 23}, "h264_omx", nil, false},
+	}
+	for _, tt := range tests {
+		got, err := rateControlArgs(tt.video, tt.encoder)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: rateControlArgs error = %v, want error %t", tt.name, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: rateControlArgs = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestTuneArgs(t *testing.T) {
+	tests := []struct {
+		encoder string
+		tune    string
+		want    []string
+		wantErr bool
+	}{
+		{"libx264", "", nil, false},
+		{"libx264", "film", []string{"-tune", "film"}, false},
+		{"libx264", "Animation", []string{"-tune", "animation"}, false},
+		{"libx264", "stillimage", []string{"-tune", "stillimage"}, false},
+		{"libx264", "hq", nil, true},
+		{"libx265", "grain", []string{"-tune", "grain"}, false},
+		{"libx265", "film", nil, true},
+		{"libx265", "stillimage", nil, true},
+		{"h264_nvenc", "ll", []string{"-tune", "ll"}, false},
+		{"hevc_nvenc", "lossless", []string{"-tune", "lossless"}, false},
+		{"av1_nvenc", "ull", []string{"-tune", "ull"}, false},
+		{"hevc_nvenc", "film", nil, true},
+		{"libaom-av1", "psnr", []string{"-tune", "psnr"}, false},
+		{"libaom-av1", "ssim", []string{"-tune", "ssim"}, false},
+		{"libaom-av1", "film", nil, true},
+		{"h264_vaapi", "film", nil, true},
+		{"h264_omx", "film", nil, true},
+		{"h264_omx", "", nil, false},
+	}
+	for _, tt := range tests {
+		got, err := tuneArgs(Video{Tune: tt.tune}, tt.encoder)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("tuneArgs(%q, %s) error = %v, want error %t", tt.tune, tt.encoder, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("tuneArgs(%q, %s) = %v, want %v", tt.tune, tt.encoder, got, tt.want)
+		}
+	}
+}
+
+func TestEncoderTunesAccepted(t *testing.T) {
+	for encoder, tunes := range encoderTunes {
+		for _, tune := range tunes {
+			if _, err := tuneArgs(Video{Tune: tune}, encoder); err != nil {
+				t.Errorf("tuneArgs(%q, %s): %v", tune, encoder, err)
+			}
+		}
+	}
+}
+
+func TestRateControlModeMatrix(t *testing.T) {
+	encoders := []string{"libx264", "libx265", "libvpx-vp9", "h264_nvenc", "hevc_nvenc", "h264_vaapi", "hevc_vaapi"}
+	//the option each mode starts with, by encoder family
+	first := map[string]map[string]string{
+		"software": {"crf": "-crf", "cbr": "-b:v"},
+		"nvenc":    {"vbr": "-rc", "cq": "-rc", "cbr": "-rc", "constqp": "-rc"},
+		"vaapi":    {"cqp": "-rc_mode", "vbr": "-rc_mode", "cbr": "-rc_mode"},
+	}
+	allModes := []string{"crf", "cbr", "vbr", "cq", "constqp", "cqp", "none"}
+	for _, encoder := range encoders {
+		family := encoderFamily(encoder)
+		for _, mode := range allModes {
+			v := Video{Mode: mode, Quality: 25, VideoBitrate: "3M"}
+			args, err := rateControlArgs(v, encoder)
+			want, valid := first[family][mode]
+			if !valid {
+				if err == nil {
+					t.Errorf("rateControlArgs(%s, %s) = %q, want an error", encoder, mode, args)
+				}
+				continue
+			}
+			if err != nil {
+				t.Errorf("rateControlArgs(%s, %s) error = %v", encoder, mode, err)
+				continue
+			}
+			if len(args) == 0 || args[0] != want {
+				t.Errorf("rateControlArgs(%s, %s) = %q, want it to start with %s", encoder, mode, args, want)
+			}
+		}
+	}
+}
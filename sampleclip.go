@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// samplePath is where -sample-clip writes the sample of out: ep1.mkv becomes ep1.sample.mkv
+func samplePath(out string) string {
+	ext := filepath.Ext(out)
+	return strings.TrimSuffix(out, ext) + ".sample" + ext
+}
+
+// sampleStartOf picks where the sample starts, in seconds into the input: -sample-at if it's set, otherwise
+// centred in the part of the input the settings encode, which is usually more representative than the opening
+// titles.  It's 0 if the input's duration can't be found.
+func sampleStartOf(settings Settings, in string, length time.Duration) int {
+	if *sampleAt > 0 {
+		return int(sampleAt.Seconds())
+	}
+	probe, err := probeFile(in)
+	if err != nil || probe.duration() <= 0 {
+		log.Printf("warning: unable to find the middle of %s, sampling from the start: %v\n", in, err)
+		return settings.Time.TimeSkipIntro
+	}
+	encoded := expectedDurationOf(settings, probe.duration())
+	start := time.Duration(settings.Time.TimeSkipIntro)*time.Second + (encoded-length)/2
+	if start < 0 {
+		start = 0
+	}
+	return int(start.Seconds())
+}
+
+// sampleSettings cuts settings down to a -sample-length sample.  Everything else is left as it is, so the
+// sample shows what the real encode would look like.  The seek is by keyframe so it's quick even from the middle
+// of a long file.
+func sampleSettings(settings Settings, in, out string) (Settings, string) {
+	length := *sampleLength
+	if length < time.Second {
+		length = time.Second
+	}
+	if len(settings.Time.Segments) > 0 {
+		log.Printf("warning: -sample-clip ignores time.segments and encodes one %s sample\n", length)
+		settings.Time.Segments = nil
+	}
+	settings.Time.TimeSkipIntro = sampleStartOf(settings, in, length)
+	settings.Time.TotalTime = int(length.Seconds())
+	settings.Time.FastSeek = true
+	log.Printf("sampling %s from %s into %s\n", length, time.Duration(settings.Time.TimeSkipIntro)*time.Second, samplePath(out))
+	return settings, samplePath(out)
+}
+
+// checkSampleClip catches settings -sample-clip can't sample
+func checkSampleClip(out string) error {
+	if isStdout(out) {
+		return fmt.Errorf("-sample-clip writes next to the outfile, it can't be used with -outfile -")
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"io"
+	"log"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSamplePath(t *testing.T) {
+	tests := []struct {
+		out, want string
+	}{
+		{"ep1.mkv", "ep1.sample.mkv"},
+		{"/films/a.b.mp4", "/films/a.b.sample.mp4"},
+		{"out", "out.sample"},
+	}
+	for _, tt := range tests {
+		if got := samplePath(tt.out); got != tt.want {
+			t.Errorf("samplePath(%q) = %q, want %q", tt.out, got, tt.want)
+		}
+	}
+}
+
+func TestSampleStartOf(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+	defer func(d time.Duration) { *sampleAt = d }(*sampleAt)
+	fakeFfprobe(t, `echo '{"streams":[{"codec_type":"video","codec_name":"h264"}],"format":{"duration":"600"}}'`)
+
+	tests := []struct {
+		name   string
+		at     time.Duration
+		time   Time
+		length time.Duration
+		want   int
+	}{
+		{"middle", 0, Time{}, 30 * time.Second, 285},
+		{"middle of the encoded part", 0, Time{TimeSkipIntro: 60, TotalTime: 120}, 30 * time.Second, 105},
+		{"longer than the input", 0, Time{}, 20 * time.Minute, 0},
+		{"sample-at", 45 * time.Minute, Time{TimeSkipIntro: 60}, 30 * time.Second, 2700},
+	}
+	for _, tt := range tests {
+		*sampleAt = tt.at
+		if got := sampleStartOf(Settings{Time: tt.time}, "in.mkv", tt.length); got != tt.want {
+			t.Errorf("%s: sampleStartOf = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+
+	*sampleAt = 0
+	fakeFfprobe(t, "exit 1")
+	if got := sampleStartOf(Settings{Time: Time{TimeSkipIntro: 90}}, "in.mkv", 30*time.Second); got != 90 {
+		t.Errorf("sampleStartOf when probing fails = %d, want the intro skip, 90", got)
+	}
+}
+
+func TestSampleSettings(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+	defer func(at, length time.Duration) { *sampleAt, *sampleLength = at, length }(*sampleAt, *sampleLength)
+	fakeFfprobe(t, `echo '{"streams":[{"codec_type":"video","codec_name":"h264"}],"format":{"duration":"600"}}'`)
+
+	tests := []struct {
+		length    time.Duration
+		at        time.Duration
+		wantStart int
+		wantTotal int
+	}{
+		{30 * time.Second, 0, 285, 30},
+		{10 * time.Second, 2 * time.Minute, 120, 10},
+		{100 * time.Millisecond, 0, 299, 1},
+	}
+	for _, tt := range tests {
+		*sampleLength, *sampleAt = tt.length, tt.at
+		settings := Settings{Video: Video{Encoder: "libx265"}, Time: Time{Segments: []Segment{{Start: "0", End: "60"}}}}
+		got, out := sampleSettings(settings, "in.mkv", "out.mkv")
+		if out != "out.sample.mkv" {
+			t.Errorf("sampleSettings with -sample-length %s writes to %s, want out.sample.mkv", tt.length, out)
+		}
+		if got.Time.TimeSkipIntro != tt.wantStart || got.Time.TotalTime != tt.wantTotal || !got.Time.FastSeek || got.Time.Segments != nil {
+			t.Errorf("sampleSettings with -sample-length %s -sample-at %s time = %+v, want start %d, total %d, fastSeek and no segments", tt.length, tt.at, got.Time, tt.wantStart, tt.wantTotal)
+		}
+		if !reflect.DeepEqual(got.Video, settings.Video) {
+			t.Errorf("sampleSettings changed the video settings to %+v", got.Video)
+		}
+	}
+}
+
+func TestSampleClipArgs(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+	defer func(at, length time.Duration) { *sampleAt, *sampleLength = at, length }(*sampleAt, *sampleLength)
+	*sampleAt, *sampleLength = 2*time.Minute, 15*time.Second
+
+	settings, _ := sampleSettings(Settings{Video: Video{Encoder: "libx264"}}, "in.mkv", "out.mp4")
+	args := testArgs(t, settings)
+	if ss, i := argIndex(args, "-ss"), argIndex(args, "-i"); ss < 0 || ss > i || args[ss+1] != "120" {
+		t.Errorf("sample args = %q, want -ss 120 before -i", args)
+	}
+	if got := argValue(args, "-t"); got != "15" {
+		t.Errorf("sample args -t = %q, want 15", got)
+	}
+}
+
+func TestCheckSampleClip(t *testing.T) {
+	if err := checkSampleClip("out.mkv"); err != nil {
+		t.Errorf("checkSampleClip(out.mkv) = %v, want nil", err)
+	}
+	if err := checkSampleClip("-"); err == nil {
+		t.Errorf("checkSampleClip(-) = nil, want an error")
+	}
+}
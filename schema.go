@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// schemaEnums are the string settings that only take a fixed set of values, keyed by struct name and json
+// key.  Empty is always allowed and means the default.
+var schemaEnums = map[string][]string{
+	"Video.scaleMode":         {"", "stretch", "fit", "pad", "fill"},
+	"Video.hdrMode":           {"", "passthrough", "tonemap"},
+	"Video.frameRateMode":     {"", "drop", "blend", "interpolate"},
+	"Video.vfrMode":           {"", "cfr", "passthrough"},
+	"Audio.audioFilter":       {"", "none", "loudnorm"},
+	"Audio.resampler":         {"", "swr", "soxr"},
+	"Audio.trimSilence":       {"", "start", "end", "both"},
+	"Audio.incompatibleAudio": {"", "transcode", "error"},
+}
+
+// schemaExamples are suggestions for settings that also take other values
+var schemaExamples = map[string][]string{
+	"Video.resolution": {"480p", "720p", "1080p", "4k", "1280:720"},
+	"Video.mode":       {"crf", "cbr", "vbr", "cq", "constqp", "cqp"},
+	"Video.tune":       {"film", "animation", "grain", "stillimage", "fastdecode", "zerolatency", "psnr", "ssim", "hq", "ll", "ull", "lossless"},
+	"Video.encoder":    {"libx264", "libx265", "h264_omx", "h264_nvenc", "hevc_nvenc", "h264_vaapi", "hevc_vaapi"},
+	"Video.hwAccel":    {"auto", "cuda", "vaapi", "videotoolbox"},
+	"Video.targetSize": {"700M", "4.7G"},
+	"Audio.audioCodec": {"aac", "libfdk_aac", "libopus", "libvorbis", "libmp3lame", "flac", "ac3"},
+	"Output.format":    {"mp4", "matroska", "mpegts", "webm", "mov"},
+}
+
+// schemaDescriptions describe the settings the template has no hint for, since only string settings can
+// hold one
+var schemaDescriptions = map[string]string{
+	"Video.justCopy":              "Copy the video as it is, settings that would change it are ignored.  With the audio encoded it's the quick way to fix only the audio, as long as the output's container can hold the input's video codec",
+	"Video.threads":               "How many cpu threads a software encode uses, 0 lets ffmpeg decide.  libx265 also gets its thread pools capped",
+	"Video.noUpscale":             "Leave input that's already no bigger than resolution at its own size instead of scaling it up, or padding it with scaleMode pad",
+	"Video.disableVideo":          "Leave the video out of the output, this wins over everything else for the video",
+	"Video.copyIfMatching":        "Copy the video instead of encoding it when the input is already the codec, size and bitrate the settings would make and no filter needs it re-encoded",
+	"Audio.justCopy":              "Copy the audio as it is, settings that would change it are ignored",
+	"Audio.loudnorm2Pass":         "Measure the loudness first and normalize with the measurements, only with audioFilter loudnorm",
+	"Audio.loudnormDynamic":       "Turn off linear mode for the second loudnorm pass, which can sound better on content with a wide dynamic range",
+	"Audio.loudnormDualMono":      "Treat mono input as dual-mono when measuring loudness",
+	"Audio.preferFdkAac":          "Use libfdk_aac instead of ffmpeg's own aac encoder when this ffmpeg has it, it sounds better at low bitrates",
+	"Audio.disableAudio":          "Leave the audio out of the output, this wins over everything else for the audio",
+	"Audio.resamplePrecision":     "soxr's precision in bits, 20 is high quality and 28 very high",
+	"Audio.audioOffset":           "Seconds to shift the audio by to fix out of sync audio, positive plays it later and negative earlier.  Needs the audio encoded",
+	"Audio.copyIfMatching":        "Copy the audio instead of encoding it when the input is already the codec, channels and bitrate the settings would make and nothing like loudnorm or -album-gain needs it re-encoded",
+	"Subtitles.subtitleAlignment": "Where burned in subtitles go, 1-9 laid out like a numpad: 2 is bottom center, 8 top center",
+	"Subtitles.subtitleMarginV":   "How far burned in subtitles sit from the top or bottom edge, for when they'd cover on-screen text",
+	"Subtitles.copySubtitles":     "Pass every subtitle track through as soft subs, converting text subs to the container's format where it needs it",
+	"Subtitles.webvttSidecar":     "Also write each text subtitle track to a .vtt file next to the output, for html5 players",
+	"Time.fastSeek":               "Jump to the keyframe before timeSkipIntro instead of decoding up to it, much faster on long skips but may start slightly early",
+	"Output.fastStart":            "Put an mp4's index at the front so it can start playing while it downloads.  Can't be used with fragmented",
+	"Output.fragmented":           "Write a fragmented mp4 for DASH and low latency streaming.  Can't be used with fastStart",
+	"Ready.completed":             "Set once a run with -resume succeeds, -resume skips settings that are already completed",
+}
+
+// settingsSchema builds a JSON Schema for the settings file from the Settings struct.  The descriptions
+// are the hints from the "template" template, so they stay in step with make-template.
+func settingsSchema() (schema map[string]interface{}, err error) {
+	schema, err = schemaFor(reflect.TypeOf(Settings{}), reflect.ValueOf(templates()["template"]))
+	if err != nil {
+		return
+	}
+	schema["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	schema["title"] = "ffmpegfront settings"
+	return
+}
+
+// jsonFieldName is the key f has in a settings file
+func jsonFieldName(f reflect.StructField) string {
+	name := strings.Split(f.Tag.Get("json"), ",")[0]
+	if name == "" {
+		return f.Name
+	}
+	return name
+}
+
+// schemaFor describes type t, with hint being the template's value of that type (for descriptions)
+func schemaFor(t reflect.Type, hint reflect.Value) (map[string]interface{}, error) {
+	switch t.Kind() {
+	case reflect.Struct:
+		properties := map[string]interface{}{}
+		for f := 0; f < t.NumField(); f++ {
+			name := jsonFieldName(t.Field(f))
+			property, err := schemaFor(t.Field(f).Type, hint.Field(f))
+			if err != nil {
+				return nil, err
+			}
+			key := t.Name() + "." + name
+			if description, ok := schemaDescriptions[key]; ok {
+				property["description"] = description
+			}
+			if values, ok := schemaEnums[key]; ok {
+				property["enum"] = values
+			}
+			if values, ok := schemaExamples[key]; ok {
+				property["examples"] = values
+			}
+			properties[name] = property
+		}
+		return map[string]interface{}{"type": "object", "properties": properties, "additionalProperties": false}, nil
+	case reflect.Slice:
+		itemHint := reflect.Zero(t.Elem())
+		if hint.Len() > 0 {
+			itemHint = hint.Index(0)
+		}
+		items, err := schemaFor(t.Elem(), itemHint)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"type": "array", "items": items}, nil
+	case reflect.String:
+		property := map[string]interface{}{"type": "string"}
+		if hint.String() != "" {
+			property["description"] = hint.String()
+		}
+		return property, nil
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}, nil
+	case reflect.Int, reflect.Int64:
+		return map[string]interface{}{"type": "integer"}, nil
+	case reflect.Float64:
+		return map[string]interface{}{"type": "number"}, nil
+	}
+	return nil, fmt.Errorf("no json schema type for %s", t)
+}
+
+func printSchema() {
+	schema, err := settingsSchema()
+	if err != nil {
+		fatalf("unable to build the json schema: %v\n", err)
+	}
+	out, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		fatalf("unable to build the json schema: %v\n", err)
+	}
+	fmt.Println(strings.TrimSpace(string(out)))
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// checkAgainstSchema validates a decoded json value against the parts of JSON Schema settingsSchema uses
+func checkAgainstSchema(schema map[string]interface{}, value interface{}, path string) error {
+	if enum, ok := schema["enum"].([]string); ok {
+		s, _ := value.(string)
+		if !stringInList(s, enum) {
+			return fmt.Errorf("%s: %q isn't one of %q", path, s, enum)
+		}
+	}
+	switch schema["type"] {
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s: %v isn't an object", path, value)
+		}
+		properties := schema["properties"].(map[string]interface{})
+		for key, v := range object {
+			property, ok := properties[key]
+			if !ok {
+				return fmt.Errorf("%s: %s isn't a setting", path, key)
+			}
+			if err := checkAgainstSchema(property.(map[string]interface{}), v, path+"."+key); err != nil {
+				return err
+			}
+		}
+	case "array":
+		if value == nil {
+			return nil
+		}
+		items, ok := value.([]interface{})
+		if !ok {
+			return fmt.Errorf("%s: %v isn't an array", path, value)
+		}
+		for i, item := range items {
+			if err := checkAgainstSchema(schema["items"].(map[string]interface{}), item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	case "string":
+		if _, ok := value.(string); !ok {
+			return fmt.Errorf("%s: %v isn't a string", path, value)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("%s: %v isn't a boolean", path, value)
+		}
+	case "integer":
+		if n, ok := value.(float64); !ok || n != float64(int64(n)) {
+			return fmt.Errorf("%s: %v isn't an integer", path, value)
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			return fmt.Errorf("%s: %v isn't a number", path, value)
+		}
+	}
+	return nil
+}
+
+func TestSettingsSchema(t *testing.T) {
+	schema, err := settingsSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string][]byte{}
+	data, err := os.ReadFile("settings.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files["settings.json"] = data
+	for name, preset := range templates() {
+		if name == "template" {
+			//its string settings are descriptions rather than values
+			continue
+		}
+		if files["preset "+name], err = json.Marshal(preset); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for name, data := range files {
+		var value interface{}
+		if err := json.Unmarshal(data, &value); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if err := checkAgainstSchema(schema, value, "settings"); err != nil {
+			t.Errorf("%s doesn't validate against the schema: %v", name, err)
+		}
+	}
+}
+
+func TestSchemaForUnknownKind(t *testing.T) {
+	type withMap struct {
+		Values map[string]string `json:"values"`
+	}
+	if _, err := schemaFor(reflect.TypeOf(withMap{}), reflect.ValueOf(withMap{})); err == nil {
+		t.Error("schemaFor of a struct with a map didn't fail")
+	}
+}
+
+func TestSchemaDescriptionsAreSettings(t *testing.T) {
+	schema, err := settingsSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	properties := schema["properties"].(map[string]interface{})
+	structs := map[string]string{"Video": "video", "Audio": "audio", "Subtitles": "subtitles", "Time": "time", "Output": "output", "Ready": "ready"}
+	for key := range schemaDescriptions {
+		parts := strings.SplitN(key, ".", 2)
+		object, ok := properties[structs[parts[0]]].(map[string]interface{})
+		if !ok {
+			t.Errorf("schemaDescriptions has %s, which isn't in the settings", key)
+			continue
+		}
+		if _, ok := object["properties"].(map[string]interface{})[parts[1]]; !ok {
+			t.Errorf("schemaDescriptions has %s, which isn't in the settings", key)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type Segment struct {
+	Start string `json:"start" yaml:"start"`
+	End   string `json:"end" yaml:"end"`
+}
+
+var segmentTimeRegex = regexp.MustCompile(`^(\d+:)?(\d+:)?\d+(\.\d+)?$`)
+
+// segmentSeconds reads seconds or [hh:]mm:ss[.ms] as seconds.  The colons would have to be escaped inside a filter, seconds don't.
+func segmentSeconds(t string) (seconds float64, err error) {
+	if !segmentTimeRegex.MatchString(t) {
+		err = fmt.Errorf("bad time %q", t)
+		return
+	}
+	for _, part := range strings.Split(t, ":") {
+		n, _ := strconv.ParseFloat(part, 64)
+		seconds = seconds*60 + n
+	}
+	return
+}
+
+func segmentLabels(prefix string, n int) (labels string) {
+	for i := 0; i < n; i++ {
+		labels += fmt.Sprintf("[%s%d]", prefix, i)
+	}
+	return
+}
+
+// segmentsFilterArgs cuts each segment out of the input with trim/atrim and joins them with concat.  A
+// complex filtergraph can't be mixed with -vf/-filter:a on the same stream, so those are pulled out of
+// args and chained on after the concat instead.  audioIn is the input audio stream to cut, empty for none.
+// The result replaces args.
+func segmentsFilterArgs(segments []Segment, args []string, video bool, audioIn string) (newArgs []string, err error) {
+	audio := audioIn != ""
+	var videoFilter, audioFilter string
+	for i := 0; i < len(args); i++ {
+		if i+1 < len(args) && args[i] == "-vf" {
+			videoFilter = args[i+1]
+			i++
+			continue
+		}
+		if i+1 < len(args) && args[i] == "-filter:a" {
+			audioFilter = args[i+1]
+			i++
+			continue
+		}
+		newArgs = append(newArgs, args[i])
+	}
+
+	//an input pad can only feed one filter, so the input gets split into a copy per segment first
+	var graph, concatInputs []string
+	if video {
+		graph = append(graph, fmt.Sprintf("[0:v]split=%d%s", len(segments), segmentLabels("sv", len(segments))))
+	}
+	if audio {
+		graph = append(graph, fmt.Sprintf("[%s]asplit=%d%s", audioIn, len(segments), segmentLabels("sa", len(segments))))
+	}
+	for i, seg := range segments {
+		start, startErr := segmentSeconds(seg.Start)
+		end, endErr := segmentSeconds(seg.End)
+		if startErr != nil || endErr != nil || end <= start {
+			return nil, fmt.Errorf("segment %d has a bad start or end (%q, %q), use seconds or hh:mm:ss with the end after the start", i, seg.Start, seg.End)
+		}
+		if video {
+			graph = append(graph, fmt.Sprintf("[sv%d]trim=start=%g:end=%g,setpts=PTS-STARTPTS[v%d]", i, start, end, i))
+			concatInputs = append(concatInputs, fmt.Sprintf("[v%d]", i))
+		}
+		if audio {
+			graph = append(graph, fmt.Sprintf("[sa%d]atrim=start=%g:end=%g,asetpts=PTS-STARTPTS[a%d]", i, start, end, i))
+			concatInputs = append(concatInputs, fmt.Sprintf("[a%d]", i))
+		}
+	}
+
+	//the concat output goes through the pulled out filters when there are any
+	concatVideo, concatAudio := "[outv]", "[outa]"
+	if videoFilter != "" {
+		concatVideo = "[catv]"
+	}
+	if audioFilter != "" {
+		concatAudio = "[cata]"
+	}
+
+	concat := fmt.Sprintf("%sconcat=n=%d:v=%d:a=%d", strings.Join(concatInputs, ""), len(segments), boolInt(video), boolInt(audio))
+	var outLabels []string
+	if video {
+		concat += concatVideo
+		outLabels = append(outLabels, "[outv]")
+	}
+	if audio {
+		concat += concatAudio
+		outLabels = append(outLabels, "[outa]")
+	}
+	graph = append(graph, concat)
+
+	if video && videoFilter != "" {
+		graph = append(graph, fmt.Sprintf("[catv]%s[outv]", videoFilter))
+	}
+	if audio && audioFilter != "" {
+		graph = append(graph, fmt.Sprintf("[cata]%s[outa]", audioFilter))
+	}
+
+	newArgs = append(newArgs, []string{"-filter_complex", strings.Join(graph, ";")}...)
+	for _, label := range outLabels {
+		newArgs = append(newArgs, []string{"-map", label}...)
+	}
+	return
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
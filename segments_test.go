@@ -0,0 +1,94 @@
+package main
+
+import (
+	"io"
+	"log"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSegmentSeconds(t *testing.T) {
+	tests := []struct {
+		time    string
+		want    float64
+		wantErr bool
+	}{
+		{"90", 90, false},
+		{"1.5", 1.5, false},
+		{"01:30", 90, false},
+		{"1:00:10.5", 3610.5, false},
+		{"", 0, true},
+		{"1m30s", 0, true},
+		{"-5", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := segmentSeconds(tt.time)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("segmentSeconds(%q) error = %v, want error %t", tt.time, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("segmentSeconds(%q) = %g, want %g", tt.time, got, tt.want)
+		}
+	}
+}
+
+func TestSegmentsFilterArgs(t *testing.T) {
+	segments := []Segment{{Start: "0", End: "60"}, {Start: "00:02:00", End: "00:03:30.5"}}
+	tests := []struct {
+		name    string
+		args    []string
+		video   bool
+		audioIn string
+		want    []string
+	}{
+		{"video and audio", []string{"-c:v", "libx264"}, true, "0:a:0", []string{"-c:v", "libx264", "-filter_complex",
+			"[0:v]split=2[sv0][sv1];[0:a:0]asplit=2[sa0][sa1];" +
+				"[sv0]trim=start=0:end=60,setpts=PTS-STARTPTS[v0];[sa0]atrim=start=0:end=60,asetpts=PTS-STARTPTS[a0];" +
+				"[sv1]trim=start=120:end=210.5,setpts=PTS-STARTPTS[v1];[sa1]atrim=start=120:end=210.5,asetpts=PTS-STARTPTS[a1];" +
+				"[v0][a0][v1][a1]concat=n=2:v=1:a=1[outv][outa]",
+			"-map", "[outv]", "-map", "[outa]"}},
+		{"filters after the concat", []string{"-vf", "scale=1280:720", "-filter:a", "loudnorm", "-c:a", "aac"}, true, "0:a:1", []string{"-c:a", "aac", "-filter_complex",
+			"[0:v]split=2[sv0][sv1];[0:a:1]asplit=2[sa0][sa1];" +
+				"[sv0]trim=start=0:end=60,setpts=PTS-STARTPTS[v0];[sa0]atrim=start=0:end=60,asetpts=PTS-STARTPTS[a0];" +
+				"[sv1]trim=start=120:end=210.5,setpts=PTS-STARTPTS[v1];[sa1]atrim=start=120:end=210.5,asetpts=PTS-STARTPTS[a1];" +
+				"[v0][a0][v1][a1]concat=n=2:v=1:a=1[catv][cata];[catv]scale=1280:720[outv];[cata]loudnorm[outa]",
+			"-map", "[outv]", "-map", "[outa]"}},
+		{"audio only", []string{"-vn"}, false, "0:a:0", []string{"-vn", "-filter_complex",
+			"[0:a:0]asplit=2[sa0][sa1];" +
+				"[sa0]atrim=start=0:end=60,asetpts=PTS-STARTPTS[a0];" +
+				"[sa1]atrim=start=120:end=210.5,asetpts=PTS-STARTPTS[a1];" +
+				"[a0][a1]concat=n=2:v=0:a=1[outa]",
+			"-map", "[outa]"}},
+	}
+	for _, tt := range tests {
+		got, err := segmentsFilterArgs(segments, tt.args, tt.video, tt.audioIn)
+		if err != nil {
+			t.Errorf("%s: segmentsFilterArgs error = %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: segmentsFilterArgs =\n%q\nwant\n%q", tt.name, got, tt.want)
+		}
+	}
+
+	for _, bad := range [][]Segment{{{Start: "60", End: "30"}}, {{Start: "0", End: "ten"}}} {
+		if _, err := segmentsFilterArgs(bad, nil, true, "0:a:0"); err == nil {
+			t.Errorf("segmentsFilterArgs(%+v) didn't fail", bad)
+		}
+	}
+}
+
+func TestSegmentsBurnInSubtitles(t *testing.T) {
+	fakeFfprobe(t, `echo '{"streams":[{"codec_type":"video"},{"codec_type":"audio"},{"codec_type":"subtitle"}]}'`)
+	settings := Settings{
+		Video:     Video{Encoder: "libx264"},
+		Subtitles: Subtitles{BurnInSubtitles: true},
+		Time:      Time{Segments: []Segment{{Start: "0", End: "60"}}},
+	}
+	_, err := buildArgs(log.New(io.Discard, "", 0), settings, "in.mkv", []string{"out.mkv"})
+	if err == nil || !strings.Contains(err.Error(), "time.segments") {
+		t.Errorf("buildArgs with time.segments and burned in subtitles = %v, want a time.segments error", err)
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// selfTestSettings is a small software encode with loudnorm, so the self test goes through the video and audio settings and the loudnorm analysis
+var selfTestSettings = Settings{
+	Video: Video{SoftwareEncode: true, Resolution: "320:240", Mode: "crf", Quality: 30, Tune: "fastdecode", VideoMaxRate: "1M", VideoBufSize: "2M"},
+	Audio: Audio{AudioCodec: "aac", AudioChannels: "2", AudioFilter: "loudnorm", AudioBitrate: "96k"},
+}
+
+// runSelfTest encodes a generated 2 second clip with the normal pipeline and checks the result probes as
+// expected.  Everything is done in a directory under -tmpdir that's removed afterwards.
+func runSelfTest() (err error) {
+	if _, err = resolveFfprobe(); err != nil {
+		return
+	}
+
+	dir, err := os.MkdirTemp(runTempDir, "ffmpegfront-selftest-")
+	if err != nil {
+		return fmt.Errorf("unable to make a directory to test in: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	in := filepath.Join(dir, "source.mkv")
+	out := filepath.Join(dir, "output.mp4")
+	source := exec.Command(ffmpegBin, "-hide_banner", "-f", "lavfi", "-i", "testsrc=duration=2:size=640x480:rate=25",
+		"-f", "lavfi", "-i", "sine=frequency=440:duration=2", "-c:v", "ffv1", "-c:a", "flac", in)
+	if output, sourceErr := source.CombinedOutput(); sourceErr != nil {
+		return fmt.Errorf("unable to generate the test source, this ffmpeg may be missing lavfi: %v\n%s", sourceErr, output)
+	}
+
+	err = processFile(selfTestSettings, in, out)
+	if err != nil {
+		return fmt.Errorf("encode failed: %v", err)
+	}
+
+	probe, err := probeFile(out)
+	if err != nil {
+		return
+	}
+	if len(probe.streamsOfType("video")) != 1 || len(probe.streamsOfType("audio")) != 1 {
+		return fmt.Errorf("expected 1 video and 1 audio stream in the output, got %d and %d", len(probe.streamsOfType("video")), len(probe.streamsOfType("audio")))
+	}
+	if d := probe.duration().Seconds(); math.Abs(d-2) > 0.5 {
+		return fmt.Errorf("expected the output to be about 2s long, it's %.2fs", d)
+	}
+	return
+}
+
+func selfTest() {
+	log.Printf("self test: ffmpeg is %s\n", ffmpegBin)
+	err := runSelfTest()
+	if err != nil {
+		fatalf("self test FAIL: %v\n", err)
+	}
+	log.Printf("self test PASS\n")
+	removeTempDir()
+	os.Exit(0)
+}
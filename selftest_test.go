@@ -0,0 +1,39 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestSelfTest(t *testing.T) {
+	bin, err := exec.LookPath("ffmpeg")
+	if err != nil {
+		t.Skip("no ffmpeg to test with")
+	}
+	defer func(f, p, d string) { ffmpegBin, ffprobeBin, runTempDir = f, p, d }(ffmpegBin, ffprobeBin, runTempDir)
+	ffmpegBin, ffprobeBin, runTempDir = bin, "", t.TempDir()
+
+	if err := runSelfTest(); err != nil {
+		t.Errorf("runSelfTest() = %v", err)
+	}
+	if left, _ := os.ReadDir(runTempDir); len(left) != 0 {
+		t.Errorf("runSelfTest() left %d files behind", len(left))
+	}
+}
+
+func TestSelfTestWithoutLavfi(t *testing.T) {
+	defer func(d string) { runTempDir = d }(runTempDir)
+	runTempDir = t.TempDir()
+	fakeFfprobe(t, "exit 0")
+	fakeFfmpeg(t, "echo 'Unknown input format: lavfi' >&2; exit 1")
+
+	err := runSelfTest()
+	if err == nil || !strings.Contains(err.Error(), "missing lavfi") || !strings.Contains(err.Error(), "Unknown input format: lavfi") {
+		t.Errorf("runSelfTest() = %v, want it to say lavfi is missing and show ffmpeg's output", err)
+	}
+	if left, _ := os.ReadDir(runTempDir); len(left) != 0 {
+		t.Errorf("runSelfTest() left %d files behind", len(left))
+	}
+}
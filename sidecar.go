@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+)
+
+// sidecarSuffixes are what's added to an input's name to find its own settings in batch mode, ex: ep1.mkv.ffmpegfront.json
+var sidecarSuffixes = []string{".ffmpegfront.json", ".ffmpegfront.yaml", ".ffmpegfront.yml"}
+
+// findSidecar returns the settings sidecar for in, or "" if it doesn't have one
+func findSidecar(in string) string {
+	for _, suffix := range sidecarSuffixes {
+		if _, err := os.Stat(in + suffix); err == nil {
+			return in + suffix
+		}
+	}
+	return ""
+}
+
+// mergeSettings lays the settings in override (json or yaml) over base.  Only the keys override has are changed:
+// objects are merged key by key, everything else, lists included, replaces the base value outright.  So a
+// sidecar of {"time": {"timeSkipIntro": 90}} changes the trim and keeps the rest of base's time settings.
+// base itself is left alone.
+func mergeSettings(base Settings, override []byte, yaml bool) (merged Settings, err error) {
+	//a round trip through json so merged doesn't share any slices with base
+	data, err := json.Marshal(base)
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(data, &merged)
+	if err != nil {
+		return
+	}
+
+	if yaml {
+		err = unmarshalYaml(override, &merged)
+	} else {
+		err = json.Unmarshal(override, &merged)
+	}
+	return
+}
+
+// sidecarSettings returns base with in's sidecar merged over it, or base as it is if there's no sidecar
+func sidecarSettings(base Settings, in string) (settings Settings, sidecar string, err error) {
+	settings = base
+	sidecar = findSidecar(in)
+	if sidecar == "" {
+		return
+	}
+	data, err := ioutil.ReadFile(sidecar)
+	if err != nil {
+		err = &SettingsError{File: sidecar, Err: err}
+		return
+	}
+	settings, err = mergeSettings(base, data, isYamlFile(sidecar))
+	if err != nil {
+		err = &SettingsError{File: sidecar, Err: err}
+	}
+	return
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMergeSettings(t *testing.T) {
+	base := Settings{
+		Video: Video{Encoder: "libx264", Quality: 23},
+		Audio: Audio{Language: "eng", TrackTitles: []string{"English"}},
+		Time:  Time{TimeSkipIntro: 30, TotalTime: 600},
+	}
+	tests := []struct {
+		name     string
+		override string
+		yaml     bool
+		want     func(s *Settings)
+		wantErr  bool
+	}{
+		{"empty json", `{}`, false, func(s *Settings) {}, false},
+		{"one key", `{"time": {"timeSkipIntro": 90}}`, false, func(s *Settings) { s.Time.TimeSkipIntro = 90 }, false},
+		{"list replaced", `{"audio": {"trackTitles": ["Commentary"]}}`, false, func(s *Settings) { s.Audio.TrackTitles = []string{"Commentary"} }, false},
+		{"yaml", "video:\n  quality: 20\n", true, func(s *Settings) { s.Video.Quality = 20 }, false},
+		{"yaml comments only", "# nothing\n", true, func(s *Settings) {}, false},
+		{"bad json", `{"time": `, false, nil, true},
+		{"unknown yaml key", "video:\n  qualty: 20\n", true, nil, true},
+	}
+	for _, tt := range tests {
+		got, err := mergeSettings(base, []byte(tt.override), tt.yaml)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: mergeSettings error = %v, want error %t", tt.name, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+		want := base
+		want.Audio.TrackTitles = append([]string{}, base.Audio.TrackTitles...)
+		tt.want(&want)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: mergeSettings = %+v, want %+v", tt.name, got, want)
+		}
+	}
+	if base.Time.TimeSkipIntro != 30 || base.Audio.TrackTitles[0] != "English" {
+		t.Errorf("mergeSettings changed base: %+v", base)
+	}
+}
+
+func TestSidecarSettings(t *testing.T) {
+	dir := t.TempDir()
+	base := Settings{Video: Video{Quality: 23}}
+
+	plain := filepath.Join(dir, "ep1.mkv")
+	settings, sidecar, err := sidecarSettings(base, plain)
+	if err != nil || sidecar != "" || !reflect.DeepEqual(settings, base) {
+		t.Errorf("sidecarSettings without a sidecar = %+v, %q, %v", settings, sidecar, err)
+	}
+
+	withYaml := filepath.Join(dir, "ep2.mkv")
+	if err := os.WriteFile(withYaml+".ffmpegfront.yaml", []byte("video:\n  quality: 18\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	settings, sidecar, err = sidecarSettings(base, withYaml)
+	if err != nil || sidecar != withYaml+".ffmpegfront.yaml" || settings.Video.Quality != 18 {
+		t.Errorf("sidecarSettings with a yaml sidecar = %+v, %q, %v", settings, sidecar, err)
+	}
+
+	broken := filepath.Join(dir, "ep3.mkv")
+	if err := os.WriteFile(broken+".ffmpegfront.json", []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = sidecarSettings(base, broken)
+	var settingsErr *SettingsError
+	if !errors.As(err, &settingsErr) || settingsErr.File != broken+".ffmpegfront.json" {
+		t.Errorf("sidecarSettings with a broken sidecar error = %v, want a SettingsError for it", err)
+	}
+}
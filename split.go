@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// isSplit is whether the output gets cut into standalone pieces by the segment muxer
+func isSplit(o Output) bool {
+	return o.SplitTime > 0 || o.SplitSize != ""
+}
+
+// splitPattern is the segment muxer's name for the pieces of out: out.mp4 becomes out_000.mp4, out_001.mp4...
+func splitPattern(out string) string {
+	ext := filepath.Ext(out)
+	return strings.TrimSuffix(out, ext) + "_%03d" + ext
+}
+
+// splitParts lists the pieces of out that are on disk, in order
+func splitParts(out string) (parts []string) {
+	ext := filepath.Ext(out)
+	base := filepath.Base(strings.TrimSuffix(out, ext))
+	pieceRegex := regexp.MustCompile(`^` + regexp.QuoteMeta(base) + `_[0-9]{3,}` + regexp.QuoteMeta(ext) + `$`)
+
+	entries, err := os.ReadDir(filepath.Dir(out))
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		if !e.IsDir() && pieceRegex.MatchString(e.Name()) {
+			parts = append(parts, filepath.Join(filepath.Dir(out), e.Name()))
+		}
+	}
+	return
+}
+
+// expandSplit swaps the main output (always the first) for the pieces the segment muxer wrote, pairing
+// each .part piece with the name it gets once the encode has worked out.  Extra outputs aren't split.
+func expandSplit(partials, outs []string) (splitPartials, splitOuts []string) {
+	ext := filepath.Ext(outs[0])
+	partialBase := strings.TrimSuffix(partials[0], ext)
+	outBase := strings.TrimSuffix(outs[0], ext)
+	for _, piece := range splitParts(partials[0]) {
+		splitPartials = append(splitPartials, piece)
+		splitOuts = append(splitOuts, outBase+strings.TrimPrefix(piece, partialBase))
+	}
+	splitPartials = append(splitPartials, partials[1:]...)
+	splitOuts = append(splitOuts, outs[1:]...)
+	return
+}
+
+// splitSeconds is how long each piece is.  With splitSize that comes from estimateOutputSize, so it's only as good
+// as that guess (spot on for cbr, rough otherwise), and pieces are aimed at 90% of the size to leave some room.
+func splitSeconds(settings Settings, in string) (seconds float64, err error) {
+	o := settings.Output
+	if o.SplitTime > 0 {
+		return float64(o.SplitTime), nil
+	}
+
+	size, err := parseSize(o.SplitSize, 1024)
+	if err != nil || size == 0 {
+		return 0, fmt.Errorf("output.splitSize %q should be a size like 2G or 500M", o.SplitSize)
+	}
+	probe, err := probeFile(in)
+	if err != nil {
+		return 0, fmt.Errorf("output.splitSize needs the input's duration: %w", err)
+	}
+	duration := expectedDurationOf(settings, probe.duration()).Seconds()
+	estimate := estimateOutputSize(settings, in)
+	if duration <= 0 || estimate <= 0 {
+		return 0, fmt.Errorf("output.splitSize can't be used for %s, there's nothing to estimate the output size from.  Use output.splitTime", in)
+	}
+	seconds = duration * float64(size) / float64(estimate) * 0.9
+	if seconds < 1 {
+		seconds = 1
+	}
+	return
+}
+
+// containerMuxers are the muxers -segment_format needs for the containers whose extension isn't the muxer's name
+var containerMuxers = map[string]string{
+	"mkv": "matroska",
+	"mka": "matroska",
+	"ts":  "mpegts",
+	"m4a": "ipod",
+	"aac": "adts",
+}
+
+// splitArgs replace -f with the segment muxer.  Re-encoded video gets a keyframe forced at every cut so each
+// piece starts cleanly, copied video can only be cut at the keyframes it already has so pieces run a bit long.
+func splitArgs(settings Settings, out string, seconds float64, movflags []string) (args []string) {
+	format := settings.Output.Format
+	if format == "" {
+		format = outputContainer(settings.Output, out)
+		if muxer, ok := containerMuxers[format]; ok {
+			format = muxer
+		}
+	}
+	args = append(args, []string{"-f", "segment", "-segment_time", fmt.Sprintf("%.3f", seconds), "-reset_timestamps", "1", "-segment_format", format}...)
+	if !settings.Video.JustCopy && !settings.Video.DisableVideo {
+		args = append(args, []string{"-force_key_frames", fmt.Sprintf("expr:gte(t,n_forced*%.3f)", seconds)}...)
+	}
+	if len(movflags) == 2 {
+		//the segment muxer hands options to the muxer underneath through here
+		args = append(args, []string{"-segment_format_options", "movflags=" + movflags[1]}...)
+	}
+	return
+}
+
+// splitOutput is the segment muxer arguments for out and the pattern to write in its place
+func splitOutput(settings Settings, in, out string) (args []string, pattern string, err error) {
+	seconds, err := splitSeconds(settings, in)
+	if err != nil {
+		return
+	}
+	log.Printf("splitting %s into %.0f second pieces\n", out, seconds)
+	return splitArgs(settings, out, seconds, movflagsArgs(settings.Output, out)), splitPattern(out), nil
+}
+
+// checkSplit catches split settings that can't work
+func checkSplit(o Output, out string) error {
+	switch {
+	case o.SplitTime > 0 && o.SplitSize != "":
+		return fmt.Errorf("output.splitTime and output.splitSize can't both be set")
+	case o.SplitTime < 0:
+		return fmt.Errorf("output.splitTime %d can't be negative", o.SplitTime)
+	case isSplit(o) && isStdout(out):
+		return fmt.Errorf("output.splitTime and output.splitSize write several files, they can't be used with -outfile -")
+	}
+	return nil
+}
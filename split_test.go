@@ -0,0 +1,73 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSplitArgs(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings Settings
+		out      string
+		movflags []string
+		want     []string
+	}{
+		{"mp4", Settings{}, "out.mp4", nil,
+			[]string{"-f", "segment", "-segment_time", "600.000", "-reset_timestamps", "1", "-segment_format", "mp4", "-force_key_frames", "expr:gte(t,n_forced*600.000)"}},
+		{"mkv", Settings{}, "out.mkv", nil,
+			[]string{"-f", "segment", "-segment_time", "600.000", "-reset_timestamps", "1", "-segment_format", "matroska", "-force_key_frames", "expr:gte(t,n_forced*600.000)"}},
+		{"ts", Settings{}, "out.ts", nil,
+			[]string{"-f", "segment", "-segment_time", "600.000", "-reset_timestamps", "1", "-segment_format", "mpegts", "-force_key_frames", "expr:gte(t,n_forced*600.000)"}},
+		{"format set", Settings{Output: Output{Format: "matroska"}}, "out.bin", nil,
+			[]string{"-f", "segment", "-segment_time", "600.000", "-reset_timestamps", "1", "-segment_format", "matroska", "-force_key_frames", "expr:gte(t,n_forced*600.000)"}},
+		{"copied video", Settings{Video: Video{JustCopy: true}}, "out.mkv", nil,
+			[]string{"-f", "segment", "-segment_time", "600.000", "-reset_timestamps", "1", "-segment_format", "matroska"}},
+		{"movflags", Settings{Video: Video{JustCopy: true}}, "out.mp4", []string{"-movflags", "+faststart"},
+			[]string{"-f", "segment", "-segment_time", "600.000", "-reset_timestamps", "1", "-segment_format", "mp4", "-segment_format_options", "movflags=+faststart"}},
+	}
+	for _, tt := range tests {
+		if got := splitArgs(tt.settings, tt.out, 600, tt.movflags); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: splitArgs = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCheckSplit(t *testing.T) {
+	tests := []struct {
+		output  Output
+		out     string
+		wantErr bool
+	}{
+		{Output{}, "out.mkv", false},
+		{Output{SplitTime: 600}, "out.mkv", false},
+		{Output{SplitSize: "2G"}, "out.mkv", false},
+		{Output{SplitTime: 600, SplitSize: "2G"}, "out.mkv", true},
+		{Output{SplitTime: -1}, "out.mkv", true},
+		{Output{SplitTime: 600}, "-", true},
+	}
+	for _, tt := range tests {
+		if err := checkSplit(tt.output, tt.out); (err != nil) != tt.wantErr {
+			t.Errorf("checkSplit(%+v, %s) = %v, want error %t", tt.output, tt.out, err, tt.wantErr)
+		}
+	}
+}
+
+func TestExpandSplit(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"ep1.part.mkv", "ep1.part_000.mkv", "ep1.part_001.mkv", "ep1.part_x.mkv", "other_000.mkv"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	partials := []string{filepath.Join(dir, "ep1.part.mkv"), filepath.Join(dir, "ep1.part.mp4")}
+	outs := []string{filepath.Join(dir, "ep1.mkv"), filepath.Join(dir, "ep1.mp4")}
+	gotPartials, gotOuts := expandSplit(partials, outs)
+	wantPartials := []string{filepath.Join(dir, "ep1.part_000.mkv"), filepath.Join(dir, "ep1.part_001.mkv"), filepath.Join(dir, "ep1.part.mp4")}
+	wantOuts := []string{filepath.Join(dir, "ep1_000.mkv"), filepath.Join(dir, "ep1_001.mkv"), filepath.Join(dir, "ep1.mp4")}
+	if !reflect.DeepEqual(gotPartials, wantPartials) || !reflect.DeepEqual(gotOuts, wantOuts) {
+		t.Errorf("expandSplit = %v, %v, want %v, %v", gotPartials, gotOuts, wantPartials, wantOuts)
+	}
+}
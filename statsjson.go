@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// statsLine is one line of -stats-json.  Times are in seconds, and percent and eta are 0 when the length of the
+// encode isn't known.  The last line for a file has done set, with the error if it failed.
+type statsLine struct {
+	File    string  `json:"file"`
+	Frame   int     `json:"frame"`
+	Fps     float64 `json:"fps"`
+	Speed   float64 `json:"speed"`
+	Time    float64 `json:"time"`
+	Percent float64 `json:"percent"`
+	Eta     float64 `json:"eta"`
+	Done    bool    `json:"done"`
+	Error   string  `json:"error,omitempty"`
+}
+
+// statsJsonWriter writes -stats-json for one file's encode
+type statsJsonWriter struct {
+	w     io.Writer
+	file  *os.File
+	in    string
+	total time.Duration
+}
+
+// statsFd is -stats-json's fd:N, opened once for the whole run.  An *os.File made for each file would close
+// the descriptor when it was garbage collected, and the next file in a batch would have nothing to write to.
+var statsFd *os.File
+
+// openStatsFd opens dest's file descriptor into statsFd when dest is fd:N
+func openStatsFd(dest string) error {
+	fd, ok := strings.CutPrefix(dest, "fd:")
+	if !ok {
+		return nil
+	}
+	n, err := strconv.Atoi(fd)
+	if err != nil || n < 0 {
+		return fmt.Errorf("-stats-json %s should be a file or fd: and a file descriptor number, ex: fd:3", dest)
+	}
+	statsFd = os.NewFile(uintptr(n), dest)
+	return nil
+}
+
+// newStatsJsonWriter opens dest, a file that's appended to so a batch's files all end up in it, or fd:N for a
+// file descriptor the program running ffmpegfront passed in
+func newStatsJsonWriter(dest, in string, total time.Duration) (s *statsJsonWriter, err error) {
+	s = &statsJsonWriter{in: in, total: total}
+	if strings.HasPrefix(dest, "fd:") {
+		if statsFd == nil {
+			if err = openStatsFd(dest); err != nil {
+				return nil, err
+			}
+		}
+		//not closed afterwards, the next file in a batch still needs it
+		s.w = statsFd
+		return s, nil
+	}
+	s.file, err = os.OpenFile(dest, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open -stats-json %s: %w", dest, err)
+	}
+	s.w = s.file
+	return s, nil
+}
+
+func (s *statsJsonWriter) line(p progressStats) statsLine {
+	return statsLine{
+		File:    s.in,
+		Frame:   p.Frame,
+		Fps:     p.Fps,
+		Speed:   p.Speed,
+		Time:    p.Time.Seconds(),
+		Percent: p.percent(s.total),
+		Eta:     p.eta(s.total).Seconds(),
+	}
+}
+
+// write puts l on its own line.  A gui that's stopped reading isn't a reason to fail the encode, so errors
+// are dropped.
+func (s *statsJsonWriter) write(l statsLine) {
+	data, err := json.Marshal(l)
+	if err != nil {
+		return
+	}
+	s.w.Write(append(data, '\n'))
+}
+
+// progress writes one of ffmpeg's status lines
+func (s *statsJsonWriter) progress(p progressStats) {
+	s.write(s.line(p))
+}
+
+// finish writes the last line for the file, with the encode's error if it failed
+func (s *statsJsonWriter) finish(p progressStats, err error) {
+	l := s.line(p)
+	l.Done = true
+	if err != nil {
+		l.Error = err.Error()
+	} else if s.total > 0 {
+		l.Percent, l.Eta = 100, 0
+	}
+	s.write(l)
+}
+
+func (s *statsJsonWriter) Close() {
+	if s.file != nil {
+		s.file.Close()
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStatsJsonWriter(t *testing.T) {
+	var buf bytes.Buffer
+	s := &statsJsonWriter{w: &buf, in: "in.mkv", total: 100 * time.Second}
+	p, ok := parseProgressLine("frame=  240 fps= 48 q=28.0 size=    1024kB time=00:00:25.00 bitrate= 335.5kbits/s speed=2.5x")
+	if !ok {
+		t.Fatal("parseProgressLine didn't parse an ffmpeg status line")
+	}
+	s.progress(p)
+	s.finish(p, nil)
+	s.finish(p, errors.New("exit status 1"))
+
+	want := []string{
+		`{"file":"in.mkv","frame":240,"fps":48,"speed":2.5,"time":25,"percent":25,"eta":30,"done":false}`,
+		`{"file":"in.mkv","frame":240,"fps":48,"speed":2.5,"time":25,"percent":100,"eta":0,"done":true}`,
+		`{"file":"in.mkv","frame":240,"fps":48,"speed":2.5,"time":25,"percent":25,"eta":30,"done":true,"error":"exit status 1"}`,
+	}
+	if got := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n"); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("-stats-json wrote\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestStatsJsonFileAppends(t *testing.T) {
+	dest := filepath.Join(t.TempDir(), "stats.json")
+	for _, in := range []string{"a.mkv", "b.mkv"} {
+		s, err := newStatsJsonWriter(dest, in, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.finish(progressStats{}, nil)
+		s.Close()
+	}
+	data, err := os.ReadFile(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(data), "\n"); lines != 2 {
+		t.Errorf("-stats-json file has %d lines after two files, want 2:\n%s", lines, data)
+	}
+}
+
+func TestOpenStatsFd(t *testing.T) {
+	defer func(f *os.File) { statsFd = f }(statsFd)
+	tests := []struct {
+		dest    string
+		wantFd  bool
+		wantErr bool
+	}{
+		{"stats.json", false, false},
+		{"fd:1000", true, false},
+		{"fd:", false, true},
+		{"fd:x", false, true},
+		{"fd:-1", false, true},
+	}
+	for _, tt := range tests {
+		statsFd = nil
+		err := openStatsFd(tt.dest)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("openStatsFd(%q) = %v, want error %t", tt.dest, err, tt.wantErr)
+		}
+		if (statsFd != nil) != tt.wantFd {
+			t.Errorf("openStatsFd(%q) opened %v, want a descriptor %t", tt.dest, statsFd, tt.wantFd)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+)
+
+// streamSpecifiers are the letters ffmpeg's stream specifiers use for each codec_type
+var streamSpecifiers = map[string]string{"video": "v", "audio": "a", "subtitle": "s", "data": "d", "attachment": "t"}
+
+// writeStreamTable writes probe's streams as a table.  map is the specifier to put in output.maps for that
+// track, ex: 0:a:1 is the second audio track, counted the way ffmpeg counts them rather than by index.
+func writeStreamTable(w io.Writer, probe probeData) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "index\tmap\ttype\tcodec\tlanguage\tchannels\tresolution\ttitle")
+	counts := map[string]int{}
+	for _, s := range probe.Streams {
+		spec := "-"
+		if letter, ok := streamSpecifiers[s.CodecType]; ok {
+			spec = fmt.Sprintf("0:%s:%d", letter, counts[s.CodecType])
+			counts[s.CodecType]++
+		}
+		channels, resolution := "-", "-"
+		if s.Channels > 0 {
+			channels = fmt.Sprintf("%d", s.Channels)
+		}
+		if s.Width > 0 && s.Height > 0 {
+			resolution = fmt.Sprintf("%dx%d", s.Width, s.Height)
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", s.Index, spec, orDash(s.CodecType), orDash(s.CodecName), orDash(s.Tags["language"]), channels, resolution, orDash(s.Tags["title"]))
+	}
+	return tw.Flush()
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// printStreams is -print-streams: it lists in's tracks so the right one can be picked for output.maps or
+// audio.audioLanguage, then exits
+func printStreams(in string) {
+	probe, err := probeFile(in)
+	if err != nil {
+		fatalf("%v\n", err)
+	}
+	if err := writeStreamTable(os.Stdout, probe); err != nil {
+		fatalf("%v\n", err)
+	}
+	os.Exit(0)
+}
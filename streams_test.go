@@ -0,0 +1,39 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWriteStreamTable(t *testing.T) {
+	probe := probeData{Streams: []probeStream{
+		{Index: 0, CodecType: "video", CodecName: "h264", Width: 1920, Height: 1080},
+		{Index: 1, CodecType: "audio", CodecName: "aac", Channels: 2, Tags: map[string]string{"language": "eng"}},
+		{Index: 2, CodecType: "audio", CodecName: "ac3", Channels: 6, Tags: map[string]string{"language": "fra", "title": "Surround"}},
+		{Index: 3, CodecType: "subtitle", CodecName: "subrip", Tags: map[string]string{"language": "eng"}},
+		{Index: 4, CodecType: "attachment", Tags: map[string]string{"title": "font.ttf"}},
+		{Index: 5},
+	}}
+	var buf bytes.Buffer
+	if err := writeStreamTable(&buf, probe); err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"index", "map", "type", "codec", "language", "channels", "resolution", "title"},
+		{"0", "0:v:0", "video", "h264", "-", "-", "1920x1080", "-"},
+		{"1", "0:a:0", "audio", "aac", "eng", "2", "-", "-"},
+		{"2", "0:a:1", "audio", "ac3", "fra", "6", "-", "Surround"},
+		{"3", "0:s:0", "subtitle", "subrip", "eng", "-", "-", "-"},
+		{"4", "0:t:0", "attachment", "-", "-", "-", "-", "font.ttf"},
+		{"5", "-", "-", "-", "-", "-", "-", "-"},
+	}
+	var got [][]string
+	for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n") {
+		got = append(got, strings.Fields(line))
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("writeStreamTable wrote\n%s\nwant the columns\n%q", buf.String(), want)
+	}
+}
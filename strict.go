@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// defaultStrictPatterns are ffmpeg messages that mean the input is damaged or the output may not be what was asked for
+var defaultStrictPatterns = []string{
+	`invalid data found`,
+	`error while decoding`,
+	`corrupt`,
+	`concealing \d+ (dc, \d+ ac, \d+ mv )?errors`,
+	`non[- ]monotonous dts|non monotonically increasing dts`,
+	`missing picture`,
+	`deprecated pixel format`,
+	`past duration .* too large`,
+	`packet mismatch`,
+	`invalid nal unit`,
+	`error submitting`,
+}
+
+// strictProblems returns the lines of ffmpeg's output that match any of the regexes
+func strictProblems(output string, regexes []*regexp.Regexp) (problems []string) {
+	for _, line := range strings.FieldsFunc(output, func(r rune) bool { return r == '\r' || r == '\n' }) {
+		for _, r := range regexes {
+			if r.MatchString(line) {
+				problems = append(problems, strings.TrimSpace(line))
+				break
+			}
+		}
+	}
+	return
+}
+
+// strictPatterns is the built-in list plus anything from -strict-patterns, compiled case insensitive
+func strictPatterns() (regexes []*regexp.Regexp, err error) {
+	patterns := append([]string{}, defaultStrictPatterns...)
+	for _, p := range strings.Split(*strictExtraPatterns, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			patterns = append(patterns, p)
+		}
+	}
+	for _, p := range patterns {
+		r, err := regexp.Compile("(?i)" + p)
+		if err != nil {
+			return nil, fmt.Errorf("-strict-patterns: %q isn't a valid regex: %w", p, err)
+		}
+		regexes = append(regexes, r)
+	}
+	return
+}
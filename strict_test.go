@@ -0,0 +1,61 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStrictPatterns(t *testing.T) {
+	defer func(p string) { *strictExtraPatterns = p }(*strictExtraPatterns)
+
+	tests := []struct {
+		extra     string
+		wantCount int
+		wantErr   bool
+	}{
+		{"", len(defaultStrictPatterns), false},
+		{"dropping frame", len(defaultStrictPatterns) + 1, false},
+		{" dropping frame , , queue input is backward ", len(defaultStrictPatterns) + 2, false},
+		{"unclosed (group", 0, true},
+	}
+	for _, tt := range tests {
+		*strictExtraPatterns = tt.extra
+		got, err := strictPatterns()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("strictPatterns with -strict-patterns %q error = %v, want error %t", tt.extra, err, tt.wantErr)
+			continue
+		}
+		if len(got) != tt.wantCount {
+			t.Errorf("strictPatterns with -strict-patterns %q = %d regexes, want %d", tt.extra, len(got), tt.wantCount)
+		}
+	}
+}
+
+func TestStrictProblems(t *testing.T) {
+	defer func(p string) { *strictExtraPatterns = p }(*strictExtraPatterns)
+	*strictExtraPatterns = "dropping frame"
+	regexes, err := strictPatterns()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output := "frame=  100 fps= 50 q=28.0 size=    1024kB\r" +
+		"[h264 @ 0x55] Error while decoding stream #0:0: Invalid data found when processing input\n" +
+		"[h264 @ 0x55] concealing 1234 DC, 1234 AC, 1234 MV errors in P frame\n" +
+		"frame=  200 fps= 50 q=28.0 size=    2048kB\r" +
+		"  [mp4 @ 0x56] Non-monotonous DTS in output stream 0:1  \n" +
+		"[vf#0:0 @ 0x57] Dropping frame 212 from the filter\n" +
+		"video:2048kB audio:256kB subtitle:0kB\n"
+	want := []string{
+		"[h264 @ 0x55] Error while decoding stream #0:0: Invalid data found when processing input",
+		"[h264 @ 0x55] concealing 1234 DC, 1234 AC, 1234 MV errors in P frame",
+		"[mp4 @ 0x56] Non-monotonous DTS in output stream 0:1",
+		"[vf#0:0 @ 0x57] Dropping frame 212 from the filter",
+	}
+	if got := strictProblems(output, regexes); !reflect.DeepEqual(got, want) {
+		t.Errorf("strictProblems = %q, want %q", got, want)
+	}
+	if got := strictProblems("frame=  100 fps= 50\nvideo:2048kB audio:256kB\n", regexes); got != nil {
+		t.Errorf("strictProblems on a clean run = %q, want none", got)
+	}
+}
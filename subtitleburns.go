@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+)
+
+// SubtitleBurn is one set of subtitles to burn in, for subtitles.burns.  The fields work like the ones on
+// Subtitles with the same names.
+type SubtitleBurn struct {
+	SubtitleFile        string `json:"subtitleFile" yaml:"subtitleFile"`
+	SubtitleTrack       int    `json:"subtitleTrack" yaml:"subtitleTrack"`
+	SubtitleStyle       string `json:"subtitleStyle" yaml:"subtitleStyle"`
+	SubtitleStylePreset string `json:"subtitleStylePreset" yaml:"subtitleStylePreset"`
+	SubtitleAlignment   int    `json:"subtitleAlignment" yaml:"subtitleAlignment"`
+	SubtitleMarginV     int    `json:"subtitleMarginV" yaml:"subtitleMarginV"`
+}
+
+// subtitleBurns is every set of subtitles s burns in, in the order they're drawn.  Without subtitles.burns
+// it's s itself.  With them, each one is s with that burn's file, track and style, sharing s's fontsDir.
+func subtitleBurns(s Subtitles) []Subtitles {
+	if len(s.Burns) == 0 {
+		return []Subtitles{s}
+	}
+	var burns []Subtitles
+	for _, b := range s.Burns {
+		burns = append(burns, Subtitles{
+			BurnInSubtitles:     true,
+			SubtitleFile:        b.SubtitleFile,
+			SubtitleTrack:       b.SubtitleTrack,
+			SubtitleStyle:       b.SubtitleStyle,
+			SubtitleStylePreset: b.SubtitleStylePreset,
+			SubtitleAlignment:   b.SubtitleAlignment,
+			SubtitleMarginV:     b.SubtitleMarginV,
+			FontsDir:            s.FontsDir,
+		})
+	}
+	return burns
+}
+
+// checkSubtitleSources runs checkSubtitleSource on every set of subtitles that gets burned in
+func checkSubtitleSources(s Subtitles, in string) error {
+	if len(s.Burns) == 0 {
+		return checkSubtitleSource(s, in)
+	}
+	for i, b := range subtitleBurns(s) {
+		if err := checkSubtitleSource(b, in); err != nil {
+			return fmt.Errorf("subtitles.burns %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// subtitlesFilters are the subtitles filters for every set of subtitles s burns in.  They're chained one
+// after another, so a later one is drawn over an earlier one where they overlap.
+func subtitlesFilters(s Subtitles, f string) (filters []string, err error) {
+	for _, b := range subtitleBurns(s) {
+		filter, err := subtitlesFilter(b, f)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, filter)
+	}
+	return
+}
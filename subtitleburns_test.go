@@ -0,0 +1,57 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSubtitlesFilters(t *testing.T) {
+	tests := []struct {
+		name    string
+		subs    Subtitles
+		want    []string
+		wantErr bool
+	}{
+		{"from the input", Subtitles{BurnInSubtitles: true}, []string{"subtitles='in.mkv'"}, false},
+		{"one file", Subtitles{BurnInSubtitles: true, SubtitleFile: "a.srt", SubtitleTrack: 1}, []string{"subtitles='a.srt:si=1'"}, false},
+		{"burns", Subtitles{
+			SubtitleFile: "ignored.srt",
+			FontsDir:     "fonts",
+			Burns: []SubtitleBurn{
+				{SubtitleFile: "signs.ass", SubtitleAlignment: 8},
+				{SubtitleTrack: 2, SubtitleStyle: "Fontsize=20"},
+			},
+		}, []string{"subtitles='signs.ass:fontsdir=fonts:force_style=Alignment=8'", "subtitles='in.mkv:si=2:fontsdir=fonts:force_style=Fontsize=20'"}, false},
+		{"bad burn style", Subtitles{Burns: []SubtitleBurn{{SubtitleFile: "a.srt"}, {SubtitleFile: "b.srt", SubtitleAlignment: 12}}}, nil, true},
+	}
+	for _, tt := range tests {
+		got, err := subtitlesFilters(tt.subs, "in.mkv")
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: subtitlesFilters error = %v, want error %t", tt.name, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: subtitlesFilters = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCheckSubtitleSources(t *testing.T) {
+	fakeFfprobe(t, `echo '{"streams":[{"codec_type":"video"},{"codec_type":"subtitle"},{"codec_type":"subtitle"}]}'`)
+	tests := []struct {
+		name    string
+		subs    Subtitles
+		wantErr bool
+	}{
+		{"input track", Subtitles{BurnInSubtitles: true, SubtitleTrack: 1}, false},
+		{"input track out of range", Subtitles{BurnInSubtitles: true, SubtitleTrack: 2}, true},
+		{"burns", Subtitles{Burns: []SubtitleBurn{{SubtitleTrack: 0}, {SubtitleTrack: 1}}}, false},
+		{"burn out of range", Subtitles{Burns: []SubtitleBurn{{SubtitleTrack: 0}, {SubtitleTrack: 5}}}, true},
+		{"burn file missing", Subtitles{Burns: []SubtitleBurn{{SubtitleFile: "missing.srt"}}}, true},
+	}
+	for _, tt := range tests {
+		if err := checkSubtitleSources(tt.subs, "in.mkv"); (err != nil) != tt.wantErr {
+			t.Errorf("%s: checkSubtitleSources = %v, want error %t", tt.name, err, tt.wantErr)
+		}
+	}
+}
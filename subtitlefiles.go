@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// subtitleSidecar is one subtitle track to be written to its own file next to the output
+type subtitleSidecar struct {
+	Stream int
+	Path   string
+	Codec  string
+	Format string
+}
+
+// subtitleFormats are the formats subtitle tracks can be converted to as files: codec, muxer and extension
+var subtitleFormats = map[string]struct{ Codec, Format, Ext string }{
+	"srt": {"srt", "srt", ".srt"},
+	"vtt": {"webvtt", "webvtt", ".vtt"},
+	"ass": {"ass", "ass", ".ass"},
+}
+
+// subtitleCopyFormats is where each codec goes when it's copied as it is.  Bitmap subs other than pgs have
+// no file format of their own, so they go in a subtitle only matroska file.  mov_text only exists inside
+// mp4, so it's converted to srt.
+var subtitleCopyFormats = map[string]struct{ Codec, Format, Ext string }{
+	"subrip":            {"copy", "srt", ".srt"},
+	"ass":               {"copy", "ass", ".ass"},
+	"ssa":               {"copy", "ass", ".ssa"},
+	"webvtt":            {"copy", "webvtt", ".vtt"},
+	"mov_text":          {"srt", "srt", ".srt"},
+	"text":              {"srt", "srt", ".srt"},
+	"hdmv_pgs_subtitle": {"copy", "sup", ".sup"},
+}
+
+// subtitleSidecars works out a file for every subtitle track in the input that can be written as format (srt,
+// vtt, ass, or copy to keep each track's own codec).  A single track is written next to the output as name.srt,
+// several get their language (or track number) added, ex: name.eng.srt.  Bitmap subs can't be turned into text
+// so they're skipped with a warning unless they're being copied.
+func subtitleSidecars(in, out, format string) (sidecars []subtitleSidecar, err error) {
+	if _, ok := subtitleFormats[format]; !ok && format != "copy" {
+		return nil, fmt.Errorf("%q is not a subtitle format, use srt, vtt, ass or copy", format)
+	}
+	probe, err := probeFile(in)
+	if err != nil {
+		return
+	}
+	subs := probe.streamsOfType("subtitle")
+	base := strings.TrimSuffix(out, filepath.Ext(out))
+
+	used := map[string]bool{}
+	for i, s := range subs {
+		target := subtitleFormats[format]
+		if format == "copy" {
+			var ok bool
+			if target, ok = subtitleCopyFormats[s.CodecName]; !ok {
+				target = struct{ Codec, Format, Ext string }{"copy", "matroska", ".mks"}
+			}
+		} else if !stringInList(s.CodecName, textSubtitleCodecs) {
+			log.Printf("warning: subtitle stream #%d is %s, which can't be converted to %s, skipping it\n", s.Index, s.CodecName, format)
+			continue
+		}
+
+		name := ""
+		if len(subs) > 1 {
+			name = s.Tags["language"]
+			if name == "" || name == "und" || used[name] {
+				name = fmt.Sprintf("%d", i)
+			}
+			used[name] = true
+			name = "." + name
+		}
+		sidecars = append(sidecars, subtitleSidecar{Stream: i, Path: base + name + target.Ext, Codec: target.Codec, Format: target.Format})
+	}
+	return
+}
+
+// subtitleSidecarArgs writes one subtitle track to its own file, cut the same way as the main encode so the
+// cues line up with it
+func subtitleSidecarArgs(t Time, in string, s subtitleSidecar) (args []string) {
+	args = append(args, "-hide_banner", "-i", in)
+	if t.TimeSkipIntro != 0 {
+		args = append(args, []string{"-ss", fmt.Sprintf("%d", t.TimeSkipIntro)}...)
+	}
+	if t.TotalTime != 0 {
+		args = append(args, []string{"-t", fmt.Sprintf("%d", t.TotalTime)}...)
+	}
+	args = append(args, []string{"-map", fmt.Sprintf("0:s:%d", s.Stream), "-vn", "-an", "-c:s", s.Codec, "-f", s.Format, "-y", s.Path}...)
+	return
+}
+
+// writeSubtitleSidecars runs one ffmpeg per sidecar and returns the files it wrote.  A failed track is
+// logged and skipped rather than failing the whole run.
+func writeSubtitleSidecars(t Time, in string, sidecars []subtitleSidecar) (written []string) {
+	for _, s := range sidecars {
+		output, err := exec.Command(ffmpegBin, subtitleSidecarArgs(t, in, s)...).CombinedOutput()
+		if err != nil {
+			log.Printf("unable to write %s: %v: %s\n", s.Path, err, strings.TrimSpace(string(output)))
+			continue
+		}
+		written = append(written, s.Path)
+	}
+	return
+}
+
+// runExtractSubtitles does -extract-subtitles for -infile or every file in -indir, then exits
+func runExtractSubtitles(singleFile bool) {
+	jobs := []batchJob{{In: *inFile, Out: *outFile}}
+	if !singleFile {
+		if *inDir == "" || *outDir == "" {
+			fatalf("-extract-subtitles needs -infile and -outfile, or -indir and -outdir")
+		}
+		jobs = findBatchJobs(*inDir, *outDir)
+	}
+	resolveFfmpeg()
+
+	failed := 0
+	for _, job := range jobs {
+		if !extractSubtitles(job.In, job.Out) {
+			failed++
+		}
+	}
+	if failed > 0 {
+		fatalf("couldn't extract all the subtitles from %d of %d files\n", failed, len(jobs))
+	}
+	os.Exit(0)
+}
+
+// extractSubtitles is -extract-subtitles: every subtitle track of in written to files named after out, and
+// nothing else.  No settings are needed.  It returns false if any track couldn't be written.
+func extractSubtitles(in, out string) (ok bool) {
+	sidecars, err := subtitleSidecars(in, out, *extractSubtitlesFlag)
+	if err != nil {
+		log.Printf("unable to extract subtitles from %s: %v\n", in, err)
+		return false
+	}
+	if len(sidecars) == 0 {
+		log.Printf("%s has no subtitles to extract\n", in)
+		return true
+	}
+	if *argsOnly {
+		for _, s := range sidecars {
+			fmt.Println(commandLine(ffmpegBin, subtitleSidecarArgs(Time{}, in, s)))
+		}
+		return true
+	}
+	if err := os.MkdirAll(filepath.Dir(out), 0755); err != nil {
+		log.Printf("unable to create output directory for %s: %v\n", out, err)
+		return false
+	}
+	written := writeSubtitleSidecars(Time{}, in, sidecars)
+	log.Printf("extracted %d of %d subtitle tracks from %s: %v\n", len(written), len(sidecars), in, written)
+	return len(written) == len(sidecars)
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSubtitleSidecarArgs(t *testing.T) {
+	vtt := subtitleSidecar{Stream: 1, Path: "out.eng.vtt", Codec: "webvtt", Format: "webvtt"}
+	tests := []struct {
+		time Time
+		want []string
+	}{
+		{Time{}, []string{"-hide_banner", "-i", "in.mkv", "-map", "0:s:1", "-vn", "-an", "-c:s", "webvtt", "-f", "webvtt", "-y", "out.eng.vtt"}},
+		{Time{TimeSkipIntro: 90}, []string{"-hide_banner", "-i", "in.mkv", "-ss", "90", "-map", "0:s:1", "-vn", "-an", "-c:s", "webvtt", "-f", "webvtt", "-y", "out.eng.vtt"}},
+		{Time{TimeSkipIntro: 90, TotalTime: 600}, []string{"-hide_banner", "-i", "in.mkv", "-ss", "90", "-t", "600", "-map", "0:s:1", "-vn", "-an", "-c:s", "webvtt", "-f", "webvtt", "-y", "out.eng.vtt"}},
+	}
+	for _, tt := range tests {
+		got := subtitleSidecarArgs(tt.time, "in.mkv", vtt)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("subtitleSidecarArgs(%+v) = %v, want %v", tt.time, got, tt.want)
+		}
+	}
+}
+
+func TestSubtitleSidecarsFormat(t *testing.T) {
+	if _, err := subtitleSidecars("in.mkv", "out.mkv", "sub"); err == nil {
+		t.Errorf("subtitleSidecars with format sub: got no error")
+	}
+}
+
+func TestSubtitleSidecars(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	one := `{"streams":[{"index":0,"codec_type":"video"},{"index":1,"codec_type":"subtitle","codec_name":"subrip","tags":{"language":"eng"}}]}`
+	several := `{"streams":[{"index":0,"codec_type":"video"},` +
+		`{"index":1,"codec_type":"subtitle","codec_name":"subrip","tags":{"language":"eng"}},` +
+		`{"index":2,"codec_type":"subtitle","codec_name":"ass","tags":{"language":"eng"}},` +
+		`{"index":3,"codec_type":"subtitle","codec_name":"mov_text","tags":{"language":"und"}},` +
+		`{"index":4,"codec_type":"subtitle","codec_name":"hdmv_pgs_subtitle","tags":{"language":"fra"}},` +
+		`{"index":5,"codec_type":"subtitle","codec_name":"dvd_subtitle"}]}`
+	tests := []struct {
+		name   string
+		probe  string
+		format string
+		want   []subtitleSidecar
+	}{
+		{"one track", one, "srt", []subtitleSidecar{{0, "dir/ep1.srt", "srt", "srt"}}},
+		{"one track copied", one, "copy", []subtitleSidecar{{0, "dir/ep1.srt", "copy", "srt"}}},
+		{"several to vtt", several, "vtt", []subtitleSidecar{
+			{0, "dir/ep1.eng.vtt", "webvtt", "webvtt"},
+			{1, "dir/ep1.1.vtt", "webvtt", "webvtt"},
+			{2, "dir/ep1.2.vtt", "webvtt", "webvtt"},
+		}},
+		{"several copied", several, "copy", []subtitleSidecar{
+			{0, "dir/ep1.eng.srt", "copy", "srt"},
+			{1, "dir/ep1.1.ass", "copy", "ass"},
+			{2, "dir/ep1.2.srt", "srt", "srt"},
+			{3, "dir/ep1.fra.sup", "copy", "sup"},
+			{4, "dir/ep1.4.mks", "copy", "matroska"},
+		}},
+		{"none", `{"streams":[{"index":0,"codec_type":"video"}]}`, "srt", nil},
+	}
+	for _, tt := range tests {
+		fakeFfprobe(t, "echo '"+tt.probe+"'")
+		got, err := subtitleSidecars("in.mkv", "dir/ep1.mkv", tt.format)
+		if err != nil {
+			t.Errorf("%s: subtitleSidecars error = %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: subtitleSidecars = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestExtractSubtitles(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+	defer func(f string, a bool) { *extractSubtitlesFlag, *argsOnly = f, a }(*extractSubtitlesFlag, *argsOnly)
+	*extractSubtitlesFlag, *argsOnly = "srt", false
+	fakeFfprobe(t, `echo '{"streams":[{"index":0,"codec_type":"video"},{"index":1,"codec_type":"subtitle","codec_name":"subrip","tags":{"language":"eng"}},{"index":2,"codec_type":"subtitle","codec_name":"ass","tags":{"language":"jpn"}}]}'`)
+
+	//writes its last argument, the sidecar path, with the arguments in it
+	fakeFfmpeg(t, `for last; do :; done; echo "$@" > "$last"`)
+	out := filepath.Join(t.TempDir(), "new", "ep1.mkv")
+	if !extractSubtitles("in.mkv", out) {
+		t.Fatalf("extractSubtitles(in.mkv, %s) = false, want true", out)
+	}
+	for _, want := range []struct{ file, args string }{
+		{"ep1.eng.srt", "-map 0:s:0 -vn -an -c:s srt -f srt"},
+		{"ep1.jpn.srt", "-map 0:s:1 -vn -an -c:s srt -f srt"},
+	} {
+		data, err := os.ReadFile(filepath.Join(filepath.Dir(out), want.file))
+		if err != nil {
+			t.Errorf("extractSubtitles didn't write %s: %v", want.file, err)
+			continue
+		}
+		if !strings.Contains(string(data), want.args) {
+			t.Errorf("%s was written with %q, want %q in it", want.file, strings.TrimSpace(string(data)), want.args)
+		}
+	}
+	if _, err := os.Stat(out); !os.IsNotExist(err) {
+		t.Errorf("extractSubtitles wrote %s, want only the subtitles: %v", out, err)
+	}
+
+	fakeFfmpeg(t, "exit 1")
+	if extractSubtitles("in.mkv", out) {
+		t.Errorf("extractSubtitles with ffmpeg failing = true, want false")
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// assStyleFields are the ASS style fields force_style understands, and whether each takes a number.
+// libass matches them case insensitively, so they're kept lowercase here.
+var assStyleFields = map[string]bool{
+	"name": false, "fontname": false, "fontsize": true,
+	"primarycolour": false, "secondarycolour": false, "outlinecolour": false, "backcolour": false,
+	"bold": true, "italic": true, "underline": true, "strikeout": true,
+	"scalex": true, "scaley": true, "spacing": true, "angle": true,
+	"borderstyle": true, "outline": true, "shadow": true, "alignment": true,
+	"marginl": true, "marginr": true, "marginv": true, "encoding": true,
+}
+
+var assColourRegex = regexp.MustCompile(`^&[Hh][0-9A-Fa-f]{1,8}&?$`)
+
+// subtitleStylePreset is a friendlier way to write a force_style.  Colours are #RRGGBB, or #RRGGBBAA where AA is
+// how opaque it is (FF is solid), and are turned into ASS's backwards &HAABBGGRR&.  box puts the text on a box of
+// backColour instead of outlining it.  Anything left out is libass's default.
+type subtitleStylePreset struct {
+	Font          string  `json:"font"`
+	Size          float64 `json:"size"`
+	Colour        string  `json:"colour"`
+	OutlineColour string  `json:"outlineColour"`
+	BackColour    string  `json:"backColour"`
+	Bold          bool    `json:"bold"`
+	Italic        bool    `json:"italic"`
+	Outline       float64 `json:"outline"`
+	Shadow        float64 `json:"shadow"`
+	Box           bool    `json:"box"`
+	Alignment     int     `json:"alignment"`
+	MarginV       int     `json:"marginV"`
+}
+
+// subtitleStylePresets are the built in presets for subtitleStylePreset
+var subtitleStylePresets = map[string]subtitleStylePreset{
+	"large-yellow": {Font: "Arial", Size: 28, Colour: "#FFFF00", OutlineColour: "#000000", Outline: 2, Shadow: 1},
+	"netflix-like": {Font: "Arial", Size: 22, Colour: "#FFFFFF", OutlineColour: "#000000", BackColour: "#00000080", Outline: 1.5, Shadow: 1, MarginV: 30},
+	"boxed":        {Font: "Arial", Size: 22, Colour: "#FFFFFF", BackColour: "#000000A0", Box: true, Outline: 4},
+}
+
+var presetColourRegex = regexp.MustCompile(`^#([0-9A-Fa-f]{6})([0-9A-Fa-f]{2})?$`)
+
+// assColour turns #RRGGBB(AA) into an ASS colour.  ASS alpha counts transparency rather than opacity.
+func assColour(field, colour string) (string, error) {
+	m := presetColourRegex.FindStringSubmatch(colour)
+	if m == nil {
+		return "", fmt.Errorf("subtitleStylePreset: %s should be a colour like #FFFF00 or #00000080, not %q", field, colour)
+	}
+	alpha := int64(0)
+	if m[2] != "" {
+		opacity, _ := strconv.ParseInt(m[2], 16, 64)
+		alpha = 255 - opacity
+	}
+	rgb := strings.ToUpper(m[1])
+	return fmt.Sprintf("&H%02X%s%s%s&", alpha, rgb[4:6], rgb[2:4], rgb[0:2]), nil
+}
+
+// forceStyle is the preset as force_style Key=Value pairs
+func (p subtitleStylePreset) forceStyle() (style string, err error) {
+	var pairs []string
+	if p.Font != "" {
+		pairs = append(pairs, "FontName="+p.Font)
+	}
+	if p.Size != 0 {
+		pairs = append(pairs, fmt.Sprintf("Fontsize=%g", p.Size))
+	}
+	for _, c := range []struct{ Field, Key, Value string }{
+		{"colour", "PrimaryColour", p.Colour},
+		{"outlineColour", "OutlineColour", p.OutlineColour},
+		{"backColour", "BackColour", p.BackColour},
+	} {
+		if c.Value == "" {
+			continue
+		}
+		colour, err := assColour(c.Field, c.Value)
+		if err != nil {
+			return "", err
+		}
+		pairs = append(pairs, c.Key+"="+colour)
+	}
+	if p.Bold {
+		pairs = append(pairs, "Bold=1")
+	}
+	if p.Italic {
+		pairs = append(pairs, "Italic=1")
+	}
+	if p.Box {
+		pairs = append(pairs, "BorderStyle=3")
+	}
+	if p.Outline != 0 {
+		pairs = append(pairs, fmt.Sprintf("Outline=%g", p.Outline))
+	}
+	if p.Shadow != 0 {
+		pairs = append(pairs, fmt.Sprintf("Shadow=%g", p.Shadow))
+	}
+	if p.Alignment != 0 {
+		pairs = append(pairs, fmt.Sprintf("Alignment=%d", p.Alignment))
+	}
+	if p.MarginV != 0 {
+		pairs = append(pairs, fmt.Sprintf("MarginV=%d", p.MarginV))
+	}
+	style = strings.Join(pairs, ",")
+	return
+}
+
+// loadStylePreset finds subtitleStylePreset: a built in preset's name, or a json file of named presets.  A file
+// with more than one picks with file.json#name.
+func loadStylePreset(name string) (preset subtitleStylePreset, err error) {
+	if preset, ok := subtitleStylePresets[name]; ok {
+		return preset, nil
+	}
+	file, pick, _ := strings.Cut(name, "#")
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return preset, fmt.Errorf("subtitleStylePreset %q isn't one of the built in presets (large-yellow, netflix-like, boxed) or a file that can be read: %w", name, err)
+	}
+	var presets map[string]subtitleStylePreset
+	if err = json.Unmarshal(data, &presets); err != nil {
+		return preset, fmt.Errorf("subtitleStylePreset: unable to parse %s: %w", file, err)
+	}
+	if pick == "" && len(presets) == 1 {
+		for _, p := range presets {
+			return p, nil
+		}
+	}
+	preset, ok := presets[pick]
+	if !ok {
+		var names []string
+		for n := range presets {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return preset, fmt.Errorf("subtitleStylePreset: %s has no preset %q, pick one with %s#name from %v", file, pick, file, names)
+	}
+	return preset, nil
+}
+
+// subtitleStyle checks subtitleStyle's Key=Value pairs and adds subtitleAlignment and subtitleMarginV to them,
+// those win over an Alignment or MarginV already in the string.  A bad pair would otherwise only show up
+// as the subtitles filter failing to parse once ffmpeg starts.  subtitleStylePreset goes first, so subtitleStyle
+// can change bits of it: force_style takes the last value a field is given.
+func subtitleStyle(s Subtitles) (style string, err error) {
+	var pairs []string
+	raw := strings.Trim(strings.TrimSpace(s.SubtitleStyle), `'"`)
+	if s.SubtitleStylePreset != "" {
+		preset, err := loadStylePreset(s.SubtitleStylePreset)
+		if err != nil {
+			return "", err
+		}
+		presetStyle, err := preset.forceStyle()
+		if err != nil {
+			return "", err
+		}
+		if raw != "" && presetStyle != "" {
+			presetStyle += ","
+		}
+		raw = presetStyle + raw
+	}
+	if raw != "" {
+		for _, pair := range strings.Split(raw, ",") {
+			kv := strings.SplitN(pair, "=", 2)
+			key := strings.TrimSpace(kv[0])
+			if len(kv) != 2 || key == "" || strings.TrimSpace(kv[1]) == "" {
+				return "", fmt.Errorf("subtitleStyle: %q should be Key=Value, ex: Fontsize=24", pair)
+			}
+			value := strings.TrimSpace(kv[1])
+			lower := strings.ToLower(key)
+			numeric, known := assStyleFields[lower]
+			switch {
+			case !known:
+				return "", fmt.Errorf("subtitleStyle: %s is not an ASS style field", key)
+			case numeric:
+				if _, err := strconv.ParseFloat(value, 64); err != nil {
+					return "", fmt.Errorf("subtitleStyle: %s should be a number, not %s", key, value)
+				}
+			case strings.HasSuffix(lower, "colour") && !assColourRegex.MatchString(value):
+				return "", fmt.Errorf("subtitleStyle: %s should be a colour like &H00FFFFFF&, not %s", key, value)
+			}
+			if (lower == "alignment" && s.SubtitleAlignment != 0) || (lower == "marginv" && s.SubtitleMarginV != 0) {
+				continue
+			}
+			pairs = append(pairs, key+"="+value)
+		}
+	}
+
+	if s.SubtitleAlignment != 0 {
+		if s.SubtitleAlignment < 1 || s.SubtitleAlignment > 9 {
+			return "", fmt.Errorf("subtitleAlignment %d should be 1-9, laid out like a numpad: 2 is bottom center, 8 top center", s.SubtitleAlignment)
+		}
+		pairs = append(pairs, fmt.Sprintf("Alignment=%d", s.SubtitleAlignment))
+	}
+	if s.SubtitleMarginV < 0 {
+		return "", fmt.Errorf("subtitleMarginV %d can't be negative", s.SubtitleMarginV)
+	}
+	if s.SubtitleMarginV != 0 {
+		pairs = append(pairs, fmt.Sprintf("MarginV=%d", s.SubtitleMarginV))
+	}
+	style = strings.Join(pairs, ",")
+	return
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSubtitleStyle(t *testing.T) {
+	tests := []struct {
+		subs    Subtitles
+		want    string
+		wantErr bool
+	}{
+		{Subtitles{}, "", false},
+		{Subtitles{SubtitleStyle: "FontName=ubuntu,Fontsize=24,PrimaryColour=&H0000ff&"}, "FontName=ubuntu,Fontsize=24,PrimaryColour=&H0000ff&", false},
+		{Subtitles{SubtitleStyle: "'Fontsize=24'"}, "Fontsize=24", false},
+		{Subtitles{SubtitleStyle: " fontsize = 24 "}, "fontsize=24", false},
+		{Subtitles{SubtitleStyle: "Fontsize=big"}, "", true},
+		{Subtitles{SubtitleStyle: "Fontsize"}, "", true},
+		{Subtitles{SubtitleStyle: "Fontsize="}, "", true},
+		{Subtitles{SubtitleStyle: "Size=24"}, "", true},
+		{Subtitles{SubtitleStyle: "PrimaryColour=yellow"}, "", true},
+		{Subtitles{SubtitleAlignment: 8}, "Alignment=8", false},
+		{Subtitles{SubtitleAlignment: 10}, "", true},
+		{Subtitles{SubtitleMarginV: 40}, "MarginV=40", false},
+		{Subtitles{SubtitleMarginV: -1}, "", true},
+		{Subtitles{SubtitleStyle: "Alignment=2,MarginV=10,Bold=1", SubtitleAlignment: 8, SubtitleMarginV: 40}, "Bold=1,Alignment=8,MarginV=40", false},
+		{Subtitles{SubtitleStyle: "Alignment=2,MarginV=10"}, "Alignment=2,MarginV=10", false},
+	}
+	for _, tt := range tests {
+		got, err := subtitleStyle(tt.subs)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("subtitleStyle(%+v) error = %v, want error %t", tt.subs, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("subtitleStyle(%+v) = %q, want %q", tt.subs, got, tt.want)
+		}
+	}
+}
+
+func TestAssColour(t *testing.T) {
+	tests := []struct {
+		colour  string
+		want    string
+		wantErr bool
+	}{
+		{"#FFFF00", "&H0000FFFF&", false},
+		{"#ff8000", "&H000080FF&", false},
+		{"#00000080", "&H7F000000&", false},
+		{"#FFFFFFFF", "&H00FFFFFF&", false},
+		{"#FFFFFF00", "&HFFFFFFFF&", false},
+		{"FFFF00", "", true},
+		{"#FFF", "", true},
+		{"yellow", "", true},
+	}
+	for _, tt := range tests {
+		got, err := assColour("colour", tt.colour)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("assColour(%q) error = %v, want error %t", tt.colour, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("assColour(%q) = %q, want %q", tt.colour, got, tt.want)
+		}
+	}
+}
+
+func TestStylePreset(t *testing.T) {
+	dir := t.TempDir()
+	one := filepath.Join(dir, "one.json")
+	two := filepath.Join(dir, "two.json")
+	os.WriteFile(one, []byte(`{"mine": {"font": "Ubuntu", "size": 24, "italic": true}}`), 0644)
+	os.WriteFile(two, []byte(`{"top": {"alignment": 8}, "bad": {"colour": "red"}}`), 0644)
+	tests := []struct {
+		subs    Subtitles
+		want    string
+		wantErr bool
+	}{
+		{Subtitles{SubtitleStylePreset: "large-yellow"}, "FontName=Arial,Fontsize=28,PrimaryColour=&H0000FFFF&,OutlineColour=&H00000000&,Outline=2,Shadow=1", false},
+		{Subtitles{SubtitleStylePreset: "boxed"}, "FontName=Arial,Fontsize=22,PrimaryColour=&H00FFFFFF&,BackColour=&H5F000000&,BorderStyle=3,Outline=4", false},
+		{Subtitles{SubtitleStylePreset: "netflix-like", SubtitleStyle: "Fontsize=30", SubtitleMarginV: 50}, "FontName=Arial,Fontsize=22,PrimaryColour=&H00FFFFFF&,OutlineColour=&H00000000&,BackColour=&H7F000000&,Outline=1.5,Shadow=1,Fontsize=30,MarginV=50", false},
+		{Subtitles{SubtitleStylePreset: one}, "FontName=Ubuntu,Fontsize=24,Italic=1", false},
+		{Subtitles{SubtitleStylePreset: one + "#mine"}, "FontName=Ubuntu,Fontsize=24,Italic=1", false},
+		{Subtitles{SubtitleStylePreset: two + "#top"}, "Alignment=8", false},
+		{Subtitles{SubtitleStylePreset: two}, "", true},
+		{Subtitles{SubtitleStylePreset: two + "#bad"}, "", true},
+		{Subtitles{SubtitleStylePreset: one + "#other"}, "", true},
+		{Subtitles{SubtitleStylePreset: "huge-pink"}, "", true},
+	}
+	for _, tt := range tests {
+		got, err := subtitleStyle(tt.subs)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("subtitleStyle(%+v) error = %v, want error %t", tt.subs, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("subtitleStyle(%+v) = %q, want %q", tt.subs, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// targetSizeOverhead is the share of the file the container takes, headers, indexes and all.  Two pass hits
+// the bitrate closely but not exactly, so this leaves a little slack too.
+const targetSizeOverhead = 0.02
+
+// minTargetVideoBitrate is the least video bitrate (bits/s) worth encoding, below it the video is mush
+const minTargetVideoBitrate = 100000
+
+// losslessAudioCodecs have no bitrate to plan a target size around
+var losslessAudioCodecs = []string{"flac", "alac", "pcm_s16le", "pcm_s24le", "truehd"}
+
+// parseTargetSize reads video.targetSize, ex: 700M, 700MB or 4.7G.  Units are powers of 1000 like disc makers
+// use, so 700MB is a little under what a cd holds and always fits.
+func parseTargetSize(s string) (size int64, err error) {
+	trimmed := strings.TrimSpace(s)
+	if len(trimmed) > 2 && strings.ContainsAny(trimmed[len(trimmed)-2:len(trimmed)-1], "KMGTkmgt") && strings.ContainsAny(trimmed[len(trimmed)-1:], "Bb") {
+		trimmed = trimmed[:len(trimmed)-1]
+	}
+	size, err = parseSize(trimmed, 1000)
+	if err != nil || size <= 0 {
+		return 0, fmt.Errorf("video.targetSize %q should be a size like 700M or 4.7G", s)
+	}
+	return
+}
+
+// targetVideoBitrate is the video bitrate (bits/s) that makes an output of duration come out at size bytes,
+// once the audio's share and the container's overhead are taken out
+func targetVideoBitrate(size int64, duration time.Duration, audioBitrate int64) (bitrate int64, err error) {
+	if duration <= 0 {
+		return 0, fmt.Errorf("video.targetSize needs the input's duration to work out a bitrate, and it couldn't be found")
+	}
+	total := float64(size) * 8 * (1 - targetSizeOverhead) / duration.Seconds()
+	bitrate = int64(total) - audioBitrate
+	if bitrate < minTargetVideoBitrate {
+		return 0, fmt.Errorf("video.targetSize is too small for %s, it only has room for %dk a second and %dk of that is the audio", duration.Round(time.Second), int64(total)/1000, audioBitrate/1000)
+	}
+	return
+}
+
+// targetAudioBitrate is how much of the target size the audio takes, in bits/s.  Copied audio is as big as
+// the input's, encoded audio is its fixed bitrate.  Vbr and lossless audio have no size known beforehand.
+func targetAudioBitrate(a Audio, in string) (bitrate int64, err error) {
+	if a.DisableAudio {
+		return 0, nil
+	}
+	if a.JustCopy {
+		probe, err := probeFile(in)
+		if err != nil {
+			return 0, err
+		}
+		var n int
+		fmt.Sscanf(audioStream(a, in), "0:a:%d", &n)
+		if streams := probe.streamsOfType("audio"); n < len(streams) {
+			if _, err := fmt.Sscanf(streams[n].BitRate, "%d", &bitrate); err == nil {
+				return bitrate, nil
+			}
+		}
+		return 0, fmt.Errorf("video.targetSize can't tell how big the copied audio is, ffprobe doesn't know its bitrate.  Encode the audio with a fixed auidioBitrate instead")
+	}
+
+	if a.AudioQuality != "" {
+		return 0, fmt.Errorf("video.targetSize can't plan around vbr audio, set audio.auidioBitrate instead of audioQuality")
+	}
+	codec := a.AudioCodec
+	if c, ok := encoderCodecs[codec]; ok {
+		codec = c
+	}
+	if stringInList(codec, losslessAudioCodecs) {
+		return 0, fmt.Errorf("video.targetSize can't plan around %s audio, it's lossless and has no fixed bitrate", codec)
+	}
+	audioBitrate := a.AudioBitrate
+	if audioBitrate == "" {
+		audioBitrate = "192k"
+	}
+	bitrate, err = parseSize(audioBitrate, 1000)
+	if err != nil {
+		return 0, fmt.Errorf("audio.auidioBitrate: %w", err)
+	}
+	return
+}
+
+// targetSizeSettings turns video.targetSize into a cbr video bitrate for a two pass encode
+func targetSizeSettings(settings Settings, in string) (Settings, error) {
+	v := settings.Video
+	size, err := parseTargetSize(v.TargetSize)
+	if err != nil {
+		return settings, err
+	}
+	switch {
+	case v.JustCopy || v.DisableVideo:
+		return settings, fmt.Errorf("video.targetSize needs the video encoded, it can't be used with justCopy or disableVideo")
+	case encoderFamily(videoEncoder(v)) != "software":
+		return settings, fmt.Errorf("video.targetSize needs a two pass software encoder like libx264 or libx265, %s can't do two pass.  Use mode cbr with a videoBitrate instead", videoEncoder(v))
+	case len(settings.Outputs) > 0:
+		return settings, fmt.Errorf("video.targetSize can't be used with outputs, each would need its own pair of passes")
+	case len(settings.Time.Segments) > 0:
+		return settings, fmt.Errorf("video.targetSize can't be used with time.segments")
+	}
+
+	audio, err := targetAudioBitrate(settings.Audio, in)
+	if err != nil {
+		return settings, err
+	}
+	video, err := targetVideoBitrate(size, expectedDuration(settings, in), audio)
+	if err != nil {
+		return settings, err
+	}
+	log.Printf("video.targetSize %s: encoding the video at %dk in two passes\n", v.TargetSize, video/1000)
+	settings.Video.Mode = "cbr"
+	settings.Video.VideoBitrate = fmt.Sprintf("%dk", video/1000)
+	return settings, nil
+}
+
+// passArgs tell encoder which pass this is and where the first pass leaves its stats for the second.
+// libx265 takes them in its own params rather than ffmpeg's -pass.
+func passArgs(encoder string, pass int, passLog string) []string {
+	if encoder == "libx265" {
+		return []string{"-x265-params", fmt.Sprintf("pass=%d:stats=%s", pass, passLog+".log")}
+	}
+	return []string{"-pass", fmt.Sprintf("%d", pass), "-passlogfile", passLog}
+}
+
+// withX265Params adds params to args' -x265-params, or adds -x265-params if there isn't one.  ffmpeg only keeps
+// the last -x265-params, so a second one would throw away the first's, like hdr's.
+func withX265Params(args []string, params string) []string {
+	for i := 0; i+1 < len(args); i++ {
+		if args[i] == "-x265-params" {
+			args[i+1] += ":" + params
+			return args
+		}
+	}
+	return append(args, []string{"-x265-params", params}...)
+}
+
+// withPassArgs puts passArgs in front of the output, which is always the last argument
+func withPassArgs(args []string, v Video, pass int, passLog string) []string {
+	last := len(args) - 1
+	withPass := append([]string{}, args[:last]...)
+	pa := passArgs(videoEncoder(v), pass, passLog)
+	if pa[0] == "-x265-params" {
+		withPass = withX265Params(withPass, pa[1])
+	} else {
+		withPass = append(withPass, pa...)
+	}
+	return append(withPass, args[last])
+}
+
+// firstPassArgs are the arguments for the first pass, which only needs the video analysed.  Nothing's
+// written, so there's no audio, subtitles or container to bother with.
+func firstPassArgs(log *log.Logger, settings Settings, in, passLog string) ([]string, error) {
+	settings.Audio.DisableAudio = true
+	settings.Subtitles.CopySubtitles = false
+	settings.Output = Output{Format: "null", Maps: settings.Output.Maps}
+	settings.Ready.NoOverwrite = false
+	args, err := buildArgs(log, settings, in, []string{os.DevNull})
+	if err != nil {
+		return nil, err
+	}
+	return withPassArgs(args, settings.Video, 1, passLog), nil
+}
+
+// argsOnlyPassLog is where the stats would go, for -args-only to show without making anything
+func argsOnlyPassLog() string {
+	dir := *tmpDir
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "ffmpegfront-2pass", "pass")
+}
+
+// newPassLog makes somewhere in -tmpdir for the stats the first pass leaves for the second.  cleanup
+// removes it once the second pass is done.
+func newPassLog() (passLog string, cleanup func(), err error) {
+	dir, err := os.MkdirTemp(runTempDir, "ffmpegfront-2pass")
+	if err != nil {
+		return "", func() {}, fmt.Errorf("unable to make a directory for the two pass stats: %w", err)
+	}
+	return filepath.Join(dir, "pass"), func() { os.RemoveAll(dir) }, nil
+}
+
+// runFirstPass runs the first of video.targetSize's two passes
+func runFirstPass(ctx context.Context, log *log.Logger, settings Settings, in, passLog string, env []string) error {
+	args, err := firstPassArgs(log, settings, in, passLog)
+	if err != nil {
+		return err
+	}
+	log.Printf("first pass for video.targetSize with these arguments: %v", args)
+	cmd := exec.CommandContext(ctx, ffmpegBin, args...)
+	cmd.Env = env
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	startTime := time.Now()
+	if err := timeoutError(ctx, runLowPriority(cmd)); err != nil {
+		log.Printf("first pass output: %s", output.String())
+		return fmt.Errorf("first pass failed: %w", err)
+	}
+	log.Printf("first pass finished in %s", time.Since(startTime))
+	return nil
+}
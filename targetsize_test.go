@@ -0,0 +1,128 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseTargetSize(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    int64
+		wantErr bool
+	}{
+		{"700M", 700000000, false},
+		{"700MB", 700000000, false},
+		{" 700mb ", 700000000, false},
+		{"4.7G", 4700000000, false},
+		{"1500K", 1500000, false},
+		{"0M", 0, true},
+		{"-1G", 0, true},
+		{"big", 0, true},
+		{"", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseTargetSize(tt.s)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseTargetSize(%q) error = %v, want error %t", tt.s, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseTargetSize(%q) = %d, want %d", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestTargetVideoBitrate(t *testing.T) {
+	tests := []struct {
+		size     int64
+		duration time.Duration
+		audio    int64
+		want     int64
+		wantErr  bool
+	}{
+		{700000000, time.Hour, 192000, 1332444, false},
+		{700000000, time.Hour, 0, 1524444, false},
+		{10000000, time.Hour, 0, 0, true},
+		{700000000, 0, 0, 0, true},
+	}
+	for _, tt := range tests {
+		got, err := targetVideoBitrate(tt.size, tt.duration, tt.audio)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("targetVideoBitrate(%d, %s, %d) error = %v, want error %t", tt.size, tt.duration, tt.audio, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("targetVideoBitrate(%d, %s, %d) = %d, want %d", tt.size, tt.duration, tt.audio, got, tt.want)
+		}
+	}
+}
+
+func TestTargetAudioBitrate(t *testing.T) {
+	tests := []struct {
+		audio   Audio
+		want    int64
+		wantErr bool
+	}{
+		{Audio{}, 192000, false},
+		{Audio{AudioBitrate: "128k"}, 128000, false},
+		{Audio{DisableAudio: true}, 0, false},
+		{Audio{AudioQuality: "2"}, 0, true},
+		{Audio{AudioCodec: "flac"}, 0, true},
+		{Audio{AudioCodec: "alac"}, 0, true},
+	}
+	for _, tt := range tests {
+		got, err := targetAudioBitrate(tt.audio, "in.mkv")
+		if (err != nil) != tt.wantErr {
+			t.Errorf("targetAudioBitrate(%+v) error = %v, want error %t", tt.audio, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("targetAudioBitrate(%+v) = %d, want %d", tt.audio, got, tt.want)
+		}
+	}
+}
+
+func TestTargetSizeSettingsErrors(t *testing.T) {
+	x264 := Video{Encoder: "libx264", TargetSize: "700M"}
+	tests := []struct {
+		name     string
+		settings Settings
+	}{
+		{"bad size", Settings{Video: Video{Encoder: "libx264", TargetSize: "big"}}},
+		{"copy", Settings{Video: Video{JustCopy: true, TargetSize: "700M"}}},
+		{"hardware encoder", Settings{Video: Video{Encoder: "h264_nvenc", TargetSize: "700M"}}},
+		{"outputs", Settings{Video: x264, Outputs: []ExtraOutput{{Suffix: "_small"}}}},
+		{"segments", Settings{Video: x264, Time: Time{Segments: []Segment{{Start: "0", End: "10"}}}}},
+	}
+	for _, tt := range tests {
+		if _, err := targetSizeSettings(tt.settings, "in.mkv"); err == nil {
+			t.Errorf("%s: targetSizeSettings didn't fail", tt.name)
+		}
+	}
+}
+
+func TestWithPassArgs(t *testing.T) {
+	args := []string{"-i", "in.mkv", "-c:v", "libx264", "out.mp4"}
+	x265 := []string{"-i", "in.mkv", "-c:v", "libx265", "-x265-params", "hdr-opt=1", "out.mp4"}
+	tests := []struct {
+		args  []string
+		video Video
+		pass  int
+		want  []string
+	}{
+		{args, Video{Encoder: "libx264"}, 1, []string{"-i", "in.mkv", "-c:v", "libx264", "-pass", "1", "-passlogfile", "/tmp/pass", "out.mp4"}},
+		{args, Video{Encoder: "libx264"}, 2, []string{"-i", "in.mkv", "-c:v", "libx264", "-pass", "2", "-passlogfile", "/tmp/pass", "out.mp4"}},
+		{[]string{"-i", "in.mkv", "-c:v", "libx265", "out.mp4"}, Video{Encoder: "libx265"}, 1, []string{"-i", "in.mkv", "-c:v", "libx265", "-x265-params", "pass=1:stats=/tmp/pass.log", "out.mp4"}},
+		{x265, Video{Encoder: "libx265"}, 2, []string{"-i", "in.mkv", "-c:v", "libx265", "-x265-params", "hdr-opt=1:pass=2:stats=/tmp/pass.log", "out.mp4"}},
+	}
+	for _, tt := range tests {
+		if got := withPassArgs(tt.args, tt.video, tt.pass, "/tmp/pass"); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("withPassArgs(%q, %s, %d) = %q, want %q", tt.args, tt.video.Encoder, tt.pass, got, tt.want)
+		}
+	}
+	if withPassArgs(x265, Video{Encoder: "libx265"}, 1, "/tmp/pass"); x265[5] != "hdr-opt=1" {
+		t.Errorf("withPassArgs changed the args it was given: %q", x265)
+	}
+}
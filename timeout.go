@@ -0,0 +1,26 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// errTimedOut is returned by processFile when the encode ran past -file-timeout and was killed
+var errTimedOut = errors.New("timed out")
+
+// fileContext is the time budget for one file, from when processing it starts.  With no -file-timeout it never runs out.
+func fileContext() (context.Context, context.CancelFunc) {
+	if *fileTimeout <= 0 {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithTimeout(context.Background(), *fileTimeout)
+}
+
+// timeoutError replaces the killed process's exit status with errTimedOut if ctx is why it was killed
+func timeoutError(ctx context.Context, err error) error {
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%w after -file-timeout %s", errTimedOut, *fileTimeout)
+	}
+	return err
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTimeoutError(t *testing.T) {
+	killed := errors.New("signal: killed")
+
+	expired, cancel := context.WithTimeout(context.Background(), time.Nanosecond)
+	defer cancel()
+	<-expired.Done()
+	cancelled, cancelNow := context.WithCancel(context.Background())
+	cancelNow()
+
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		err      error
+		timedOut bool
+		wantNil  bool
+	}{
+		{"finished in time", context.Background(), nil, false, true},
+		{"failed in time", context.Background(), killed, false, false},
+		{"killed at the deadline", expired, killed, true, false},
+		{"succeeded just before the deadline", expired, nil, false, true},
+		{"cancelled", cancelled, killed, false, false},
+	}
+	for _, tt := range tests {
+		err := timeoutError(tt.ctx, tt.err)
+		if (err == nil) != tt.wantNil {
+			t.Errorf("%s: timeoutError = %v, want nil %t", tt.name, err, tt.wantNil)
+		}
+		if errors.Is(err, errTimedOut) != tt.timedOut {
+			t.Errorf("%s: timeoutError = %v, want errTimedOut %t", tt.name, err, tt.timedOut)
+		}
+	}
+}
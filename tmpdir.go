@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// runTempDir is this run's own directory inside -tmpdir.  Runs sharing a -tmpdir can't trip over each
+// other's files, and whatever's left in it when the run ends goes with it.
+var runTempDir string
+
+// setupTempDir checks -tmpdir is a usable directory, makes runTempDir in it and points TMPDIR there, so
+// anything ffmpeg or its libraries write to the temp directory lands there too.  removeTempDir cleans up.
+func setupTempDir() error {
+	fh, err := os.Stat(*tmpDir)
+	if err != nil {
+		return fmt.Errorf("unable to use -tmpdir: %v", err)
+	}
+	if !fh.IsDir() {
+		return fmt.Errorf("-tmpdir %s is not a directory", *tmpDir)
+	}
+	if *argsOnly {
+		//nothing's run, so there's nothing to put in it
+		return nil
+	}
+	runTempDir, err = os.MkdirTemp(*tmpDir, "ffmpegfront-")
+	if err != nil {
+		return fmt.Errorf("unable to use -tmpdir: %v", err)
+	}
+	return os.Setenv("TMPDIR", runTempDir)
+}
+
+// removeTempDir removes runTempDir and everything in it.  main defers it, and it has to be called before
+// os.Exit too, which skips deferred calls.
+func removeTempDir() {
+	if runTempDir != "" {
+		os.RemoveAll(runTempDir)
+		runTempDir = ""
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSetupTempDir(t *testing.T) {
+	t.Setenv("TMPDIR", os.Getenv("TMPDIR"))
+	defer func(d string, a bool) { *tmpDir, *argsOnly = d, a }(*tmpDir, *argsOnly)
+	defer removeTempDir()
+
+	dir := t.TempDir()
+	*tmpDir = dir
+	if err := setupTempDir(); err != nil {
+		t.Fatalf("setupTempDir() = %v", err)
+	}
+	if filepath.Dir(runTempDir) != dir || !strings.HasPrefix(filepath.Base(runTempDir), "ffmpegfront-") {
+		t.Errorf("runTempDir = %s, want an ffmpegfront- directory in %s", runTempDir, dir)
+	}
+	if got := os.Getenv("TMPDIR"); got != runTempDir {
+		t.Errorf("TMPDIR = %s, want %s", got, runTempDir)
+	}
+	made := runTempDir
+	if err := os.WriteFile(filepath.Join(made, "leftover"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	removeTempDir()
+	if _, err := os.Stat(made); !os.IsNotExist(err) {
+		t.Errorf("%s still there after removeTempDir: %v", made, err)
+	}
+	if runTempDir != "" {
+		t.Errorf("runTempDir = %s after removeTempDir, want it cleared", runTempDir)
+	}
+	//main defers it and calls it before exiting, so a second call has to be harmless
+	removeTempDir()
+}
+
+func TestSetupTempDirErrors(t *testing.T) {
+	t.Setenv("TMPDIR", os.Getenv("TMPDIR"))
+	defer func(d string, a bool) { *tmpDir, *argsOnly = d, a }(*tmpDir, *argsOnly)
+	defer removeTempDir()
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file")
+	if err := os.WriteFile(file, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range []string{filepath.Join(dir, "missing"), file} {
+		*tmpDir = d
+		if err := setupTempDir(); err == nil {
+			t.Errorf("setupTempDir() with -tmpdir %s = nil, want an error", d)
+		}
+	}
+
+	*tmpDir, *argsOnly = dir, true
+	if err := setupTempDir(); err != nil {
+		t.Fatalf("setupTempDir() with -args-only = %v", err)
+	}
+	if runTempDir != "" {
+		t.Errorf("setupTempDir() with -args-only made %s, want nothing", runTempDir)
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// maxVerifyErrors is how many of the decoder's complaints go into the error, a badly broken file can print thousands
+const maxVerifyErrors = 5
+
+// deepVerify decodes all of file and fails if the decoder reports anything.  ffmpeg -v error only prints
+// problems, so any output at all means part of the file didn't decode cleanly.
+func deepVerify(ctx context.Context, file string) error {
+	cmd := exec.CommandContext(ctx, ffmpegBin, "-hide_banner", "-nostdin", "-v", "error", "-i", file, "-f", "null", "-")
+	var errb bytes.Buffer
+	cmd.Stderr = &errb
+	err := runLowPriority(cmd)
+	problems := strings.Split(strings.TrimSpace(errb.String()), "\n")
+	if len(problems) == 1 && problems[0] == "" {
+		problems = nil
+	}
+	if err == nil && len(problems) == 0 {
+		return nil
+	}
+
+	count := len(problems)
+	if len(problems) > maxVerifyErrors {
+		problems = problems[:maxVerifyErrors]
+	}
+	if err != nil {
+		return fmt.Errorf("%s didn't decode: %v: %s", file, err, strings.Join(problems, "; "))
+	}
+	return fmt.Errorf("%s has %d decode errors: %s", file, count, strings.Join(problems, "; "))
+}
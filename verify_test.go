@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeFfmpeg writes a shell script standing in for ffmpeg and points ffmpegBin at it until the test ends
+func fakeFfmpeg(t *testing.T, script string) {
+	t.Helper()
+	bin := filepath.Join(t.TempDir(), "ffmpeg")
+	if err := os.WriteFile(bin, []byte("#!/bin/sh\n"+script+"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	old := ffmpegBin
+	ffmpegBin = bin
+	t.Cleanup(func() { ffmpegBin = old })
+}
+
+func TestDeepVerify(t *testing.T) {
+	tests := []struct {
+		name    string
+		script  string
+		wantErr string
+	}{
+		{"clean", "exit 0", ""},
+		{"decode errors", "for i in 1 2 3 4 5 6 7; do echo \"error $i\" >&2; done", "out.mkv has 7 decode errors: error 1; error 2; error 3; error 4; error 5"},
+		{"failed", "echo 'moov atom not found' >&2; exit 1", "out.mkv didn't decode: exit status 1: moov atom not found"},
+	}
+	for _, tt := range tests {
+		fakeFfmpeg(t, tt.script)
+		err := deepVerify(context.Background(), "out.mkv")
+		switch {
+		case tt.wantErr == "" && err != nil:
+			t.Errorf("%s: deepVerify = %v, want nil", tt.name, err)
+		case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+			t.Errorf("%s: deepVerify = %v, want %q", tt.name, err, tt.wantErr)
+		}
+		if err != nil && strings.Contains(err.Error(), "error 6") {
+			t.Errorf("%s: deepVerify kept more than %d errors: %v", tt.name, maxVerifyErrors, err)
+		}
+	}
+}
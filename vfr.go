@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// parseRate turns an ffprobe frame rate like 30000/1001 into frames per second, 0 if there isn't one (ffprobe
+// says 0/0)
+func parseRate(rate string) float64 {
+	num, den, found := strings.Cut(rate, "/")
+	n, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return 0
+	}
+	if !found {
+		return n
+	}
+	d, err := strconv.ParseFloat(den, 64)
+	if err != nil || d == 0 {
+		return 0
+	}
+	return n / d
+}
+
+// isVfr is whether a video stream looks like variable frame rate.  r_frame_rate is the rate every timestamp fits
+// on and avg_frame_rate is frames over duration, which only agree when the frames come at a steady rate.  They're
+// allowed to be 1% apart since rounding in the container can make a constant rate look slightly off.
+func (s probeStream) isVfr() bool {
+	r, avg := parseRate(s.RFrameRate), parseRate(s.AvgFrameRate)
+	if r == 0 || avg == 0 {
+		return false
+	}
+	return math.Abs(r-avg)/r > 0.01
+}
+
+// vfrSettings does video.vfrMode for the input f:
+//   - empty only checks, and warns if the input is variable frame rate since those can drift out of sync once
+//     transcoded
+//   - cfr makes the output constant frame rate at the input's average rate (or video.frameRate if it's set)
+//   - passthrough keeps the input's timestamps as they are, so a variable frame rate stays variable
+func vfrSettings(v Video, f string) (args []string, filters []string, err error) {
+	switch v.VfrMode {
+	case "passthrough":
+		return []string{"-vsync", "passthrough"}, nil, nil
+	case "", "cfr":
+	default:
+		return nil, nil, fmt.Errorf("video.vfrMode %q should be cfr or passthrough", v.VfrMode)
+	}
+
+	probe, probeErr := probeFile(f)
+	if probeErr != nil {
+		log.Printf("warning: unable to check %s for a variable frame rate: %v\n", f, probeErr)
+	}
+	streams := probe.streamsOfType("video")
+	if len(streams) == 0 {
+		if v.VfrMode == "cfr" {
+			args = append(args, []string{"-vsync", "cfr"}...)
+		}
+		return
+	}
+	s := streams[0]
+
+	if v.VfrMode == "" {
+		if s.isVfr() {
+			log.Printf("warning: %s looks like variable frame rate (r_frame_rate %s, avg_frame_rate %s), which can drift out of sync after transcoding.  Set video.vfrMode to cfr to make it constant\n", f, s.RFrameRate, s.AvgFrameRate)
+		}
+		return
+	}
+
+	args = append(args, []string{"-vsync", "cfr"}...)
+	//video.frameRate already puts an fps filter in
+	if v.FrameRate == "" && parseRate(s.AvgFrameRate) > 0 {
+		filters = append(filters, fmt.Sprintf("fps=%s", s.AvgFrameRate))
+	}
+	return
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseRate(t *testing.T) {
+	tests := []struct {
+		rate string
+		want float64
+	}{
+		{"25/1", 25},
+		{"30000/1001", 30000.0 / 1001},
+		{"29.97", 29.97},
+		{"24", 24},
+		{"0/0", 0},
+		{"", 0},
+		{"fast", 0},
+	}
+	for _, tt := range tests {
+		if got := parseRate(tt.rate); got != tt.want {
+			t.Errorf("parseRate(%q) = %v, want %v", tt.rate, got, tt.want)
+		}
+	}
+}
+
+func TestIsVfr(t *testing.T) {
+	tests := []struct {
+		r, avg string
+		want   bool
+	}{
+		{"25/1", "25/1", false},
+		{"24000/1001", "2997/125", false},
+		{"60/1", "2997/100", true},
+		{"30/1", "0/0", false},
+		{"0/0", "30/1", false},
+	}
+	for _, tt := range tests {
+		s := probeStream{RFrameRate: tt.r, AvgFrameRate: tt.avg}
+		if got := s.isVfr(); got != tt.want {
+			t.Errorf("isVfr(r %s, avg %s) = %t, want %t", tt.r, tt.avg, got, tt.want)
+		}
+	}
+}
+
+func TestVfrSettingsMode(t *testing.T) {
+	args, filters, err := vfrSettings(Video{VfrMode: "passthrough"}, "in.mkv")
+	if err != nil || !reflect.DeepEqual(args, []string{"-vsync", "passthrough"}) || filters != nil {
+		t.Errorf("vfrSettings(passthrough) = %v, %v, %v", args, filters, err)
+	}
+	if _, _, err := vfrSettings(Video{VfrMode: "constant"}, "in.mkv"); err == nil {
+		t.Errorf("vfrSettings(constant): got no error")
+	}
+}
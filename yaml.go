@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Settings files can also be written in YAML, which is easier to hand edit and can have comments.  The keys
+// are the same as the json ones.
+
+func isYamlFile(file string) bool {
+	ext := strings.ToLower(filepath.Ext(file))
+	return ext == ".yaml" || ext == ".yml"
+}
+
+// unmarshalYaml fills in settings from data.  Only the keys data has are set, so it can be laid over other
+// settings like a sidecar is.  Keys that aren't settings are errors rather than being quietly ignored.
+func unmarshalYaml(data []byte, settings *Settings) error {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	err := decoder.Decode(settings)
+	if errors.Is(err, io.EOF) {
+		//an empty file, or one that's only comments
+		return nil
+	}
+	return err
+}
+
+// marshalYaml writes settings out as YAML
+func marshalYaml(settings Settings) (data []byte, err error) {
+	var b bytes.Buffer
+	encoder := yaml.NewEncoder(&b)
+	encoder.SetIndent(2)
+	if err = encoder.Encode(settings); err != nil {
+		return
+	}
+	err = encoder.Close()
+	return b.Bytes(), err
+}
+
+// yamlMappingValue returns the value for key in mapping, adding it as a new kind of node if it isn't there
+func yamlMappingValue(mapping *yaml.Node, key string, kind yaml.Kind) *yaml.Node {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+	value := &yaml.Node{Kind: kind}
+	mapping.Content = append(mapping.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, value)
+	return value
+}
+
+// markYamlCompleted sets ready.completed in the file's parsed nodes and writes them back, so the comments in
+// it survive
+func markYamlCompleted(file string) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		log.Printf("unable to mark %s completed: %v\n", file, err)
+		return
+	}
+
+	var doc yaml.Node
+	if err = yaml.Unmarshal(data, &doc); err != nil {
+		log.Printf("unable to mark %s completed: %v\n", file, err)
+		return
+	}
+	if doc.Kind == 0 {
+		//nothing in the file but comments, if that
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		log.Printf("unable to mark %s completed: it isn't a mapping of settings\n", file)
+		return
+	}
+	ready := yamlMappingValue(root, "ready", yaml.MappingNode)
+	if ready.Kind == yaml.ScalarNode && ready.Tag == "!!null" {
+		//a "ready:" with nothing under it
+		*ready = yaml.Node{Kind: yaml.MappingNode}
+	}
+	if ready.Kind != yaml.MappingNode {
+		log.Printf("unable to mark %s completed: ready isn't a mapping\n", file)
+		return
+	}
+	completed := yamlMappingValue(ready, "completed", yaml.ScalarNode)
+	completed.Kind, completed.Tag, completed.Value = yaml.ScalarNode, "!!bool", "true"
+
+	var b bytes.Buffer
+	encoder := yaml.NewEncoder(&b)
+	encoder.SetIndent(2)
+	if err = encoder.Encode(&doc); err == nil {
+		err = encoder.Close()
+	}
+	if err == nil {
+		err = ioutil.WriteFile(file, b.Bytes(), 0644)
+	}
+	if err != nil {
+		log.Printf("unable to mark %s completed: %v\n", file, err)
+	}
+}